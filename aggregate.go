@@ -0,0 +1,183 @@
+package vector
+
+import (
+	"encoding/binary"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+/*
+ * Aggregates - totals over the int items of a vector (plain ints, Counter
+ * and Ordered), maintained on every write when Options.Aggregates is set,
+ * see Vector.Aggregates.
+ *
+ * Key layout, in the vector's subspace:
+ *
+ *	("agg", "sum")   little-endian int64, atomic ADD
+ *	("agg", "count") little-endian int64, atomic ADD
+ *	("agg", "min")   complement of the max layout, atomic MAX
+ *	("agg", "max")   little-endian int64 with the sign bit flipped, atomic MAX
+ *
+ * At API version 300 MIN treats a missing key as zero, which would pin
+ * the minimum of a new or cleared vector; MAX does not, so the minimum is
+ * kept as the maximum of the complemented layout.
+ */
+type Aggregates struct {
+	Sum   int64 // sum of the int items, wrapping on overflow
+	Count int64 // number of stored int items
+
+	// Smallest and largest int written since the aggregates were last
+	// rebuilt; only valid when Count > 0. Removing or overwriting an item
+	// does not narrow them, see RebuildAggregates.
+	Min, Max int64
+}
+
+// Read the aggregates maintained with Options.Aggregates, in a single
+// round trip whatever the size of the vector. Returns ErrNotEnabled
+// unless the handle has the option set.
+func (vect *Vector) Aggregates(tr fdb.ReadTransaction) (_ Aggregates, err error) {
+	defer func() { err = vect.opError("Aggregates", -1, err) }()
+
+	var agg Aggregates
+	if !vect.opts.Aggregates {
+		return agg, ErrNotEnabled
+	}
+	names := []string{"sum", "count", "min", "max"}
+	futures := make([]fdb.FutureByteSlice, len(names))
+	for i, name := range names {
+		futures[i] = tr.Get(vect.aggKey(name))
+	}
+	vals := make([][]byte, len(names))
+	for i, f := range futures {
+		b, err := f.Get()
+		if err != nil {
+			return agg, err
+		}
+		vals[i] = b
+	}
+	agg.Sum = unpackCounter(vals[0])
+	agg.Count = unpackCounter(vals[1])
+	agg.Min = unpackMinInt(vals[2])
+	agg.Max = unpackOrderedInt(vals[3])
+	return agg, nil
+}
+
+// Recompute the aggregates exactly by scanning the vector, e.g. to
+// tighten Min and Max after removals, or after the items were written
+// without Options.Aggregates (SnapshotClone copies items verbatim, the
+// atomic mutations bypass the bookkeeping). The scan runs in tr, so very
+// large vectors may exceed the transaction limits.
+func (vect *Vector) RebuildAggregates(tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("RebuildAggregates", -1, err) }()
+
+	if err := vect.mutation("RebuildAggregates", -1, tr); err != nil {
+		return err
+	}
+	vect.clearAggregates(tr)
+
+	var agg Aggregates
+	ri := tr.GetRange(vect.indexRange(), fdb.RangeOptions{Mode: fdb.StreamingModeWantAll}).Iterator()
+	for ri.Advance() {
+		kv, err := ri.Get()
+		if err != nil {
+			return err
+		}
+		n, ok := aggInt(kv.Value)
+		if !ok {
+			continue
+		}
+		if agg.Count == 0 || n < agg.Min {
+			agg.Min = n
+		}
+		if agg.Count == 0 || n > agg.Max {
+			agg.Max = n
+		}
+		agg.Sum += n
+		agg.Count++
+	}
+	if agg.Count == 0 {
+		return nil
+	}
+	tr.Set(vect.aggKey("sum"), packCounter(agg.Sum))
+	tr.Set(vect.aggKey("count"), packCounter(agg.Count))
+	tr.Set(vect.aggKey("min"), packMinInt(agg.Min))
+	tr.Set(vect.aggKey("max"), packOrderedInt(agg.Max))
+	return nil
+}
+
+// Account for the item old (nil for absent) being replaced by packed (nil
+// for removed), when Options.Aggregates is set. Atomic mutations only, so
+// concurrent writers do not conflict on the aggregate keys.
+func (vect *Vector) aggregate(old, packed []byte, tr fdb.Transaction) {
+	if !vect.opts.Aggregates {
+		return
+	}
+	var sum, count int64
+	if n, ok := aggInt(old); ok {
+		sum -= n
+		count--
+	}
+	if n, ok := aggInt(packed); ok {
+		sum += n
+		count++
+		tr.Max(vect.aggKey("min"), packMinInt(n))
+		tr.Max(vect.aggKey("max"), packOrderedInt(n))
+	}
+	if sum != 0 {
+		tr.Add(vect.aggKey("sum"), packCounter(sum))
+	}
+	if count != 0 {
+		tr.Add(vect.aggKey("count"), packCounter(count))
+	}
+}
+
+func (vect *Vector) clearAggregates(tr fdb.Transaction) {
+	tr.ClearRange(vect.subspace.Sub("agg"))
+}
+
+func (vect *Vector) aggKey(name string) fdb.Key {
+	return vect.subspace.Pack(tuple.Tuple{"agg", name})
+}
+
+// The int a packed item holds, if it is one.
+func aggInt(packed []byte) (int64, bool) {
+	lv := LazyValue{raw: packed}
+	if !lv.IsInt() {
+		return 0, false
+	}
+	n, err := lv.Int()
+	return n, err == nil
+}
+
+// Pack n so that MIN and MAX, comparing little-endian unsigned, order it
+// as a signed int.
+func packOrderedInt(n int64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(n)^signBit)
+	return b
+}
+
+func unpackOrderedInt(b []byte) int64 {
+	if b == nil {
+		return 0
+	}
+	return int64(uint64(unpackCounter(b)) ^ signBit)
+}
+
+// Pack n so that MAX, comparing little-endian unsigned, keeps the smallest
+// signed int: the complement of packOrderedInt.
+func packMinInt(n int64) []byte {
+	b := packOrderedInt(n)
+	for i := range b {
+		b[i] = ^b[i]
+	}
+	return b
+}
+
+func unpackMinInt(b []byte) int64 {
+	if b == nil {
+		return 0
+	}
+	return ^unpackOrderedInt(b)
+}
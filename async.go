@@ -0,0 +1,136 @@
+package vector
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+/*
+ * SizeFuture - a Size read that has been issued but not waited on, see
+ * SizeAsync.
+ */
+type SizeFuture struct {
+	vect *Vector
+	tr   fdb.ReadTransaction
+	last fdb.FutureKey
+}
+
+/*
+ * BackFuture - a Back read that has been issued but not waited on, see
+ * BackAsync.
+ */
+type BackFuture struct {
+	vect *Vector
+	rr   fdb.RangeResult
+}
+
+/*
+ * RangeFuture - a GetRange read that has been issued but not waited on,
+ * see GetRangeAsync.
+ */
+type RangeFuture struct {
+	vect *Vector
+	tr   fdb.ReadTransaction
+	vro  VectRange
+	size SizeFuture
+	rr   *fdb.RangeResult // nil until the key range is known
+}
+
+// Like Size, but only issues the read; call Get to wait for it. Issuing
+// the reads of several vectors before waiting on any lets them run
+// concurrently.
+func (vect *Vector) SizeAsync(tr fdb.ReadTransaction) SizeFuture {
+	_, end := vect.indexRange().FDBRangeKeys()
+	return SizeFuture{vect: vect, tr: tr, last: tr.GetKey(fdb.LastLessOrEqual(end))}
+}
+
+// Wait for the size. Behaves exactly like Vector.Size.
+func (f SizeFuture) Get() (_ int64, err error) {
+	vect := f.vect
+	defer func() { err = vect.opError("Size", -1, err) }()
+
+	begin, _ := vect.indexRange().FDBRangeKeys()
+	last := f.last
+
+	for {
+		// GET is a blocking operation
+		lastkey, err := last.Get()
+		if err != nil {
+			return 0, err
+		}
+		// lastkey < beginKey indicates an empty vector
+		if bytes.Compare(lastkey, begin.FDBKey()) == -1 {
+			return 0, nil
+		}
+
+		index, err := vect.indexAt(lastkey)
+		if err != nil {
+			if vect.opts.SkipForeignKeys && errors.Is(err, ErrForeignKey) {
+				last = f.tr.GetKey(fdb.LastLessThan(lastkey))
+				continue
+			}
+			return 0, err
+		}
+
+		return index + 1, nil
+	}
+}
+
+// Like Back, but only issues the read; call Get to wait for it.
+func (vect *Vector) BackAsync(tr fdb.ReadTransaction) BackFuture {
+	ropts := fdb.RangeOptions{
+		Limit:   1,
+		Reverse: true,
+	}
+	return BackFuture{vect: vect, rr: tr.GetRange(vect.indexRange(), ropts)}
+}
+
+// Wait for the last item. Behaves exactly like Vector.Back.
+func (f BackFuture) Get() (_ *Value, err error) {
+	defer func() { err = f.vect.opError("Back", -1, err) }()
+
+	last, err := f.rr.GetSliceWithError()
+	if err != nil {
+		return nil, err
+	}
+	if len(last) == 0 {
+		// should this be an error?
+		return &Value{Origin: OriginMissing}, nil
+	}
+
+	return ValUnpack(last[0].Value)
+}
+
+// Like GetRange, but only issues the reads; call Get to wait for them.
+// The range itself is issued right away when vro does not depend on the
+// size (Start at least 0, Stop above it and not Open); otherwise it is
+// issued once the size read it needs has come back.
+func (vect *Vector) GetRangeAsync(vro VectRange, tr fdb.ReadTransaction) *RangeFuture {
+	f := &RangeFuture{vect: vect, tr: tr, vro: vro}
+	if vro.Start >= 0 && vro.Stop > vro.Start && vro.Step >= 0 && !vro.Open {
+		kr, rev := vect.rangeKeys(vro, 0)
+		rr := tr.GetRange(kr, fdb.RangeOptions{Reverse: rev})
+		f.rr = &rr
+	} else {
+		f.size = vect.SizeAsync(tr)
+	}
+	return f
+}
+
+// Wait for the range and return an iterator over it, like GetRange.
+func (f *RangeFuture) Get() (_ *Vectorator, err error) {
+	defer func() { err = f.vect.opError("GetRange", -1, err) }()
+
+	if f.rr == nil {
+		size, err := f.size.Get()
+		if err != nil {
+			return nil, err
+		}
+		kr, rev := f.vect.rangeKeys(f.vro, size)
+		rr := f.tr.GetRange(kr, fdb.RangeOptions{Reverse: rev})
+		f.rr = &rr
+	}
+	return &Vectorator{ri: f.rr.Iterator(), vect: f.vect}, nil
+}
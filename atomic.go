@@ -0,0 +1,139 @@
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+/*
+ * Atomic - composes operations over several Vectors into a single
+ * transaction. Either every step commits or none does.
+ *
+ *	err := vector.NewAtomic(db).
+ *		Do("enqueue", pending, func(v *vector.Vector, tr fdb.Transaction) error {
+ *			return v.Push("job-1", tr)
+ *		}).
+ *		Do("count", counts, func(v *vector.Vector, tr fdb.Transaction) error {
+ *			return v.Set(0, 1, tr)
+ *		}).
+ *		Run()
+ */
+type Atomic struct {
+	db    fdb.Database
+	opts  TxOptions
+	steps []atomicStep
+}
+
+type atomicStep struct {
+	name     string
+	onVector bool // added with Do rather than DoTx
+	vect     *Vector
+	fn       func(fdb.Transaction) error
+}
+
+/*
+ * StepError - the error of a single step of an Atomic, identified by its
+ * position and name.
+ */
+type StepError struct {
+	Step int
+	Name string
+	Err  error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("atomic step %d (%s): %s", e.Step, e.Name, e.Err)
+}
+
+func (e *StepError) Unwrap() error {
+	return e.Err
+}
+
+/*
+ * StepErrors - every step that failed validation before Run started the
+ * transaction.
+ */
+type StepErrors []*StepError
+
+func (errs StepErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Start an empty Atomic running against db.
+func NewAtomic(db fdb.Database) *Atomic {
+	return &Atomic{db: db}
+}
+
+// Add a step operating on vect.
+func (a *Atomic) Do(name string, vect *Vector, fn func(*Vector, fdb.Transaction) error) *Atomic {
+	step := atomicStep{name: name, onVector: true, vect: vect}
+	if fn != nil {
+		step.fn = func(tr fdb.Transaction) error {
+			return fn(vect, tr)
+		}
+	}
+	a.steps = append(a.steps, step)
+	return a
+}
+
+// Add a step that works directly on the transaction, e.g. for other
+// layers sharing it.
+func (a *Atomic) DoTx(name string, fn func(fdb.Transaction) error) *Atomic {
+	a.steps = append(a.steps, atomicStep{name: name, fn: fn})
+	return a
+}
+
+// Set the options of the transaction Run uses.
+func (a *Atomic) WithTxOptions(opts TxOptions) *Atomic {
+	a.opts = opts
+	return a
+}
+
+// Validate the steps and run them in order in one transaction. Validation
+// problems are all reported together as StepErrors; a step failing inside
+// the transaction aborts it and is reported as a *StepError.
+func (a *Atomic) Run() error {
+	if err := a.validate(); err != nil {
+		return err
+	}
+
+	_, err := WithTxOptions(a.db, a.opts).Transact(func(tr fdb.Transaction) (interface{}, error) {
+		for i, step := range a.steps {
+			if err := step.fn(tr); err != nil {
+				return nil, &StepError{Step: i, Name: step.name, Err: err}
+			}
+		}
+		return nil, nil
+	})
+	return err
+}
+
+// Check every vector is usable and, when known, lives in a.db.
+func (a *Atomic) validate() error {
+	var errs StepErrors
+	for i, step := range a.steps {
+		switch {
+		case step.fn == nil:
+			errs = append(errs, &StepError{Step: i, Name: step.name, Err: errors.New("nil step function")})
+		case !step.onVector:
+			// DoTx step, nothing more to check
+		case step.vect == nil:
+			errs = append(errs, &StepError{Step: i, Name: step.name, Err: errors.New("nil vector")})
+		case step.vect.subspace == nil:
+			errs = append(errs, &StepError{Step: i, Name: step.name, Err: errors.New("vector has no subspace")})
+		case step.vect.db != nil && *step.vect.db != a.db:
+			errs = append(errs, &StepError{Step: i, Name: step.name, Err: ErrDatabaseMismatch})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
@@ -0,0 +1,97 @@
+package vector
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/directory"
+)
+
+func TestAtomic(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	a, err := Open(db, []string{"tests", "atomic", "a"}, "")
+	if err != nil {
+		panic(err)
+	}
+	b, err := Open(db, []string{"tests", "atomic", "b"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	err = NewAtomic(db).
+		DoTx("reset", func(tr fdb.Transaction) error {
+			a.Clear(tr)
+			b.Clear(tr)
+			return nil
+		}).
+		Do("push a", a, func(v *Vector, tr fdb.Transaction) error {
+			return v.Push("x", tr)
+		}).
+		Do("push b", b, func(v *Vector, tr fdb.Transaction) error {
+			return v.Push("y", tr)
+		}).
+		Run()
+	if err != nil {
+		t.Error(err)
+	}
+
+	// a failing step aborts the whole transaction
+	err = NewAtomic(db).
+		Do("push a", a, func(v *Vector, tr fdb.Transaction) error {
+			return v.Push("z", tr)
+		}).
+		Do("bad push", b, func(v *Vector, tr fdb.Transaction) error {
+			return v.Push(struct{}{}, tr)
+		}).
+		Run()
+	var se *StepError
+	if !errors.As(err, &se) || se.Step != 1 {
+		t.Errorf("Expected StepError for step 1, got %v", err)
+	}
+
+	size, err := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		return a.Size(tr)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+	if size.(int64) != 1 {
+		t.Errorf("Expected aborted step to leave size 1, got %d", size)
+	}
+
+	err = NewAtomic(db).Do("nil", nil, nil).Run()
+	if _, ok := err.(StepErrors); !ok {
+		t.Errorf("Expected StepErrors from validation, got %v", err)
+	}
+}
+
+func TestOpenInPartition(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	partition, err := directory.CreateOrOpen(db, []string{"tests", "partition"}, []byte("partition"))
+	if err != nil {
+		panic(err)
+	}
+
+	if _, err := Open(db, []string{"tests", "partition"}, ""); err != ErrPartitionRoot {
+		t.Errorf("Expected ErrPartitionRoot, got %v", err)
+	}
+
+	vector, err := OpenIn(db, partition, []string{"vector"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		vector.Push("a", tr)
+		return vector.Size(tr)
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
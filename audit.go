@@ -0,0 +1,172 @@
+package vector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync/atomic"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+/*
+ * With Options.Audit set, every mutation that passes the Authorizer is
+ * recorded in the audit subspace, in the transaction making it:
+ *
+ *	audit + versionstamp + (seq) -> (principal, op, index, path...)
+ *
+ * seq orders the records of one transaction. Records are only ever
+ * appended; the subspace can be shared by many vectors, the path tells
+ * them apart.
+ */
+
+// Orders the audit records written by this process within a transaction.
+var auditSeq uint32
+
+/*
+ * AuditRecord - one audited mutation: who made it (the handle's principal,
+ * see WithPrincipal), which method, on which index (-1 for none), of
+ * which vector, committed at Version.
+ */
+type AuditRecord struct {
+	Version   Versionstamp
+	Principal string
+	Op        string
+	Index     int64
+	Path      []string
+
+	key []byte // position in the audit log
+}
+
+/*
+ * AuditQuery - selects audit records for QueryAudit. Empty fields match
+ * everything; Index is only compared when ByIndex is set. After resumes
+ * a query past the record at that Position, Until stops before records
+ * committed at or after that version.
+ */
+type AuditQuery struct {
+	Principal string
+	Op        string
+	Index     int64
+	ByIndex   bool
+	After     []byte
+	Until     Versionstamp
+	Limit     int
+}
+
+// Return a copy of the Vector recording principal as the author of its
+// audited mutations.
+func (vect *Vector) WithPrincipal(principal string) *Vector {
+	v := *vect
+	v.principal = principal
+	return &v
+}
+
+// Authorize a mutation, audit it and bump the dirty counter.
+func (vect *Vector) mutation(op string, index int64, tr fdb.Transaction) error {
+	if err := vect.Authorize(op, index); err != nil {
+		return err
+	}
+	vect.audit(op, index, tr)
+	vect.notify(tr)
+	return nil
+}
+
+// Record op on index in the audit subspace, when Options.Audit is set.
+func (vect *Vector) audit(op string, index int64, tr fdb.Transaction) {
+	if vect.opts.Audit == nil {
+		return
+	}
+	prefix := vect.opts.Audit.Bytes()
+	suffix := tuple.Tuple{int64(atomic.AddUint32(&auditSeq, 1))}.Pack()
+
+	// key with a placeholder for the versionstamp, the suffix, and the
+	// little-endian offset of the placeholder
+	key := make([]byte, len(prefix)+len(Versionstamp{})+len(suffix)+2)
+	copy(key, prefix)
+	copy(key[len(prefix)+len(Versionstamp{}):], suffix)
+	binary.LittleEndian.PutUint16(key[len(key)-2:], uint16(len(prefix)))
+
+	rec := tuple.Tuple{vect.principal, op, index}
+	for _, p := range vect.path() {
+		rec = append(rec, p)
+	}
+	tr.SetVersionstampedKey(fdb.Key(key), rec.Pack())
+}
+
+// Read the audit records matching q from the vector's Options.Audit
+// subspace, oldest first. Records of every vector sharing the subspace
+// are returned; filter on Path to tell them apart.
+func (vect *Vector) QueryAudit(q AuditQuery, tr fdb.ReadTransaction) (_ []AuditRecord, err error) {
+	defer func() { err = vect.opError("QueryAudit", -1, err) }()
+
+	if vect.opts.Audit == nil {
+		return nil, ErrNotEnabled
+	}
+	prefix := vect.opts.Audit.Bytes()
+	kr, err := fdb.PrefixRange(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if q.After != nil {
+		kr.Begin = fdb.Key(append(append(append([]byte{}, prefix...), q.After...), 0x00))
+	}
+	if q.Until != (Versionstamp{}) {
+		kr.End = fdb.Key(append(append([]byte{}, prefix...), q.Until[:]...))
+	}
+
+	var records []AuditRecord
+	ri := tr.GetRange(kr, fdb.RangeOptions{}).Iterator()
+	for ri.Advance() {
+		kv, err := ri.Get()
+		if err != nil {
+			return nil, err
+		}
+		rec, err := auditRecord(prefix, kv)
+		if err != nil {
+			return nil, err
+		}
+		if (q.Principal != "" && rec.Principal != q.Principal) ||
+			(q.Op != "" && rec.Op != q.Op) ||
+			(q.ByIndex && rec.Index != q.Index) {
+			continue
+		}
+		records = append(records, rec)
+		if q.Limit > 0 && len(records) == q.Limit {
+			break
+		}
+	}
+	return records, nil
+}
+
+// Position of the record in the audit log, to resume a query after it.
+func (rec AuditRecord) Position() []byte {
+	return rec.key
+}
+
+// Decode an audit key value pair.
+func auditRecord(prefix []byte, kv fdb.KeyValue) (AuditRecord, error) {
+	var rec AuditRecord
+	if len(kv.Key) <= len(prefix)+len(rec.Version) || !bytes.HasPrefix(kv.Key, prefix) {
+		return rec, &ForeignKeyError{Key: kv.Key, Reason: "malformed audit key"}
+	}
+	rec.key = append([]byte{}, kv.Key[len(prefix):]...)
+	copy(rec.Version[:], rec.key)
+
+	t, err := tuple.Unpack(kv.Value)
+	if err != nil || len(t) < 3 {
+		return rec, &ForeignKeyError{Key: kv.Key, Reason: "malformed audit record"}
+	}
+	var ok [3]bool
+	rec.Principal, ok[0] = t[0].(string)
+	rec.Op, ok[1] = t[1].(string)
+	rec.Index, ok[2] = t[2].(int64)
+	if !ok[0] || !ok[1] || !ok[2] {
+		return rec, &ForeignKeyError{Key: kv.Key, Reason: "malformed audit record"}
+	}
+	for _, p := range t[3:] {
+		s, _ := p.(string)
+		rec.Path = append(rec.Path, s)
+	}
+	return rec, nil
+}
@@ -0,0 +1,40 @@
+package vector
+
+import "github.com/FoundationDB/fdb-go/fdb/directory"
+
+/*
+ * Authorizer - decides whether a mutation may go ahead. op is the method
+ * name (e.g. "Set", "Clear", "Add"), path the vector's directory path (nil
+ * for vectors not opened through the directory layer) and index the item,
+ * or -1 for operations without one. A non-nil error denies the mutation,
+ * which then returns that error without writing anything. Methods built on
+ * others, such as Requeue pushing the item back, also check the methods
+ * they call.
+ */
+type Authorizer func(op string, path []string, index int64) error
+
+// Return a copy of the Vector consulting auth before every mutation.
+func (vect *Vector) WithAuthorizer(auth Authorizer) *Vector {
+	v := *vect
+	v.authorizer = auth
+	return &v
+}
+
+// Ask the vector's Authorizer whether op may change the item at index
+// (-1 for none). Clear, which has no error result, does nothing when it
+// is denied; ClearWithError returns the reason.
+func (vect *Vector) Authorize(op string, index int64) error {
+	if vect.authorizer == nil {
+		return nil
+	}
+	return vect.authorizer(op, vect.path(), index)
+}
+
+// The directory path of the vector, nil when it was not opened through
+// the directory layer.
+func (vect *Vector) path() []string {
+	if ds, ok := vect.subspace.(directory.DirectorySubspace); ok {
+		return ds.GetPath()
+	}
+	return nil
+}
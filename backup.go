@@ -0,0 +1,253 @@
+package vector
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/directory"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+// Stored items per backup chunk (and per transaction).
+const backupChunkSize = 1000
+
+// Leading bytes of every backup chunk.
+var backupMagic = []byte("FDBV1")
+
+/*
+ * Uploader - destination of a backup, e.g. an object store. Upload is
+ * called once per chunk, in order; name is unique per chunk of a backup
+ * ("<backup>/<chunk number>"). Uploading the same name again must replace
+ * the object, since a resumed backup may repeat the last chunk.
+ */
+type Uploader interface {
+	Upload(ctx context.Context, name string, chunk []byte) error
+}
+
+type writerUploader struct {
+	w io.Writer
+}
+
+// Make an Uploader appending every chunk to w, each preceded by its length
+// as 4 byte big-endian integer. Resuming a backup into a writer is not
+// supported, the stream would contain the repeated chunk twice.
+func WriterUploader(w io.Writer) Uploader {
+	return writerUploader{w}
+}
+
+func (wu writerUploader) Upload(ctx context.Context, name string, chunk []byte) error {
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(chunk)))
+	if _, err := wu.w.Write(n[:]); err != nil {
+		return err
+	}
+	_, err := wu.w.Write(chunk)
+	return err
+}
+
+// Back up the stored items of the vector to up under name, one chunk per
+// transaction. Progress is recorded in the vector's ("backup", name) key
+// after every chunk, so calling BackupTo again with the same name after a
+// failure resumes where it stopped; the key is removed once the backup is
+// complete.
+//
+// Chunks are read in separate transactions, so writes made to the vector
+// while the backup runs may or may not be included.
+func (vect *Vector) BackupTo(ctx context.Context, db fdb.Transactor, name string, up Uploader) (err error) {
+	defer func() { err = vect.opError("BackupTo", -1, err) }()
+
+	p := Progress{Op: "BackupTo", Last: -1}
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		r, err := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			return vect.backupChunk(name, tr)
+		})
+		if err != nil {
+			return err
+		}
+		c := r.(backupState)
+
+		if c.chunk != nil {
+			chunkName := fmt.Sprintf("%s/%08d", name, c.number)
+			if err := up.Upload(ctx, chunkName, c.chunk); err != nil {
+				return err
+			}
+		}
+
+		// commit progress only after the upload succeeded
+		_, err = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			if c.done {
+				tr.Clear(vect.backupKey(name))
+			} else {
+				tr.Set(vect.backupKey(name), tuple.Tuple{c.next, c.number + 1}.Pack())
+			}
+			return nil, nil
+		})
+		if err != nil {
+			return err
+		}
+		vect.reportProgress(&p, c.items, c.bytes, c.next-1, c.done)
+		if c.done {
+			return nil
+		}
+	}
+}
+
+// Back up every vector directly below dir, each under name/<subdirectory>.
+// Directory partitions below dir are backed up recursively, under
+// name/<partition>/<subdirectory>. Each vector is opened with the key
+// encoding and default recorded in its metadata, see openStored.
+func BackupDirectory(ctx context.Context, db fdb.Transactor, dir directory.Directory, name string, up Uploader) error {
+	children, err := dir.List(db, nil)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		sub, err := dir.Open(db, []string{child}, nil)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(sub.GetLayer(), partitionLayer) {
+			// a partition holds no keys itself, only more directories
+			if err := BackupDirectory(ctx, db, sub, name+"/"+child, up); err != nil {
+				return err
+			}
+			continue
+		}
+		vect, err := openStored(db, sub)
+		if err != nil {
+			return err
+		}
+		if err := vect.BackupTo(ctx, db, name+"/"+child, up); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Verify a chunk written by BackupTo and store its items in the vector.
+// Returns an error matching ErrCorruptChunk if the checksum does not match.
+// Timestamps the items carried are dropped; with Options.Timestamps they
+// are stamped with the time of the restore.
+func (vect *Vector) RestoreChunk(chunk []byte, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("RestoreChunk", -1, err) }()
+
+	if err := vect.mutation("RestoreChunk", -1, tr); err != nil {
+		return err
+	}
+	if len(chunk) < len(backupMagic)+4 || !bytes.HasPrefix(chunk, backupMagic) {
+		return fmt.Errorf("vector.restorechunk: bad header: %w", ErrCorruptChunk)
+	}
+	body := chunk[len(backupMagic) : len(chunk)-4]
+	sum := binary.BigEndian.Uint32(chunk[len(chunk)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return fmt.Errorf("vector.restorechunk: checksum mismatch: %w", ErrCorruptChunk)
+	}
+
+	for len(body) > 0 {
+		index, n := binary.Varint(body)
+		if n <= 0 {
+			return fmt.Errorf("vector.restorechunk: bad index: %w", ErrCorruptChunk)
+		}
+		body = body[n:]
+		size, n := binary.Uvarint(body)
+		if n <= 0 || uint64(len(body)-n) < size {
+			return fmt.Errorf("vector.restorechunk: bad value length: %w", ErrCorruptChunk)
+		}
+		body = body[n:]
+
+		key, err := vect.prepareWrite("vector.restorechunk", index, tr)
+		if err != nil {
+			return err
+		}
+		// strip any timestamp, setKey stamps again with Options.Timestamps
+		packed := LazyValue{raw: body[:size]}.body()
+		if err := vect.setKey(key, packed, tr); err != nil {
+			return err
+		}
+		body = body[size:]
+	}
+	return nil
+}
+
+// Outcome of reading one backup chunk.
+type backupState struct {
+	chunk  []byte // nil if there was nothing left to back up
+	number int64  // chunk number
+	next   int64  // first index of the following chunk
+	done   bool
+	items  int64 // items in the chunk
+	bytes  int64 // bytes of their packed values
+}
+
+// Read the next chunk of backup name, as recorded in its progress key.
+//
+// Chunk layout: magic, then per item its index (varint), value length
+// (uvarint) and packed value, then the CRC-32 (IEEE) of the items as 4 byte
+// big-endian integer.
+func (vect *Vector) backupChunk(name string, tr fdb.Transaction) (backupState, error) {
+	var st backupState
+
+	progress, err := tr.Get(vect.backupKey(name)).Get()
+	if err != nil {
+		return st, err
+	}
+	if progress != nil {
+		t, err := tuple.Unpack(progress)
+		var ok [2]bool
+		if err == nil && len(t) == 2 {
+			st.next, ok[0] = t[0].(int64)
+			st.number, ok[1] = t[1].(int64)
+		}
+		if !ok[0] || !ok[1] {
+			return st, fmt.Errorf("vector.backup: bad progress marker for %q", name)
+		}
+	}
+
+	_, end := vect.indexRange().FDBRangeKeys()
+	kr := fdb.KeyRange{Begin: vect.keyAt(st.next), End: end}
+	kvs, err := tr.GetRange(kr, fdb.RangeOptions{Limit: backupChunkSize}).GetSliceWithError()
+	if err != nil {
+		return st, err
+	}
+	st.done = len(kvs) < backupChunkSize
+	if len(kvs) == 0 {
+		return st, nil
+	}
+
+	var body bytes.Buffer
+	var n [binary.MaxVarintLen64]byte
+	for _, kv := range kvs {
+		index, err := vect.indexAt(kv.Key)
+		if err != nil {
+			return st, err
+		}
+		body.Write(n[:binary.PutVarint(n[:], index)])
+		body.Write(n[:binary.PutUvarint(n[:], uint64(len(kv.Value)))])
+		body.Write(kv.Value)
+		st.next = index + 1
+		st.items++
+		st.bytes += int64(len(kv.Value))
+	}
+
+	chunk := make([]byte, 0, len(backupMagic)+body.Len()+4)
+	chunk = append(chunk, backupMagic...)
+	chunk = append(chunk, body.Bytes()...)
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(body.Bytes()))
+	st.chunk = append(chunk, sum[:]...)
+	return st, nil
+}
+
+// Key of the progress marker of backup name.
+func (vect *Vector) backupKey(name string) fdb.Key {
+	return vect.subspace.Pack(tuple.Tuple{"backup", name})
+}
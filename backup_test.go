@@ -0,0 +1,191 @@
+package vector
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/directory"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+func TestBackupTo(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	src, err := Open(db, []string{"tests", "backup", "src"}, "")
+	if err != nil {
+		panic(err)
+	}
+	dest, err := Open(db, []string{"tests", "backup", "dest"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		src.Clear(tr)
+		for i := 0; i < backupChunkSize+10; i++ {
+			src.Push(i, tr)
+		}
+		dest.Clear(tr)
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	var buf bytes.Buffer
+	if err := src.BackupTo(context.Background(), db, "nightly", WriterUploader(&buf)); err != nil {
+		t.Fatal(err)
+	}
+
+	var chunks [][]byte
+	for data := buf.Bytes(); len(data) > 0; {
+		n := binary.BigEndian.Uint32(data)
+		chunks = append(chunks, data[4:4+n])
+		data = data[4+n:]
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("Expected 2 chunks, got %d instead", len(chunks))
+	}
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		for _, chunk := range chunks {
+			if err := dest.RestoreChunk(chunk, tr); err != nil {
+				return nil, err
+			}
+		}
+		size, err := dest.Size(tr)
+		if err != nil {
+			return nil, err
+		}
+		if size != backupChunkSize+10 {
+			return nil, fmt.Errorf("Expected restored size %d, got %d instead", backupChunkSize+10, size)
+		}
+		val, err := dest.Get(backupChunkSize+5, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.Int != backupChunkSize+5 {
+			return nil, fmt.Errorf("Expected %d, got %d instead", backupChunkSize+5, val.Int)
+		}
+
+		progress, err := tr.Get(src.backupKey("nightly")).Get()
+		if err != nil {
+			return nil, err
+		}
+		if progress != nil {
+			return nil, fmt.Errorf("Expected progress marker to be removed after backup")
+		}
+
+		corrupt := append([]byte(nil), chunks[1]...)
+		corrupt[len(backupMagic)] ^= 0xff
+		if err := dest.RestoreChunk(corrupt, tr); !errors.Is(err, ErrCorruptChunk) {
+			return nil, fmt.Errorf("Expected ErrCorruptChunk, got %v instead", err)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+
+	// a marker of the wrong types fails the backup instead of panicking
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(src.backupKey("nightly"), tuple.Tuple{"next", "number"}.Pack())
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if err := src.BackupTo(context.Background(), db, "nightly", WriterUploader(&buf)); err == nil {
+		t.Error("Expected a bad progress marker to fail the backup")
+	}
+	db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Clear(src.backupKey("nightly"))
+		return nil, nil
+	})
+}
+
+func TestBackupDirectory(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	root, err := directory.CreateOrOpen(db, []string{"tests", "backupdir"}, nil)
+	if err != nil {
+		panic(err)
+	}
+	partition, err := root.CreateOrOpen(db, []string{"part"}, []byte("partition"))
+	if err != nil {
+		panic(err)
+	}
+	fixed, err := OpenIn(db, root, []string{"fixed"}, "-")
+	if err != nil {
+		panic(err)
+	}
+	fixed = fixed.WithOptions(Options{FixedKeys: true, Timestamps: true})
+	inner, err := OpenIn(db, partition, []string{"inner"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		fixed.Clear(tr)
+		inner.Clear(tr)
+		if err := fixed.Init(tr); err != nil {
+			return nil, err
+		}
+		fixed.Push("a", tr)
+		return nil, inner.Push("b", tr)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	var buf bytes.Buffer
+	if err := BackupDirectory(context.Background(), db, root, "nightly", WriterUploader(&buf)); err != nil {
+		t.Fatal(err)
+	}
+
+	var chunks [][]byte
+	for data := buf.Bytes(); len(data) > 0; {
+		n := binary.BigEndian.Uint32(data)
+		chunks = append(chunks, data[4:4+n])
+		data = data[4+n:]
+	}
+	if len(chunks) != 2 {
+		t.Fatalf("Expected a chunk per vector, got %d instead", len(chunks))
+	}
+
+	// the stamped item restores into a vector stamping it again
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		fixed.Clear(tr)
+		inner.Clear(tr)
+		for i, v := range []*Vector{fixed, inner} {
+			if err := v.RestoreChunk(chunks[i], tr); err != nil {
+				return nil, err
+			}
+		}
+		val, err := fixed.Get(0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.String != "a" || val.Modified.IsZero() {
+			return nil, fmt.Errorf("Expected stamped 'a', got %+v", val)
+		}
+		val, err = inner.Get(0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.String != "b" {
+			return nil, fmt.Errorf("Expected 'b' from the partition, got %+v", val)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
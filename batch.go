@@ -0,0 +1,131 @@
+package vector
+
+import (
+	"fmt"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+/*
+ * WriteBatch - Set and Push calls on a Vector held back until Flush, which
+ * applies them with one size read instead of one per call. Values are
+ * packed when added, so encoding errors surface at the call that caused
+ * them. A WriteBatch is not safe for concurrent use.
+ */
+type WriteBatch struct {
+	vect   *Vector
+	writes []batchWrite
+}
+
+// One buffered write; push writes go to the end of the vector.
+type batchWrite struct {
+	index  int64
+	push   bool
+	packed []byte
+}
+
+// Start an empty batch of writes to the vector.
+func (vect *Vector) Batch() *WriteBatch {
+	return &WriteBatch{vect: vect}
+}
+
+// Buffer setting the item at index, see Vector.Set.
+func (b *WriteBatch) Set(index int64, val interface{}) error {
+	v, err := ValPack(val)
+	if err != nil {
+		return err
+	}
+	b.writes = append(b.writes, batchWrite{index: index, packed: v})
+	return nil
+}
+
+// Buffer pushing an item onto the end, see Vector.Push.
+func (b *WriteBatch) Push(val interface{}) error {
+	v, err := ValPack(val)
+	if err != nil {
+		return err
+	}
+	b.writes = append(b.writes, batchWrite{push: true, packed: v})
+	return nil
+}
+
+// The number of buffered writes.
+func (b *WriteBatch) Len() int {
+	return len(b.writes)
+}
+
+// Drop the buffered writes without applying them.
+func (b *WriteBatch) Reset() {
+	b.writes = b.writes[:0]
+}
+
+// Apply the buffered writes in the order they were made, as if each had
+// been called on the vector in tr, and empty the batch. The size is read
+// once, from the counter with AtomicPush, and tracked as writes extend
+// the vector. If any write would be
+// rejected, by Dense mode or as an out of range negative index, nothing
+// is written.
+func (b *WriteBatch) Flush(tr fdb.Transaction) (err error) {
+	vect := b.vect
+	info, err := vect.beforeWrite("Flush", -1, tr)
+	if err != nil {
+		return err
+	}
+	defer vect.afterOp(info, &err)
+
+	if len(b.writes) == 0 {
+		return nil
+	}
+
+	// with AtomicPush the counter stands in for the size, read without a
+	// conflict so concurrent pushers only collide on the slots they take
+	var size int64
+	if vect.opts.AtomicPush {
+		size, err = vect.counterSize(tr)
+	} else {
+		size, err = vect.Size(tr)
+	}
+	if err != nil {
+		return err
+	}
+
+	keys := make([]fdb.Key, len(b.writes))
+	for i, w := range b.writes {
+		index := w.index
+		switch {
+		case w.push:
+			index = size
+		case index < 0 && vect.opts.NegativeIndexes:
+			if index += size; index < 0 {
+				return outOfRange("vector.flush", w.index)
+			}
+		}
+		if keys[i], err = vect.indexKey("vector.flush", index); err != nil {
+			return err
+		}
+		if w.push && vect.opts.AtomicPush {
+			if err := tr.AddReadConflictKey(keys[i]); err != nil {
+				return err
+			}
+		}
+		if vect.opts.Dense && index > size {
+			return fmt.Errorf("vector.flush: index '%d' past size %d: %w", index, size, ErrSparseWrite)
+		}
+		if index >= size {
+			size = index + 1
+		}
+	}
+
+	for i, w := range b.writes {
+		info.addBytes(len(w.packed))
+		if err := vect.setKey(keys[i], w.packed, tr); err != nil {
+			return err
+		}
+	}
+	if vect.opts.AtomicPush {
+		tr.Max(vect.sizeKey(), packCounter(size))
+	}
+
+	b.Reset()
+	return nil
+}
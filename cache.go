@@ -0,0 +1,145 @@
+package vector
+
+import "github.com/FoundationDB/fdb-go/fdb"
+
+/*
+ * CachedVector - a Vector bound to one transaction that remembers the
+ * items and size it has read, so repeated reads of the same index do not
+ * wait on the database again. Writes made through the CachedVector
+ * invalidate what they touch; writes made to the vector any other way in
+ * the same transaction (including through the Vector itself) are not seen
+ * by the cache. Not safe for concurrent use.
+ *
+ *	cv := vect.Cached(tr)
+ *	a, _ := cv.Get(3)
+ *	b, _ := cv.Get(3) // no read issued
+ */
+type CachedVector struct {
+	vect  *Vector
+	tr    fdb.Transaction
+	items map[int64]Value
+	size  int64
+	sized bool
+
+	pending *WriteBatch // deferred pushes, see PushDeferred
+}
+
+// Get a CachedVector reading and writing the vector in tr. Drop it with
+// the transaction.
+func (vect *Vector) Cached(tr fdb.Transaction) *CachedVector {
+	return &CachedVector{vect: vect, tr: tr, items: make(map[int64]Value)}
+}
+
+// Get the number of items in the Vector, reading it at most once.
+// Once the size is known, deferred pushes are counted without flushing
+// them.
+func (cv *CachedVector) Size() (int64, error) {
+	if cv.sized {
+		return cv.size, nil
+	}
+	if err := cv.Flush(); err != nil {
+		return 0, err
+	}
+	size, err := cv.vect.Size(cv.tr)
+	if err != nil {
+		return 0, err
+	}
+	cv.size, cv.sized = size, true
+	return size, nil
+}
+
+// Get the item at index, reading it at most once.
+func (cv *CachedVector) Get(index int64) (*Value, error) {
+	if v, ok := cv.items[index]; ok {
+		return &v, nil
+	}
+	if err := cv.Flush(); err != nil {
+		return nil, err
+	}
+	val, err := cv.vect.Get(index, cv.tr)
+	if err != nil {
+		return nil, err
+	}
+	// negative indexes depend on the size, only cache resolved ones
+	if index >= 0 {
+		cv.items[index] = *val
+	}
+	return val, nil
+}
+
+// Set the item at index.
+func (cv *CachedVector) Set(index int64, val interface{}) error {
+	if err := cv.Flush(); err != nil {
+		return err
+	}
+	cv.invalidate(index)
+	return cv.vect.Set(index, val, cv.tr)
+}
+
+// Push an item onto the end of the Vector.
+func (cv *CachedVector) Push(val interface{}) error {
+	if err := cv.Flush(); err != nil {
+		return err
+	}
+	cv.sized = false
+	return cv.vect.Push(val, cv.tr)
+}
+
+// Push an item onto the end of the Vector without looking up the size
+// now. Deferred pushes are written together, with a single size read,
+// by Flush, which any other method of the CachedVector calls first; call
+// it before committing. A tight loop of PushDeferred costs no round trips.
+func (cv *CachedVector) PushDeferred(val interface{}) error {
+	if cv.pending == nil {
+		cv.pending = cv.vect.Batch()
+	}
+	if err := cv.pending.Push(val); err != nil {
+		return err
+	}
+	if cv.sized {
+		cv.size++
+	}
+	return nil
+}
+
+// Write the deferred pushes.
+func (cv *CachedVector) Flush() error {
+	if cv.pending == nil || cv.pending.Len() == 0 {
+		return nil
+	}
+	return cv.pending.Flush(cv.tr)
+}
+
+// Get and pop the last item off the Vector.
+func (cv *CachedVector) Pop() (*Value, error) {
+	if err := cv.Flush(); err != nil {
+		return nil, err
+	}
+	cv.reset()
+	return cv.vect.Pop(cv.tr)
+}
+
+// Remove all items from the Vector, deferred pushes included.
+func (cv *CachedVector) Clear() {
+	if cv.pending != nil {
+		cv.pending.Reset()
+	}
+	cv.reset()
+	cv.vect.Clear(cv.tr)
+}
+
+// Forget index and the size; a negative index may refer to any item.
+func (cv *CachedVector) invalidate(index int64) {
+	if index < 0 {
+		cv.reset()
+		return
+	}
+	delete(cv.items, index)
+	cv.sized = false
+}
+
+// Forget everything read so far.
+func (cv *CachedVector) reset() {
+	cv.items = make(map[int64]Value)
+	cv.sized = false
+}
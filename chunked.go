@@ -0,0 +1,246 @@
+package vector
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+// Elements per key of a ChunkedVector by default, for 4 kB values.
+const DefaultChunkSize = 512
+
+/*
+ * ChunkedVector - a vector of float64 stored chunkSize consecutive
+ * elements per key, for wide dense numeric vectors where a key per element
+ * would cost more than the elements themselves. Get, Set, Push and
+ * GetRange address single elements; the chunks are not visible.
+ *
+ * Key layout, in the vector's subspace:
+ *
+ *	(chunk number)   little-endian float64 bits of elements
+ *	                 chunk number * chunkSize onwards
+ *	("meta", "keys") "chunked<chunkSize>", see Init
+ *
+ * A chunk may hold fewer than chunkSize elements: elements that were never
+ * written read as 0, whether their chunk is missing or short. A write
+ * rewrites its whole chunk, so writes to the same chunk in concurrent
+ * transactions conflict.
+ */
+type ChunkedVector struct {
+	chunkLayout
+}
+
+// Keys of a vector stored chunkSize elements per key, shared by
+// ChunkedVector and DeltaVector.
+type chunkLayout struct {
+	subspace  subspace.Subspace
+	chunkSize int64
+	encoding  string // recorded under ("meta", "keys") with the chunk size
+}
+
+// Create a ChunkedVector storing its elements in subspace, chunkSize of
+// them per key; DefaultChunkSize when chunkSize is not positive.
+func NewChunkedVector(subspace subspace.Subspace, chunkSize int) *ChunkedVector {
+	return &ChunkedVector{newChunkLayout(subspace, chunkSize, "chunked")}
+}
+
+func newChunkLayout(subspace subspace.Subspace, chunkSize int, encoding string) chunkLayout {
+	if chunkSize <= 0 {
+		chunkSize = DefaultChunkSize
+	}
+	return chunkLayout{subspace: subspace, chunkSize: int64(chunkSize), encoding: encoding}
+}
+
+// Record the chunk size in the vector's metadata if it has none yet, or
+// check that it matches. Returns an error matching ErrMetadataMismatch on
+// disagreement, e.g. opening a chunked vector with another chunk size or
+// a plain Vector's subspace.
+func (cl chunkLayout) Init(tr fdb.Transaction) error {
+	want := fmt.Sprintf("%s%d", cl.encoding, cl.chunkSize)
+	key := cl.subspace.Pack(tuple.Tuple{"meta", "keys"})
+	got, err := tr.Get(key).Get()
+	if err != nil {
+		return err
+	}
+	if got == nil {
+		tr.Set(key, []byte(want))
+		return nil
+	}
+	if string(got) != want {
+		return fmt.Errorf("vector: key encoding is %q, handle uses %q: %w", got, want, ErrMetadataMismatch)
+	}
+	return nil
+}
+
+// Get the number of elements in the vector.
+func (cv *ChunkedVector) Size(tr fdb.ReadTransaction) (int64, error) {
+	chunk, b, err := cv.lastChunk(tr)
+	if err != nil || b == nil {
+		return 0, err
+	}
+	return chunk*cv.chunkSize + int64(len(b)/8), nil
+}
+
+// Get the element at index.
+func (cv *ChunkedVector) Get(index int64, tr fdb.ReadTransaction) (float64, error) {
+	size, err := cv.Size(tr)
+	if err != nil {
+		return 0, err
+	}
+	if index < 0 || index >= size {
+		return 0, outOfRange("chunkedvector.get", index)
+	}
+	b, err := tr.Get(cv.chunkKey(index / cv.chunkSize)).Get()
+	if err != nil {
+		return 0, err
+	}
+	return chunkElem(b, index%cv.chunkSize), nil
+}
+
+// Set the element at index. Setting past the end grows the vector, the
+// elements in between reading as 0.
+func (cv *ChunkedVector) Set(index int64, val float64, tr fdb.Transaction) error {
+	if index < 0 {
+		return outOfRange("chunkedvector.set", index)
+	}
+	key := cv.chunkKey(index / cv.chunkSize)
+	b, err := tr.Get(key).Get()
+	if err != nil {
+		return err
+	}
+	if end := int(index%cv.chunkSize+1) * 8; len(b) < end {
+		b = append(b, make([]byte, end-len(b))...)
+	}
+	putChunkElem(b, index%cv.chunkSize, val)
+	tr.Set(key, b)
+	return nil
+}
+
+// Push an element onto the end of the vector.
+func (cv *ChunkedVector) Push(val float64, tr fdb.Transaction) error {
+	size, err := cv.Size(tr)
+	if err != nil {
+		return err
+	}
+	return cv.Set(size, val, tr)
+}
+
+// Get the elements in vro, which is resolved against the size like the
+// range of Vector.GetRange but must not have a negative Step. All chunks
+// of the range are fetched with a single range read.
+func (cv *ChunkedVector) GetRange(vro VectRange, tr fdb.ReadTransaction) ([]float64, error) {
+	size, err := cv.Size(tr)
+	if err != nil {
+		return nil, err
+	}
+	vro, err = resolveChunked("chunkedvector.getrange", vro, size)
+	if err != nil {
+		return nil, err
+	}
+	if vro.Start >= vro.Stop {
+		return []float64{}, nil
+	}
+
+	vals := make([]float64, vro.Stop-vro.Start)
+	err = cv.readChunks(vro.Start, vro.Stop, tr, func(base int64, b []byte) error {
+		for i := int64(0); i < int64(len(b)/8); i++ {
+			if index := base + i; index >= vro.Start && index < vro.Stop {
+				vals[index-vro.Start] = chunkElem(b, i)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// Remove all elements from the vector. The metadata is kept.
+func (cl chunkLayout) Clear(tr fdb.Transaction) {
+	tr.ClearRange(cl.chunkRange())
+}
+
+// Read the last chunk, nil if there is none.
+func (cl chunkLayout) lastChunk(tr fdb.ReadTransaction) (chunk int64, value []byte, err error) {
+	kvs, err := tr.GetRange(cl.chunkRange(), fdb.RangeOptions{Limit: 1, Reverse: true}).GetSliceWithError()
+	if err != nil || len(kvs) == 0 {
+		return 0, nil, err
+	}
+	chunk, err = cl.chunkAt(kvs[0].Key)
+	return chunk, kvs[0].Value, err
+}
+
+// Read the chunks holding [start, stop), calling fn with the index of the
+// first element of each.
+func (cl chunkLayout) readChunks(start, stop int64, tr fdb.ReadTransaction, fn func(base int64, value []byte) error) error {
+	first, last := start/cl.chunkSize, (stop-1)/cl.chunkSize
+	kr := fdb.KeyRange{Begin: cl.chunkKey(first), End: cl.chunkKey(last + 1)}
+	kvs, err := tr.GetRange(kr, fdb.RangeOptions{Mode: fdb.StreamingModeWantAll}).GetSliceWithError()
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		chunk, err := cl.chunkAt(kv.Key)
+		if err != nil {
+			return err
+		}
+		if err := fn(chunk*cl.chunkSize, kv.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolve vro against size for a GetRange, clamping Stop to size.
+func resolveChunked(op string, vro VectRange, size int64) (VectRange, error) {
+	if vro.Step < 0 {
+		return vro, fmt.Errorf("%s: negative step", op)
+	}
+	vro = vro.resolve(size)
+	if vro.Stop > size {
+		vro.Stop = size
+	}
+	return vro, nil
+}
+
+func (cl chunkLayout) chunkKey(chunk int64) fdb.Key {
+	return cl.subspace.Pack(tuple.Tuple{chunk})
+}
+
+func (cl chunkLayout) chunkAt(key fdb.Key) (int64, error) {
+	t, err := cl.subspace.Unpack(key)
+	if err != nil {
+		return 0, err
+	}
+	if len(t) == 1 {
+		if chunk, ok := t[0].(int64); ok {
+			return chunk, nil
+		}
+	}
+	return 0, &ForeignKeyError{Key: key, Reason: "not a chunk number"}
+}
+
+// Keys of all chunks; the metadata keys sort before them.
+func (cl chunkLayout) chunkRange() fdb.KeyRange {
+	_, end := cl.subspace.FDBRangeKeys()
+	return fdb.KeyRange{Begin: cl.chunkKey(0), End: end}
+}
+
+// Element i of a chunk's value, 0 past its end.
+func chunkElem(b []byte, i int64) float64 {
+	off := i * 8
+	if int64(len(b)) < off+8 {
+		return 0
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b[off:]))
+}
+
+// Store v as element i of a chunk's value, which must be long enough.
+func putChunkElem(b []byte, i int64, v float64) {
+	binary.LittleEndian.PutUint64(b[i*8:], math.Float64bits(v))
+}
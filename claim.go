@@ -0,0 +1,110 @@
+package vector
+
+import (
+	"time"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+/*
+ * Work claims use a vector as a worklist: consumers Claim stored items,
+ * which locks them (see TryLock) for the consumer, and Ack them once
+ * processed, which removes them. A claim that is not acked before its ttl
+ * runs out expires and the item is handed out again by a later Claim, so
+ * every item is processed at least once. Unlock gives a claim back early,
+ * Fail does so recording why (see DeadLetter).
+ */
+
+// Lease up to n unclaimed stored items, lowest index first, to consumer
+// for ttl. Items whose claim has expired count as unclaimed. Claims of
+// concurrent consumers conflict, so one of them retries.
+func (vect *Vector) Claim(consumer string, n int, ttl time.Duration, tr fdb.Transaction) (_ []IndexValue, err error) {
+	defer func() { err = vect.opError("Claim", -1, err) }()
+
+	if err := vect.mutation("Claim", -1, tr); err != nil {
+		return nil, err
+	}
+	si, err := vect.GetStoredRange(VectRange{}, tr)
+	if err != nil {
+		return nil, err
+	}
+	defer si.Close()
+
+	var claimed []IndexValue
+	for len(claimed) < n && si.Advance() {
+		index := si.Index()
+		held, _, err := vect.lockHolder(index, tr)
+		if err != nil {
+			return nil, err
+		}
+		if held != "" && held != consumer {
+			continue
+		}
+		if held == "" {
+			ok, err := vect.countClaim(index, si.Raw(), tr)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				continue
+			}
+		}
+		if _, err := vect.TryLock(index, consumer, ttl, tr); err != nil {
+			return nil, err
+		}
+		val, err := si.Value()
+		if err != nil {
+			return nil, err
+		}
+		claimed = append(claimed, IndexValue{Index: index, Value: val})
+	}
+	if err := si.Err(); err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+// Remove the item at index claimed by consumer, marking it processed.
+// Fails with ErrNotLockOwner if consumer does not hold the claim, e.g.
+// because it expired and another consumer claimed the item since.
+func (vect *Vector) Ack(index int64, consumer string, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("Ack", index, err) }()
+
+	if err := vect.mutation("Ack", index, tr); err != nil {
+		return err
+	}
+	held, _, err := vect.lockHolder(index, tr)
+	if err != nil {
+		return err
+	}
+	if held != consumer {
+		return ErrNotLockOwner
+	}
+	tr.Clear(vect.lockKey(index))
+	tr.Clear(vect.attemptsKey(index))
+	return vect.removeAt(index, tr)
+}
+
+// Clear the item at index, leaving a sparse gap, with the bookkeeping of
+// the enabled options.
+func (vect *Vector) removeAt(index int64, tr fdb.Transaction) error {
+	key := vect.keyAt(index)
+	if vect.opts.History || vect.opts.Aggregates {
+		old, err := tr.Get(key).Get()
+		if err != nil {
+			return err
+		}
+		if vect.opts.History {
+			vect.writeHistory(index, old, tr)
+		}
+		vect.aggregate(old, nil, tr)
+	}
+	if vect.opts.ChangeFeed {
+		vect.writeChange(index, nil, tr)
+	}
+	tr.Clear(key)
+	if vect.opts.Tags {
+		return vect.clearTags(index, tr)
+	}
+	return nil
+}
@@ -0,0 +1,158 @@
+package vector
+
+import (
+	"fmt"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+// Number of stored items copied per transaction by SnapshotClone.
+const cloneChunkSize = 1000
+
+// Copy the vector into dest (which is cleared first) using as many
+// transactions as needed, so vectors too large for one transaction can be
+// cloned while other clients keep writing.
+//
+// A source of up to cloneChunkSize stored items is copied in a single
+// transaction, so the clone is the source as of that transaction. Larger
+// sources need Options.ChangeFeed: stored items are copied verbatim in
+// chunks, then the changes recorded since the copy started are replayed
+// onto dest, the last of them in the same transaction that reads the
+// source size and trims dest to it, so the clone is exactly the source as
+// of that transaction. Atomic mutations are not in the feed and are only
+// picked up if the chunk holding the item is copied after them. Without
+// the feed a larger source fails with ErrNotEnabled and dest is left as it
+// was.
+//
+// db is usually a Database, but any Transactor (such as a Tenant) works.
+func (vect *Vector) SnapshotClone(dest *Vector, db fdb.Transactor) (err error) {
+	defer func() { err = vect.opError("SnapshotClone", -1, err) }()
+
+	p := Progress{Op: "SnapshotClone", Last: -1}
+	var items, bytes, last int64
+	copied := func(index int64, kv fdb.KeyValue) {
+		items++
+		bytes += int64(len(kv.Value))
+		last = index
+	}
+
+	var after []byte // position of the last change before the copy
+	r, err := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		items, bytes, last = 0, 0, -1
+		if err := dest.ClearWithError(tr); err != nil {
+			return nil, err
+		}
+		n, err := vect.cloneChunk(dest, 0, copied, tr)
+		if err != nil || n < 0 {
+			return n, err
+		}
+		if !vect.opts.ChangeFeed {
+			return nil, fmt.Errorf("vector.snapshotclone: copying more than %d items consistently needs Options.ChangeFeed: %w", cloneChunkSize, ErrNotEnabled)
+		}
+		after, err = vect.lastChange(tr)
+		return n, err
+	})
+	if err != nil {
+		return err
+	}
+	next := r.(int64) // first index not copied yet
+	vect.reportProgress(&p, items, bytes, last, next < 0)
+	if next < 0 {
+		return nil
+	}
+
+	for {
+		r, err := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			items, bytes, last = 0, 0, -1
+			return vect.cloneChunk(dest, next, copied, tr)
+		})
+		if err != nil {
+			return err
+		}
+		n := r.(int64)
+		vect.reportProgress(&p, items, bytes, last, false)
+		if n < 0 {
+			break
+		}
+		next = n
+	}
+
+	for {
+		var records []ChangeRecord
+		_, err := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			var err error
+			records, err = vect.ReadChanges(after, cloneChunkSize, tr)
+			if err != nil {
+				return nil, err
+			}
+			for _, rec := range records {
+				switch {
+				case rec.Cleared:
+					tr.ClearRange(dest.indexRange())
+				case rec.Value == nil:
+					tr.Clear(dest.keyAt(rec.Index))
+				default:
+					tr.Set(dest.keyAt(rec.Index), rec.Value.Raw)
+				}
+			}
+			if len(records) < cloneChunkSize {
+				return nil, vect.trimClone(dest, tr)
+			}
+			return nil, nil
+		})
+		if err != nil {
+			return err
+		}
+		done := len(records) < cloneChunkSize
+		vect.reportProgress(&p, 0, 0, -1, done)
+		if done {
+			return nil
+		}
+		after = records[len(records)-1].Position()
+	}
+}
+
+// Copy up to cloneChunkSize stored items starting at index next into dest,
+// passing each to copied. Returns the index to continue from, or -1 once
+// the tail has been copied and dest trimmed to the size of the source.
+func (vect *Vector) cloneChunk(dest *Vector, next int64, copied func(index int64, kv fdb.KeyValue), tr fdb.Transaction) (int64, error) {
+	_, end := vect.indexRange().FDBRangeKeys()
+	kr := fdb.KeyRange{Begin: vect.keyAt(next), End: end}
+	kvs, err := tr.GetRange(kr, fdb.RangeOptions{Limit: cloneChunkSize}).GetSliceWithError()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, kv := range kvs {
+		index, err := vect.indexAt(kv.Key)
+		if err != nil {
+			return 0, err
+		}
+		tr.Set(dest.keyAt(index), kv.Value)
+		copied(index, kv)
+		next = index + 1
+	}
+
+	if len(kvs) == cloneChunkSize {
+		return next, nil
+	}
+
+	if err := vect.trimClone(dest, tr); err != nil {
+		return 0, err
+	}
+	return -1, nil
+}
+
+// Make dest end exactly where the source ends in tr.
+func (vect *Vector) trimClone(dest *Vector, tr fdb.Transaction) error {
+	size, err := vect.Size(tr)
+	if err != nil {
+		return err
+	}
+	_, destEnd := dest.indexRange().FDBRangeKeys()
+	tr.ClearRange(fdb.KeyRange{Begin: dest.keyAt(size), End: destEnd})
+	if dest.opts.AtomicPush {
+		tr.Set(dest.sizeKey(), packCounter(size))
+	}
+	return nil
+}
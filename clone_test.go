@@ -0,0 +1,234 @@
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/directory"
+)
+
+func TestSnapshotClone(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	src, err := Open(db, []string{"tests", "clone", "src"}, "")
+	if err != nil {
+		panic(err)
+	}
+	dest, err := Open(db, []string{"tests", "clone", "dest"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		src.Clear(tr)
+		for i := 0; i < cloneChunkSize+10; i++ {
+			src.Push(i, tr)
+		}
+		src.Set(cloneChunkSize+20, "last", tr)
+
+		dest.Clear(tr)
+		dest.Set(5000, "stale", tr)
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// too large for one transaction without a change feed
+	if err := src.SnapshotClone(dest, db); !errors.Is(err, ErrNotEnabled) {
+		t.Fatalf("Expected ErrNotEnabled without a change feed, got %v", err)
+	}
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		val, err := dest.Get(5000, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.String != "stale" {
+			return nil, fmt.Errorf("Expected the failed clone to leave dest alone, got %v", val)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	var reports []Progress
+	progress := func(p Progress) { reports = append(reports, p) }
+	if err := src.WithOptions(Options{ChangeFeed: true}).WithProgress(progress).SnapshotClone(dest, db); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(reports); n != 3 || !reports[2].Done || reports[2].Items != cloneChunkSize+11 || reports[2].Last != cloneChunkSize+20 {
+		t.Errorf("Expected three progress reports ending at index %d, got %v", cloneChunkSize+20, reports)
+	}
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		size, err := dest.Size(tr)
+		if err != nil {
+			return nil, err
+		}
+		if size != cloneChunkSize+21 {
+			return nil, fmt.Errorf("Expected clone to be size %d, got %d instead", cloneChunkSize+21, size)
+		}
+		val, err := dest.Get(cloneChunkSize+5, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.Int != cloneChunkSize+5 {
+			return nil, fmt.Errorf("Expected %d, got %d instead", cloneChunkSize+5, val.Int)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+
+	// a source fitting in one transaction needs no change feed
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		src.Clear(tr)
+		src.Push("a", tr)
+		return nil, src.Push("b", tr)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	reports = nil
+	if err := src.WithProgress(progress).SnapshotClone(dest, db); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(reports); n != 1 || !reports[0].Done || reports[0].Items != 2 {
+		t.Errorf("Expected one progress report of 2 items, got %v", reports)
+	}
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		size, err := dest.Size(tr)
+		if err != nil {
+			return nil, err
+		}
+		if size != 2 {
+			return nil, fmt.Errorf("Expected clone to be size 2, got %d instead", size)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestSnapshotCloneChangeFeed(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	src, err := Open(db, []string{"tests", "clone", "src"}, "")
+	if err != nil {
+		panic(err)
+	}
+	src = src.WithOptions(Options{ChangeFeed: true})
+	dest, err := Open(db, []string{"tests", "clone", "dest"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.ClearRange(src.subspace)
+		for i := 0; i < cloneChunkSize+10; i++ {
+			src.Push(i, tr)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// rewrite an item behind the copy cursor once the first chunk is in
+	var writeErr error
+	progress := func(p Progress) {
+		if p.Items != cloneChunkSize || p.Done {
+			return
+		}
+		_, writeErr = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			if err := src.Set(5, "changed", tr); err != nil {
+				return nil, err
+			}
+			if _, err := src.Pop(tr); err != nil {
+				return nil, err
+			}
+			return nil, src.Push("new", tr)
+		})
+	}
+	if err := src.WithProgress(progress).SnapshotClone(dest, db); err != nil {
+		t.Fatal(err)
+	}
+	if writeErr != nil {
+		t.Fatal(writeErr)
+	}
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		val, err := dest.Get(5, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.String != "changed" {
+			return nil, fmt.Errorf("Expected the rewrite behind the cursor to be copied, got %v", val)
+		}
+		val, err = dest.Get(cloneChunkSize+9, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.String != "new" {
+			return nil, fmt.Errorf("Expected 'new' at the tail, got %v", val)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestExchange(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	blue, err := Open(db, []string{"tests", "exchange", "blue"}, "")
+	if err != nil {
+		panic(err)
+	}
+	green, err := Open(db, []string{"tests", "exchange", "green"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		blue.Clear(tr)
+		blue.Push("old", tr)
+		green.Clear(tr)
+		green.Push("new", tr)
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if err := Exchange(db, directory.Root(), []string{"tests", "exchange", "blue"}, []string{"tests", "exchange", "green"}); err != nil {
+		t.Fatal(err)
+	}
+
+	blue, err = Open(db, []string{"tests", "exchange", "blue"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		val, err := blue.Get(0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.String != "new" {
+			return nil, fmt.Errorf("Expected 'new' after exchange, got %q instead", val.String)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
@@ -0,0 +1,194 @@
+// Command fdbvec-stress hammers a vector with concurrent pushers, poppers
+// and readers, then reports throughput, conflict rates and any pushed
+// item that was lost or delivered twice.
+//
+//	fdbvec-stress -pushers 8 -poppers 4 -readers 2 -duration 30s
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	vector "github.com/dedalcom/fdb-vector"
+)
+
+// Counters of one kind of worker.
+type counters struct {
+	ops       int64
+	attempts  int64
+	conflicts int64
+	errors    int64
+}
+
+func (c *counters) record(stats vector.RetryStats, err error) {
+	atomic.AddInt64(&c.attempts, int64(stats.Attempts))
+	atomic.AddInt64(&c.conflicts, int64(stats.Conflicts))
+	if err != nil {
+		atomic.AddInt64(&c.errors, 1)
+		return
+	}
+	atomic.AddInt64(&c.ops, 1)
+}
+
+func (c *counters) report(name string, elapsed time.Duration) {
+	rate := 0.0
+	if c.attempts > 0 {
+		rate = 100 * float64(c.conflicts) / float64(c.attempts)
+	}
+	fmt.Printf("%-8s %8d ops %10.1f ops/s %6.1f%% conflicts %d errors\n",
+		name, c.ops, float64(c.ops)/elapsed.Seconds(), rate, c.errors)
+}
+
+func main() {
+	clusterFile := flag.String("cluster", "", "fdb cluster file, default if empty")
+	path := flag.String("path", "fdbvec-stress", "directory of the vector")
+	pushers := flag.Int("pushers", 4, "concurrent pushers")
+	poppers := flag.Int("poppers", 2, "concurrent poppers")
+	readers := flag.Int("readers", 2, "concurrent readers")
+	duration := flag.Duration("duration", 10*time.Second, "how long to run")
+	atomicPush := flag.Bool("atomic-push", false, "open the vector with Options.AtomicPush")
+	flag.Parse()
+
+	fdb.MustAPIVersion(300)
+	db, err := fdb.Open(*clusterFile, []byte("DB"))
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	vect, err := vector.Open(db, []string{*path}, "")
+	if err != nil {
+		log.Fatal(err)
+	}
+	vect = vect.WithOptions(vector.Options{AtomicPush: *atomicPush})
+	if _, err := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vect.Clear(tr)
+		return nil, nil
+	}); err != nil {
+		log.Fatal(err)
+	}
+
+	var (
+		push, pop, read counters
+		mu              sync.Mutex
+		pushed          = map[string]bool{}
+		popped          = map[string]int{}
+		wg              sync.WaitGroup
+		stop            = make(chan struct{})
+		opts            = vector.RetryOptions{MaxAttempts: 50}
+	)
+
+	for w := 0; w < *pushers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for seq := 0; ; seq++ {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				item := fmt.Sprintf("w%d-%d", w, seq)
+				_, stats, err := vector.RunWithRetry(db, opts, func(tr fdb.Transaction) (interface{}, error) {
+					return nil, vect.Push(item, tr)
+				})
+				push.record(stats, err)
+				if err == nil {
+					mu.Lock()
+					pushed[item] = true
+					mu.Unlock()
+				}
+			}
+		}(w)
+	}
+
+	for w := 0; w < *poppers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				r, stats, err := vector.RunWithRetry(db, opts, func(tr fdb.Transaction) (interface{}, error) {
+					return vect.Pop(tr)
+				})
+				pop.record(stats, err)
+				if err != nil {
+					continue
+				}
+				if val := r.(*vector.Value); val.IsString {
+					mu.Lock()
+					popped[val.String]++
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+
+	for w := 0; w < *readers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				_, err := db.ReadTransact(func(tr fdb.ReadTransaction) (interface{}, error) {
+					return vect.Peek(10, tr)
+				})
+				read.record(vector.RetryStats{Attempts: 1}, err)
+			}
+		}()
+	}
+
+	start := time.Now()
+	time.Sleep(*duration)
+	close(stop)
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	// whatever is left counts as delivered once
+	r, err := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		return vect.Drain(tr)
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, iv := range r.([]vector.IndexValue) {
+		if iv.Value.IsString {
+			popped[iv.Value.String]++
+		}
+	}
+
+	violations := 0
+	for item := range pushed {
+		if popped[item] != 1 {
+			violations++
+			fmt.Printf("violation: %s delivered %d times\n", item, popped[item])
+		}
+	}
+	for item := range popped {
+		if item != "" && !pushed[item] {
+			violations++
+			fmt.Printf("violation: %s delivered but never pushed\n", item)
+		}
+	}
+
+	push.report("push", elapsed)
+	pop.report("pop", elapsed)
+	read.report("read", elapsed)
+	fmt.Printf("%d violations\n", violations)
+	if violations > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,49 @@
+package vector
+
+import (
+	"fmt"
+
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+/*
+ * KeyCodec - how a Vector created with NewVectorAt encodes indexes into
+ * the part of a key after its root. Encodings must sort in index order and
+ * after the tuple-packed bookkeeping keys, so they should begin with a
+ * byte above 0x05 (tuple integers begin with 0x0c to 0x1c). Name is
+ * recorded in the vector's metadata by Init.
+ */
+type KeyCodec interface {
+	Name() string
+	AppendIndex(dst []byte, index int64) []byte
+	DecodeIndex(b []byte) (int64, error)
+}
+
+// The default encoding: the index as a one element tuple, the layout of
+// vectors created with NewVector.
+var TupleKeys KeyCodec = tupleKeys{}
+
+type tupleKeys struct{}
+
+func (tupleKeys) Name() string {
+	return keysTuple
+}
+
+func (tupleKeys) AppendIndex(dst []byte, index int64) []byte {
+	return append(dst, tuple.Tuple{index}.Pack()...)
+}
+
+func (tupleKeys) DecodeIndex(b []byte) (int64, error) {
+	t, err := tuple.Unpack(b)
+	if err != nil {
+		return 0, err
+	}
+	if len(t) != 1 {
+		return 0, fmt.Errorf("expected 1 tuple element, got %d", len(t))
+	}
+	index, ok := t[0].(int64)
+	if !ok {
+		return 0, fmt.Errorf("element is %T, not int64", t[0])
+	}
+	return index, nil
+}
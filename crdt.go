@@ -0,0 +1,235 @@
+package vector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+/*
+ * ElementID - identifies an element of a GList or ORList across replicas:
+ * the versionstamp of the transaction that appended it in its home
+ * cluster, the actor (e.g. datacenter) that did, and a sequence number
+ * telling apart appends made in the same transaction. Versionstamps of
+ * different clusters are unrelated, the actor makes the ID unique. Lists
+ * are ordered by ID, version first, which every replica agrees on.
+ */
+type ElementID struct {
+	Version Versionstamp
+	Actor   string
+	Seq     int64
+}
+
+/*
+ * Element - an element of a GList or ORList. Packed is the value as
+ * stored, which is what Merge writes on other replicas.
+ */
+type Element struct {
+	ID     ElementID
+	Value  *Value
+	Packed []byte
+}
+
+/*
+ * GList - a grow-only list replicated between clusters that cannot
+ * serialize their writes through one another. Each cluster appends under
+ * its own actor name and the replicas converge by exchanging State and
+ * merging it, in any order and any number of times.
+ *
+ *	("add") + versionstamp + (actor, seq) -> packed value
+ */
+type GList struct {
+	subspace subspace.Subspace
+	actor    string
+	seq      int64 // last sequence number used, see ElementID
+}
+
+/*
+ * ORList - an observed-remove list: a GList whose elements can also be
+ * removed. A removal only affects the elements its replica had seen, so a
+ * concurrent append on another replica survives it.
+ *
+ *	("rm") + versionstamp + (actor, seq) -> ""
+ */
+type ORList struct {
+	GList
+}
+
+/*
+ * ListState - the full state of a GList or ORList, exchanged between
+ * replicas to merge them.
+ */
+type ListState struct {
+	Added   []Element
+	Removed []ElementID
+}
+
+// Create a GList stored in sub, appending as actor.
+func NewGList(sub subspace.Subspace, actor string) *GList {
+	return &GList{subspace: sub, actor: actor}
+}
+
+// Create an ORList stored in sub, appending and removing as actor.
+func NewORList(sub subspace.Subspace, actor string) *ORList {
+	return &ORList{GList{subspace: sub, actor: actor}}
+}
+
+// Append val. Its ID is known once tr commits.
+func (g *GList) Append(val interface{}, tr fdb.Transaction) error {
+	packed, err := ValPack(val)
+	if err != nil {
+		return err
+	}
+	prefix := g.subspace.Pack(tuple.Tuple{"add"})
+	suffix := tuple.Tuple{g.actor, atomic.AddInt64(&g.seq, 1)}.Pack()
+
+	// key with a placeholder for the versionstamp, the actor and sequence
+	// number, and the little-endian offset of the placeholder
+	key := make([]byte, len(prefix)+len(Versionstamp{})+len(suffix)+2)
+	copy(key, prefix)
+	copy(key[len(prefix)+len(Versionstamp{}):], suffix)
+	binary.LittleEndian.PutUint16(key[len(key)-2:], uint16(len(prefix)))
+	tr.SetVersionstampedKey(fdb.Key(key), packed)
+	return nil
+}
+
+// Get the elements in ID order.
+func (g *GList) Elements(tr fdb.ReadTransaction) ([]Element, error) {
+	state, err := g.State(tr)
+	if err != nil {
+		return nil, err
+	}
+	return state.Added, nil
+}
+
+// Read the state to send to other replicas.
+func (g *GList) State(tr fdb.ReadTransaction) (ListState, error) {
+	var state ListState
+	prefix := g.subspace.Pack(tuple.Tuple{"add"})
+	kvs, err := g.readIDs(prefix, tr)
+	if err != nil {
+		return state, err
+	}
+	for _, kv := range kvs {
+		id, err := parseElementID(prefix, kv.Key)
+		if err != nil {
+			return state, err
+		}
+		val, err := ValUnpack(kv.Value)
+		if err != nil {
+			return state, err
+		}
+		state.Added = append(state.Added, Element{ID: id, Value: val, Packed: kv.Value})
+	}
+	return state, nil
+}
+
+// Merge the state of another replica. Removals are ignored by a GList.
+func (g *GList) Merge(state ListState, tr fdb.Transaction) error {
+	for _, e := range state.Added {
+		if len(e.Packed) == 0 {
+			return fmt.Errorf("vector.glist.merge: element %v has no packed value", e.ID)
+		}
+		tr.Set(g.elementKey("add", e.ID), e.Packed)
+	}
+	return nil
+}
+
+// Remove the element with ID id. Removing an element this replica has not
+// seen yet is allowed; it disappears once it arrives.
+func (l *ORList) Remove(id ElementID, tr fdb.Transaction) {
+	tr.Set(l.elementKey("rm", id), []byte{})
+}
+
+// Get the elements that are not removed, in ID order.
+func (l *ORList) Elements(tr fdb.ReadTransaction) ([]Element, error) {
+	state, err := l.State(tr)
+	if err != nil {
+		return nil, err
+	}
+	removed := make(map[ElementID]bool, len(state.Removed))
+	for _, id := range state.Removed {
+		removed[id] = true
+	}
+	var live []Element
+	for _, e := range state.Added {
+		if !removed[e.ID] {
+			live = append(live, e)
+		}
+	}
+	return live, nil
+}
+
+// Read the state, removals included, to send to other replicas.
+func (l *ORList) State(tr fdb.ReadTransaction) (ListState, error) {
+	state, err := l.GList.State(tr)
+	if err != nil {
+		return state, err
+	}
+	prefix := l.subspace.Pack(tuple.Tuple{"rm"})
+	kvs, err := l.readIDs(prefix, tr)
+	if err != nil {
+		return state, err
+	}
+	for _, kv := range kvs {
+		id, err := parseElementID(prefix, kv.Key)
+		if err != nil {
+			return state, err
+		}
+		state.Removed = append(state.Removed, id)
+	}
+	return state, nil
+}
+
+// Merge the state of another replica.
+func (l *ORList) Merge(state ListState, tr fdb.Transaction) error {
+	if err := l.GList.Merge(state, tr); err != nil {
+		return err
+	}
+	for _, id := range state.Removed {
+		l.Remove(id, tr)
+	}
+	return nil
+}
+
+// Read the keys below prefix.
+func (g *GList) readIDs(prefix []byte, tr fdb.ReadTransaction) ([]fdb.KeyValue, error) {
+	kr, err := fdb.PrefixRange(prefix)
+	if err != nil {
+		return nil, err
+	}
+	return tr.GetRange(kr, fdb.RangeOptions{}).GetSliceWithError()
+}
+
+// Key of id below ("kind").
+func (g *GList) elementKey(kind string, id ElementID) fdb.Key {
+	var key []byte
+	key = append(key, g.subspace.Pack(tuple.Tuple{kind})...)
+	key = append(key, id.Version[:]...)
+	return append(key, tuple.Tuple{id.Actor, id.Seq}.Pack()...)
+}
+
+// Decode the ElementID of a key below prefix.
+func parseElementID(prefix []byte, key fdb.Key) (ElementID, error) {
+	var id ElementID
+	if len(key) <= len(prefix)+len(id.Version) || !bytes.HasPrefix(key, prefix) {
+		return id, &ForeignKeyError{Key: key, Reason: "malformed list element key"}
+	}
+	copy(id.Version[:], key[len(prefix):])
+	t, err := tuple.Unpack(key[len(prefix)+len(id.Version):])
+	if err != nil || len(t) != 2 {
+		return id, &ForeignKeyError{Key: key, Reason: "malformed list element key"}
+	}
+	actor, ok1 := t[0].(string)
+	seq, ok2 := t[1].(int64)
+	if !ok1 || !ok2 {
+		return id, &ForeignKeyError{Key: key, Reason: "malformed list element key"}
+	}
+	id.Actor, id.Seq = actor, seq
+	return id, nil
+}
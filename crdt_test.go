@@ -0,0 +1,87 @@
+package vector
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/directory"
+)
+
+func TestORList(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	subA, err := directory.CreateOrOpen(db, []string{"tests", "orlist", "a"}, nil)
+	if err != nil {
+		panic(err)
+	}
+	subB, err := directory.CreateOrOpen(db, []string{"tests", "orlist", "b"}, nil)
+	if err != nil {
+		panic(err)
+	}
+	a := NewORList(subA, "east")
+	b := NewORList(subB, "west")
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.ClearRange(subA)
+		tr.ClearRange(subB)
+		a.Append("a1", tr)
+		a.Append("a2", tr)
+		return nil, b.Append("b1", tr)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// a removes a1, then both exchange state
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		elems, err := a.Elements(tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(elems) != 2 {
+			return nil, fmt.Errorf("Expected 2 elements, got %d instead", len(elems))
+		}
+		a.Remove(elems[0].ID, tr)
+
+		sa, err := a.State(tr)
+		if err != nil {
+			return nil, err
+		}
+		sb, err := b.State(tr)
+		if err != nil {
+			return nil, err
+		}
+		if err := a.Merge(sb, tr); err != nil {
+			return nil, err
+		}
+		return nil, b.Merge(sa, tr)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		ea, err := a.Elements(tr)
+		if err != nil {
+			return nil, err
+		}
+		eb, err := b.Elements(tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(ea) != 2 || len(eb) != 2 {
+			return nil, fmt.Errorf("Expected 2 elements on both replicas, got %d and %d", len(ea), len(eb))
+		}
+		for i := range ea {
+			if ea[i].ID != eb[i].ID {
+				return nil, fmt.Errorf("Replicas disagree at %d: %v vs %v", i, ea[i].ID, eb[i].ID)
+			}
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
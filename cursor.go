@@ -0,0 +1,134 @@
+package vector
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+/*
+ * Cursor - a named, persistent read position over the stored items of a
+ * vector used as an append-only log. Each consumer uses its own name and
+ * moves at its own pace: ReadNext returns the items after the committed
+ * position, Commit records how far the consumer got. Positions live under
+ * ("cursor", name) in the vector's subspace.
+ */
+type Cursor struct {
+	vect *Vector
+	name string
+}
+
+/*
+ * ChangeCursor - like Cursor, but over the change feed of a vector with
+ * Options.ChangeFeed, positioned by versionstamp. Positions live under
+ * ("changecursor", name).
+ */
+type ChangeCursor struct {
+	vect *Vector
+	name string
+}
+
+// Get the cursor of consumer name.
+func (vect *Vector) Cursor(name string) *Cursor {
+	return &Cursor{vect: vect, name: name}
+}
+
+// Get the change feed cursor of consumer name.
+func (vect *Vector) ChangeCursor(name string) *ChangeCursor {
+	return &ChangeCursor{vect: vect, name: name}
+}
+
+// Read up to batch stored items after the committed position.
+func (c *Cursor) ReadNext(batch int, tr fdb.ReadTransaction) ([]IndexValue, error) {
+	next, err := c.Position(tr)
+	if err != nil {
+		return nil, err
+	}
+
+	_, end := c.vect.indexRange().FDBRangeKeys()
+	kr := fdb.KeyRange{Begin: c.vect.keyAt(next), End: end}
+	kvs, err := tr.GetRange(kr, fdb.RangeOptions{Limit: batch}).GetSliceWithError()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]IndexValue, 0, len(kvs))
+	for _, kv := range kvs {
+		index, err := c.vect.indexAt(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := ValUnpack(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, IndexValue{Index: index, Value: val})
+	}
+	return items, nil
+}
+
+// Record that the items up to and including index are processed.
+func (c *Cursor) Commit(index int64, tr fdb.Transaction) {
+	tr.Set(c.key(), tuple.Tuple{index + 1}.Pack())
+}
+
+// Get the index the next ReadNext starts at, 0 for a new cursor.
+func (c *Cursor) Position(tr fdb.ReadTransaction) (int64, error) {
+	v, err := tr.Get(c.key()).Get()
+	if err != nil || v == nil {
+		return 0, err
+	}
+	t, err := tuple.Unpack(v)
+	if err != nil || len(t) != 1 {
+		return 0, &ForeignKeyError{Key: c.key(), Reason: "malformed cursor"}
+	}
+	next, ok := t[0].(int64)
+	if !ok {
+		return 0, &ForeignKeyError{Key: c.key(), Reason: "malformed cursor"}
+	}
+	return next, nil
+}
+
+// Forget the cursor, so it starts over at the beginning.
+func (c *Cursor) Reset(tr fdb.Transaction) {
+	tr.Clear(c.key())
+}
+
+// Key holding the position of the cursor.
+func (c *Cursor) key() fdb.Key {
+	return c.vect.subspace.Pack(tuple.Tuple{"cursor", c.name})
+}
+
+// Read up to batch change records after the committed position.
+func (c *ChangeCursor) ReadNext(batch int, tr fdb.ReadTransaction) ([]ChangeRecord, error) {
+	after, err := tr.Get(c.key()).Get()
+	if err != nil {
+		return nil, err
+	}
+	return c.vect.ReadChanges(after, batch, tr)
+}
+
+// Record that the change records up to and including rec are processed.
+func (c *ChangeCursor) Commit(rec ChangeRecord, tr fdb.Transaction) {
+	tr.Set(c.key(), rec.Position())
+}
+
+// Get the versionstamp of the last committed record, zero for a new cursor.
+func (c *ChangeCursor) Position(tr fdb.ReadTransaction) (Versionstamp, error) {
+	var vs Versionstamp
+	pos, err := tr.Get(c.key()).Get()
+	if err != nil {
+		return vs, err
+	}
+	copy(vs[:], pos)
+	return vs, nil
+}
+
+// Forget the cursor, so it starts over at the beginning of the feed.
+func (c *ChangeCursor) Reset(tr fdb.Transaction) {
+	tr.Clear(c.key())
+}
+
+// Key holding the position of the cursor.
+func (c *ChangeCursor) key() fdb.Key {
+	return c.vect.subspace.Pack(tuple.Tuple{"changecursor", c.name})
+}
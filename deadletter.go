@@ -0,0 +1,183 @@
+package vector
+
+import (
+	"time"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+/*
+ * Claim attempts are counted per item in a sibling subspace:
+ *
+ *	("attempts", index) -> (claims, last failure reason)
+ *
+ * With Options.MaxClaims set, an item claimed that many times without an
+ * Ack is moved to the dead-letter subspace by the next Claim instead of
+ * being handed out again:
+ *
+ *	dead.Pack(index) -> (packed value, claims, reason, unix nanoseconds)
+ *
+ * The dead-letter subspace is Options.DeadLetter, by default ("dead") in
+ * the vector's subspace.
+ */
+
+/*
+ * DeadLetter - an item moved out of the vector after too many failed
+ * claims, with the reason its last consumer gave in Fail.
+ */
+type DeadLetter struct {
+	Index    int64
+	Value    *Value
+	Attempts int64
+	Reason   string
+	Failed   time.Time
+}
+
+// Give up the claim consumer holds on the item at index, recording reason
+// as the cause. The item can be claimed again right away; once it has been
+// claimed Options.MaxClaims times it is dead-lettered instead.
+func (vect *Vector) Fail(index int64, consumer, reason string, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("Fail", index, err) }()
+
+	if err := vect.mutation("Fail", index, tr); err != nil {
+		return err
+	}
+	if err := vect.Unlock(index, consumer, tr); err != nil {
+		return err
+	}
+	attempts, _, err := vect.claimAttempts(index, tr)
+	if err != nil {
+		return err
+	}
+	tr.Set(vect.attemptsKey(index), tuple.Tuple{attempts, reason}.Pack())
+	return nil
+}
+
+// List the dead-lettered items, by original index.
+func (vect *Vector) DeadLetters(tr fdb.ReadTransaction) (_ []DeadLetter, err error) {
+	defer func() { err = vect.opError("DeadLetters", -1, err) }()
+
+	dead := vect.deadLetterSpace()
+	kvs, err := tr.GetRange(dead, fdb.RangeOptions{}).GetSliceWithError()
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]DeadLetter, 0, len(kvs))
+	for _, kv := range kvs {
+		k, err := dead.Unpack(kv.Key)
+		if err != nil || len(k) != 1 {
+			return nil, &ForeignKeyError{Key: kv.Key, Reason: "malformed dead letter"}
+		}
+		t, err := tuple.Unpack(kv.Value)
+		if err != nil || len(t) != 4 {
+			return nil, &ForeignKeyError{Key: kv.Key, Reason: "malformed dead letter"}
+		}
+		index, ok1 := k[0].(int64)
+		packed, ok2 := t[0].([]byte)
+		attempts, ok3 := t[1].(int64)
+		reason, ok4 := t[2].(string)
+		failed, ok5 := t[3].(int64)
+		if !ok1 || !ok2 || !ok3 || !ok4 || !ok5 {
+			return nil, &ForeignKeyError{Key: kv.Key, Reason: "malformed dead letter"}
+		}
+		val, err := ValUnpack(packed)
+		if err != nil {
+			return nil, err
+		}
+		letters = append(letters, DeadLetter{
+			Index:    index,
+			Value:    val,
+			Attempts: attempts,
+			Reason:   reason,
+			Failed:   time.Unix(0, failed),
+		})
+	}
+	return letters, nil
+}
+
+// Move the item dead-lettered from index back onto the end of the vector
+// with a fresh attempt count. Does nothing if there is no such item.
+func (vect *Vector) Requeue(index int64, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("Requeue", index, err) }()
+
+	if err := vect.mutation("Requeue", index, tr); err != nil {
+		return err
+	}
+	key := vect.deadLetterSpace().Pack(tuple.Tuple{index})
+	v, err := tr.Get(key).Get()
+	if err != nil || v == nil {
+		return err
+	}
+	t, err := tuple.Unpack(v)
+	if err != nil || len(t) != 4 {
+		return &ForeignKeyError{Key: key, Reason: "malformed dead letter"}
+	}
+	packed, ok := t[0].([]byte)
+	if !ok {
+		return &ForeignKeyError{Key: key, Reason: "malformed dead letter"}
+	}
+	tr.Clear(key)
+
+	size, err := vect.Size(tr)
+	if err != nil {
+		return err
+	}
+	tr.Clear(vect.attemptsKey(size))
+	wkey, err := vect.prepareWrite("vector.requeue", size, tr)
+	if err != nil {
+		return err
+	}
+	return vect.setKey(wkey, LazyValue{raw: packed}.body(), tr)
+}
+
+// Count a new claim of the item at index. Returns false when the item
+// exceeded Options.MaxClaims and was dead-lettered instead.
+func (vect *Vector) countClaim(index int64, packed []byte, tr fdb.Transaction) (bool, error) {
+	attempts, reason, err := vect.claimAttempts(index, tr)
+	if err != nil {
+		return false, err
+	}
+	if vect.opts.MaxClaims > 0 && attempts >= int64(vect.opts.MaxClaims) {
+		dead := tuple.Tuple{packed, attempts, reason, time.Now().UnixNano()}
+		tr.Set(vect.deadLetterSpace().Pack(tuple.Tuple{index}), dead.Pack())
+		tr.Clear(vect.attemptsKey(index))
+		return false, vect.removeAt(index, tr)
+	}
+	tr.Set(vect.attemptsKey(index), tuple.Tuple{attempts + 1, reason}.Pack())
+	return true, nil
+}
+
+// Read the claim count and last failure reason of index.
+func (vect *Vector) claimAttempts(index int64, tr fdb.ReadTransaction) (int64, string, error) {
+	key := vect.attemptsKey(index)
+	v, err := tr.Get(key).Get()
+	if err != nil || v == nil {
+		return 0, "", err
+	}
+	t, err := tuple.Unpack(v)
+	if err != nil || len(t) != 2 {
+		return 0, "", &ForeignKeyError{Key: key, Reason: "malformed claim attempts"}
+	}
+	attempts, ok1 := t[0].(int64)
+	reason, ok2 := t[1].(string)
+	if !ok1 || !ok2 {
+		return 0, "", &ForeignKeyError{Key: key, Reason: "malformed claim attempts"}
+	}
+	return attempts, reason, nil
+}
+
+// Key of the claim count of index.
+func (vect *Vector) attemptsKey(index int64) fdb.Key {
+	return vect.subspace.Pack(tuple.Tuple{"attempts", index})
+}
+
+// Subspace receiving dead-lettered items.
+func (vect *Vector) deadLetterSpace() subspace.Subspace {
+	if vect.opts.DeadLetter != nil {
+		return vect.opts.DeadLetter
+	}
+	return vect.subspace.Sub("dead")
+}
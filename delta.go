@@ -0,0 +1,192 @@
+package vector
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+)
+
+/*
+ * DeltaVector - a vector of int64 stored chunkSize elements per key, each
+ * element as the varint difference from the one before it in its chunk.
+ * Vectors of timestamps, offsets or sequence numbers grow by small steps,
+ * so most elements take a byte or two instead of eight. Reads reconstruct
+ * the elements; the encoding is not visible.
+ *
+ * Key layout, in the vector's subspace:
+ *
+ *	(chunk number)   uvarint count, then the first element of the chunk
+ *	                 and the difference of each following one from its
+ *	                 predecessor, as varints
+ *	("meta", "keys") "delta<chunkSize>", see Init
+ *
+ * Any sequence can be stored, decreasing steps just take more bytes. As
+ * with ChunkedVector, elements never written read as 0 and writes to the
+ * same chunk conflict.
+ */
+type DeltaVector struct {
+	chunkLayout
+}
+
+// Create a DeltaVector storing its elements in subspace, chunkSize of them
+// per key; DefaultChunkSize when chunkSize is not positive. Every write
+// re-encodes its chunk, so smaller chunks suit vectors written at random
+// positions.
+func NewDeltaVector(subspace subspace.Subspace, chunkSize int) *DeltaVector {
+	return &DeltaVector{newChunkLayout(subspace, chunkSize, "delta")}
+}
+
+// Get the number of elements in the vector.
+func (dv *DeltaVector) Size(tr fdb.ReadTransaction) (int64, error) {
+	chunk, b, err := dv.lastChunk(tr)
+	if err != nil || b == nil {
+		return 0, err
+	}
+	n, err := deltaCount(b)
+	if err != nil {
+		return 0, err
+	}
+	return chunk*dv.chunkSize + int64(n), nil
+}
+
+// Get the element at index.
+func (dv *DeltaVector) Get(index int64, tr fdb.ReadTransaction) (int64, error) {
+	size, err := dv.Size(tr)
+	if err != nil {
+		return 0, err
+	}
+	if index < 0 || index >= size {
+		return 0, outOfRange("deltavector.get", index)
+	}
+	b, err := tr.Get(dv.chunkKey(index / dv.chunkSize)).Get()
+	if err != nil {
+		return 0, err
+	}
+	elems, err := decodeDeltas(b)
+	if err != nil {
+		return 0, err
+	}
+	if off := index % dv.chunkSize; off < int64(len(elems)) {
+		return elems[off], nil
+	}
+	return 0, nil
+}
+
+// Set the element at index. Setting past the end grows the vector, the
+// elements in between reading as 0.
+func (dv *DeltaVector) Set(index int64, val int64, tr fdb.Transaction) error {
+	if index < 0 {
+		return outOfRange("deltavector.set", index)
+	}
+	key := dv.chunkKey(index / dv.chunkSize)
+	b, err := tr.Get(key).Get()
+	if err != nil {
+		return err
+	}
+	elems, err := decodeDeltas(b)
+	if err != nil {
+		return err
+	}
+	off := index % dv.chunkSize
+	for int64(len(elems)) <= off {
+		elems = append(elems, 0)
+	}
+	elems[off] = val
+	tr.Set(key, encodeDeltas(elems))
+	return nil
+}
+
+// Push an element onto the end of the vector.
+func (dv *DeltaVector) Push(val int64, tr fdb.Transaction) error {
+	size, err := dv.Size(tr)
+	if err != nil {
+		return err
+	}
+	return dv.Set(size, val, tr)
+}
+
+// Get the elements in vro, which is resolved against the size like the
+// range of Vector.GetRange but must not have a negative Step. All chunks
+// of the range are fetched with a single range read.
+func (dv *DeltaVector) GetRange(vro VectRange, tr fdb.ReadTransaction) ([]int64, error) {
+	size, err := dv.Size(tr)
+	if err != nil {
+		return nil, err
+	}
+	vro, err = resolveChunked("deltavector.getrange", vro, size)
+	if err != nil {
+		return nil, err
+	}
+	if vro.Start >= vro.Stop {
+		return []int64{}, nil
+	}
+
+	vals := make([]int64, vro.Stop-vro.Start)
+	err = dv.readChunks(vro.Start, vro.Stop, tr, func(base int64, b []byte) error {
+		elems, err := decodeDeltas(b)
+		if err != nil {
+			return err
+		}
+		for i, v := range elems {
+			if index := base + int64(i); index >= vro.Start && index < vro.Stop {
+				vals[index-vro.Start] = v
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// Encode a chunk: the count, the first element and the deltas.
+func encodeDeltas(elems []int64) []byte {
+	b := make([]byte, 0, binary.MaxVarintLen64+2*len(elems))
+	var n [binary.MaxVarintLen64]byte
+	b = append(b, n[:binary.PutUvarint(n[:], uint64(len(elems)))]...)
+	var prev int64
+	for _, v := range elems {
+		b = append(b, n[:binary.PutVarint(n[:], v-prev)]...)
+		prev = v
+	}
+	return b
+}
+
+// The number of elements of an encoded chunk, without decoding them.
+func deltaCount(b []byte) (int, error) {
+	n, size := binary.Uvarint(b)
+	if size <= 0 {
+		return 0, fmt.Errorf("deltavector: bad chunk header")
+	}
+	return int(n), nil
+}
+
+// Decode a chunk written by encodeDeltas; nil decodes to no elements.
+func decodeDeltas(b []byte) ([]int64, error) {
+	if b == nil {
+		return nil, nil
+	}
+	n, size := binary.Uvarint(b)
+	if size <= 0 {
+		return nil, fmt.Errorf("deltavector: bad chunk header")
+	}
+	b = b[size:]
+	if n > uint64(len(b)) {
+		return nil, fmt.Errorf("deltavector: truncated chunk")
+	}
+	elems := make([]int64, 0, n)
+	var prev int64
+	for i := uint64(0); i < n; i++ {
+		d, size := binary.Varint(b)
+		if size <= 0 {
+			return nil, fmt.Errorf("deltavector: truncated chunk")
+		}
+		b = b[size:]
+		prev += d
+		elems = append(elems, prev)
+	}
+	return elems, nil
+}
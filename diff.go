@@ -0,0 +1,168 @@
+package vector
+
+import (
+	"bytes"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+/*
+ * Difference - an index at which two vectors differ. Old is the item of
+ * the first vector, New the item of the second; either is nil where that
+ * vector has no stored item.
+ */
+type Difference struct {
+	Index int64
+	Old   *Value
+	New   *Value
+
+	oldRaw []byte
+	newRaw []byte
+}
+
+// List the indexes at which the stored items of a and b differ, in index
+// order, streaming both vectors side by side. Items are compared in their
+// packed form without any timestamp header, so an item stored with the
+// default value differs from a sparse one, while the same value written at
+// different times (e.g. by a Replicator) does not differ.
+func Diff(a, b *Vector, tr fdb.ReadTransaction) ([]Difference, error) {
+	ia := a.storedItems(tr)
+	ib := b.storedItems(tr)
+	if err := ia.advance(); err != nil {
+		return nil, err
+	}
+	if err := ib.advance(); err != nil {
+		return nil, err
+	}
+
+	var diffs []Difference
+	for !ia.done || !ib.done {
+		var d Difference
+		switch {
+		case ib.done || (!ia.done && ia.index < ib.index):
+			d = Difference{Index: ia.index, oldRaw: ia.kv.Value}
+			if err := ia.advance(); err != nil {
+				return nil, err
+			}
+		case ia.done || ib.index < ia.index:
+			d = Difference{Index: ib.index, newRaw: ib.kv.Value}
+			if err := ib.advance(); err != nil {
+				return nil, err
+			}
+		default:
+			same := bytes.Equal(LazyValue{raw: ia.kv.Value}.body(), LazyValue{raw: ib.kv.Value}.body())
+			d = Difference{Index: ia.index, oldRaw: ia.kv.Value, newRaw: ib.kv.Value}
+			if err := ia.advance(); err != nil {
+				return nil, err
+			}
+			if err := ib.advance(); err != nil {
+				return nil, err
+			}
+			if same {
+				continue
+			}
+		}
+
+		var err error
+		if d.oldRaw != nil {
+			if d.Old, err = ValUnpack(d.oldRaw); err != nil {
+				return nil, err
+			}
+		}
+		if d.newRaw != nil {
+			if d.New, err = ValUnpack(d.newRaw); err != nil {
+				return nil, err
+			}
+		}
+		diffs = append(diffs, d)
+	}
+	return diffs, nil
+}
+
+// Cursor over the stored items of a vector, one item read ahead.
+type storedCursor struct {
+	vect  *Vector
+	ri    *fdb.RangeIterator
+	kv    fdb.KeyValue
+	index int64
+	done  bool
+}
+
+// Start a storedCursor over all stored items; call advance before use.
+func (vect *Vector) storedItems(tr fdb.ReadTransaction) *storedCursor {
+	ri := tr.GetRange(vect.indexRange(), fdb.RangeOptions{}).Iterator()
+	return &storedCursor{vect: vect, ri: ri}
+}
+
+// Move to the next stored item, setting done at the end.
+func (c *storedCursor) advance() error {
+	if !c.ri.Advance() {
+		c.done = true
+		return nil
+	}
+	kv, err := c.ri.Get()
+	if err != nil {
+		return err
+	}
+	c.kv = kv
+	c.index, err = c.vect.indexAt(kv.Key)
+	return err
+}
+
+// Merge the stored items of src into dest. Items stored only in src are
+// copied, items stored only in dest are kept, and where both have an item
+// resolver decides: its result is written to dest, or dest's item is kept
+// if it returns nil.
+func Merge(src, dest *Vector, resolver func(index int64, srcVal, destVal *Value) interface{}, tr fdb.Transaction) error {
+	if err := dest.mutation("Merge", -1, tr); err != nil {
+		return err
+	}
+	is := src.storedItems(tr)
+	id := dest.storedItems(tr)
+	if err := is.advance(); err != nil {
+		return err
+	}
+	if err := id.advance(); err != nil {
+		return err
+	}
+
+	for !is.done {
+		switch {
+		case id.done || is.index < id.index:
+			key, err := dest.prepareWrite("vector.merge", is.index, tr)
+			if err != nil {
+				return err
+			}
+			// without src's timestamp, dest stamps its own
+			if err := dest.setKey(key, LazyValue{raw: is.kv.Value}.body(), tr); err != nil {
+				return err
+			}
+		case id.index < is.index:
+			if err := id.advance(); err != nil {
+				return err
+			}
+			continue
+		default:
+			srcVal, err := ValUnpack(is.kv.Value)
+			if err != nil {
+				return err
+			}
+			destVal, err := ValUnpack(id.kv.Value)
+			if err != nil {
+				return err
+			}
+			if val := resolver(is.index, srcVal, destVal); val != nil {
+				if err := dest.Set(is.index, val, tr); err != nil {
+					return err
+				}
+			}
+			if err := id.advance(); err != nil {
+				return err
+			}
+		}
+		if err := is.advance(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+package vector
+
+import (
+	"bytes"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+// Whether a write of packed at index can leave the item sparse instead,
+// see Options.ElideDefault. The last item is always stored, since the size
+// of the vector is derived from it. Returns ErrNoDefaultFunc without a
+// DefaultFunc: sparse items would then read back as an untyped Value.
+func (vect *Vector) elides(index int64, packed []byte, tr fdb.Transaction) (bool, error) {
+	if vect.defaultFunc == nil {
+		return false, ErrNoDefaultFunc
+	}
+	def, err := vect.defaultPacked(index)
+	if err != nil || !bytes.Equal(def, packed) {
+		return false, err
+	}
+	size, err := vect.Size(tr)
+	if err != nil {
+		return false, err
+	}
+	return index < size-1, nil
+}
+
+// Clear the stored items in [start, stop) holding the vector's default
+// value, turning runs of it into sparse gaps that read back the same but
+// take no keys; the counterpart of Options.ElideDefault for items written
+// before it was set. The last item of the vector is kept. Returns the
+// number of items cleared. stop is clamped to the size of the vector, so
+// compact a large vector in slices, one transaction each. Like
+// ElideDefault it needs a DefaultFunc, and returns ErrNoDefaultFunc
+// without one.
+func (vect *Vector) CompactDefaults(start, stop int64, tr fdb.Transaction) (_ int64, err error) {
+	defer func() { err = vect.opError("CompactDefaults", -1, err) }()
+
+	if vect.defaultFunc == nil {
+		return 0, ErrNoDefaultFunc
+	}
+	if err := vect.mutation("CompactDefaults", start, tr); err != nil {
+		return 0, err
+	}
+	if start < 0 {
+		return 0, outOfRange("vector.compactdefaults", start)
+	}
+
+	size, err := vect.Size(tr)
+	if err != nil {
+		return 0, err
+	}
+	if stop > size-1 {
+		stop = size - 1
+	}
+	if start >= stop {
+		return 0, nil
+	}
+
+	vi, err := vect.getRange(VectRange{Start: start, Stop: stop}, tr)
+	if err != nil {
+		return 0, err
+	}
+	var cleared int64
+	for vi.Advance() {
+		lv, err := vi.GetLazy()
+		if err != nil {
+			return cleared, err
+		}
+		def, err := vect.defaultPacked(lv.Index)
+		if err != nil {
+			return cleared, err
+		}
+		if !bytes.Equal(lv.body(), def) {
+			continue
+		}
+		vect.aggregate(lv.raw, nil, tr)
+		tr.Clear(vi.kv.Key)
+		cleared++
+	}
+	return cleared, nil
+}
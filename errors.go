@@ -0,0 +1,152 @@
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+var (
+	// An index below 0 or at/after the end of the vector.
+	ErrIndexOutOfRange = errors.New("out of range")
+
+	// Set in Dense mode would leave a sparse gap.
+	ErrSparseWrite = errors.New("write would create a sparse gap")
+
+	// A step of an Atomic uses a vector opened against another database.
+	ErrDatabaseMismatch = errors.New("vector belongs to a different database")
+
+	// The method needs an Options field the vector was not opened with.
+	ErrNotEnabled = errors.New("option not enabled on this vector")
+
+	// The directory is a partition root, which cannot hold keys itself;
+	// open a vector inside the partition instead.
+	ErrPartitionRoot = errors.New("cannot store a vector at the root of a directory partition")
+
+	// The handle's configuration disagrees with the vector's metadata.
+	ErrMetadataMismatch = errors.New("vector metadata mismatch")
+
+	// The fdb bindings in use do not provide the operation.
+	ErrUnsupported = errors.New("operation not supported by the fdb bindings in use")
+
+	// Unlock of an element locked by another owner.
+	ErrNotLockOwner = errors.New("element is locked by another owner")
+
+	// A backup chunk failed its checksum or is malformed.
+	ErrCorruptChunk = errors.New("corrupt backup chunk")
+
+	// The vector does not hold the old value a patch expects.
+	ErrPatchConflict = errors.New("patch does not apply")
+
+	// A write through a Namespace would take the tenant over its Quota.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+
+	// No schema of that name is registered.
+	ErrUnknownSchema = errors.New("unknown schema")
+
+	// ElideDefault or CompactDefaults on a vector without a DefaultFunc,
+	// whose sparse items would not read back as the default.
+	ErrNoDefaultFunc = errors.New("eliding defaults requires a DefaultFunc")
+
+	// A write breaks a constraint of the vector's schema.
+	ErrSchemaViolation = errors.New("schema violation")
+
+	// Matches any *TypeMismatchError with errors.Is.
+	ErrTypeMismatch = errors.New("element type does not match the vector")
+
+	// Matches any *ReplicaConflictError with errors.Is.
+	ErrReplicaConflict = errors.New("replicated change conflicts with the destination")
+
+	// Matches any *ForeignKeyError with errors.Is.
+	ErrForeignKey = errors.New("foreign key in vector subspace")
+)
+
+/*
+ * ForeignKeyError - a key inside the vector's subspace that does not decode
+ * to an index, e.g. written by another layer or by hand.
+ */
+type ForeignKeyError struct {
+	Key    fdb.Key
+	Reason string
+}
+
+func (e *ForeignKeyError) Error() string {
+	return fmt.Sprintf("vector: foreign key %s in subspace: %s", fdb.Printable(e.Key), e.Reason)
+}
+
+func (e *ForeignKeyError) Is(target error) bool {
+	return target == ErrForeignKey
+}
+
+/*
+ * TypeMismatchError - a write to a vector with Options.Homogeneous of a
+ * value whose type (its ValPack typecode) differs from the one recorded
+ * for the vector.
+ */
+type TypeMismatchError struct {
+	Index int64
+	Want  string
+	Got   string
+}
+
+func (e *TypeMismatchError) Error() string {
+	return fmt.Sprintf("vector: %s written at index %d of a %s vector", e.Got, e.Index, e.Want)
+}
+
+func (e *TypeMismatchError) Is(target error) bool {
+	return target == ErrTypeMismatch
+}
+
+/*
+ * OpError - an error of a Vector method with the context it failed in:
+ * the method, the directory path of the vector (nil when it was not
+ * opened through the directory layer) and the index, -1 if there is
+ * none. Use errors.Is and errors.As to look at the wrapped error.
+ *
+ * Errors of fdb itself (fdb.Error) are returned as they are, so the retry
+ * loops of Transact and RunWithRetry keep recognizing them.
+ */
+type OpError struct {
+	Op    string
+	Path  []string
+	Index int64
+	Err   error
+}
+
+func (e *OpError) Error() string {
+	msg := "vector " + e.Op
+	if e.Path != nil {
+		msg += " /" + strings.Join(e.Path, "/")
+	}
+	if e.Index >= 0 {
+		msg += fmt.Sprintf(" index %d", e.Index)
+	}
+	return msg + ": " + e.Err.Error()
+}
+
+func (e *OpError) Unwrap() error {
+	return e.Err
+}
+
+// Wrap err of op at index in an *OpError, unless it is nil, an fdb.Error
+// or already wrapped.
+func (vect *Vector) opError(op string, index int64, err error) error {
+	if err == nil {
+		return nil
+	}
+	if _, ok := err.(fdb.Error); ok {
+		return err
+	}
+	var oe *OpError
+	if errors.As(err, &oe) {
+		return err
+	}
+	return &OpError{Op: op, Path: vect.path(), Index: index, Err: err}
+}
+
+// Error for index being out of range in op, matching ErrIndexOutOfRange.
+func outOfRange(op string, index int64) error {
+	return fmt.Errorf("%s: index '%d' %w", op, index, ErrIndexOutOfRange)
+}
@@ -0,0 +1,120 @@
+package vector
+
+import (
+	"context"
+	"time"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+/*
+ * Sink - destination of an Exporter, e.g. a Kafka producer. Deliver gets
+ * the records in feed order and must only return nil once they are
+ * durably delivered. A batch may be delivered again after a failure.
+ */
+type Sink interface {
+	Deliver(ctx context.Context, records []ChangeRecord) error
+}
+
+/*
+ * Exporter - tails the change feed of a vector with Options.ChangeFeed and
+ * delivers the records to a Sink, at least once. The position of the last
+ * delivered record is checkpointed in the vector under ("export", name),
+ * so a restarted Exporter with the same name carries on where it stopped.
+ */
+type Exporter struct {
+	BatchSize    int           // records per Deliver call, default 500
+	PollInterval time.Duration // wait when the feed is drained, default 1s
+
+	vect *Vector
+	db   fdb.Transactor
+	name string
+	sink Sink
+}
+
+// Create an Exporter named name delivering the change feed of vect to sink.
+func NewExporter(vect *Vector, db fdb.Transactor, name string, sink Sink) *Exporter {
+	return &Exporter{vect: vect, db: db, name: name, sink: sink}
+}
+
+// Deliver batches until ctx is done or delivery fails, polling the feed
+// whenever it is drained.
+func (e *Exporter) Run(ctx context.Context) error {
+	poll := e.PollInterval
+	if poll <= 0 {
+		poll = time.Second
+	}
+
+	for {
+		n, err := e.Step(ctx)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+}
+
+// Deliver the next batch of records after the checkpoint and advance the
+// checkpoint past them. Returns the number of records delivered, 0 when
+// the feed is drained.
+func (e *Exporter) Step(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	limit := e.BatchSize
+	if limit <= 0 {
+		limit = 500
+	}
+
+	r, err := e.db.ReadTransact(func(tr fdb.ReadTransaction) (interface{}, error) {
+		after, err := tr.Get(e.checkpointKey()).Get()
+		if err != nil {
+			return nil, err
+		}
+		return e.vect.ReadChanges(after, limit, tr)
+	})
+	if err != nil {
+		return 0, err
+	}
+	records := r.([]ChangeRecord)
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	if err := e.sink.Deliver(ctx, records); err != nil {
+		return 0, err
+	}
+
+	_, err = e.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.Set(e.checkpointKey(), records[len(records)-1].Position())
+		return nil, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(records), nil
+}
+
+// Get the versionstamp of the last delivered record, zero if none was.
+func (e *Exporter) Checkpoint(tr fdb.ReadTransaction) (Versionstamp, error) {
+	var vs Versionstamp
+	pos, err := tr.Get(e.checkpointKey()).Get()
+	if err != nil {
+		return vs, err
+	}
+	copy(vs[:], pos)
+	return vs, nil
+}
+
+// Key holding the position of the last delivered record.
+func (e *Exporter) checkpointKey() fdb.Key {
+	return e.vect.subspace.Pack(tuple.Tuple{"export", e.name})
+}
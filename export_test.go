@@ -0,0 +1,134 @@
+package vector
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+type sliceSink struct {
+	records []ChangeRecord
+}
+
+func (s *sliceSink) Deliver(ctx context.Context, records []ChangeRecord) error {
+	s.records = append(s.records, records...)
+	return nil
+}
+
+func TestExporter(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	vect, err := Open(db, []string{"tests", "export"}, "")
+	if err != nil {
+		panic(err)
+	}
+	vect = vect.WithOptions(Options{ChangeFeed: true})
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.ClearRange(vect.subspace)
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	for _, v := range []string{"a", "b", "c"} {
+		_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			return nil, vect.Push(v, tr)
+		})
+		if e != nil {
+			t.Fatal(e)
+		}
+	}
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		_, err := vect.Pop(tr)
+		return nil, err
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	sink := &sliceSink{}
+	exp := NewExporter(vect, db, "kafka", sink)
+	exp.BatchSize = 2
+	for {
+		n, err := exp.Step(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n == 0 {
+			break
+		}
+	}
+
+	if len(sink.records) != 4 {
+		t.Fatalf("Expected 4 change records, got %d instead", len(sink.records))
+	}
+	if sink.records[1].Index != 1 || sink.records[1].Value.String != "b" {
+		t.Errorf("Expected push of 'b' at 1, got %+v instead", sink.records[1])
+	}
+	if sink.records[3].Index != 2 || sink.records[3].Value != nil {
+		t.Errorf("Expected removal at 2, got %+v instead", sink.records[3])
+	}
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vs, err := exp.Checkpoint(tr)
+		if err != nil {
+			return nil, err
+		}
+		if vs != sink.records[3].Version {
+			return nil, fmt.Errorf("Expected checkpoint %s, got %s instead", sink.records[3].Version, vs)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestChangeFeedOrder(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	vect, err := Open(db, []string{"tests", "export"}, "")
+	if err != nil {
+		panic(err)
+	}
+	vect = vect.WithOptions(Options{ChangeFeed: true})
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.ClearRange(vect.subspace)
+		vect.Push("a", tr)
+		vect.Clear(tr)
+		return nil, vect.Push("b", tr)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		records, err := vect.ReadChanges(nil, 0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(records) != 3 {
+			return nil, fmt.Errorf("Expected 3 change records, got %d instead", len(records))
+		}
+		if records[0].Cleared || records[0].Value.String != "a" {
+			return nil, fmt.Errorf("Expected push of 'a' first, got %+v instead", records[0])
+		}
+		if !records[1].Cleared {
+			return nil, fmt.Errorf("Expected the clear second, got %+v instead", records[1])
+		}
+		if records[2].Cleared || records[2].Value.String != "b" {
+			return nil, fmt.Errorf("Expected push of 'b' last, got %+v instead", records[2])
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
@@ -0,0 +1,156 @@
+package vector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync/atomic"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+// Orders the change records written by this process within a transaction.
+var feedSeq int64
+
+/*
+ * ChangeRecord - one change recorded in the change feed of a vector with
+ * Options.ChangeFeed. Value is the value written to Index, nil if the item
+ * was removed. Cleared marks a Clear of the whole vector; Index and Value
+ * are unset then.
+ */
+type ChangeRecord struct {
+	Version Versionstamp
+	Index   int64
+	Value   *Value
+	Cleared bool
+
+	key []byte // feed key suffix, the position of the record in the feed
+}
+
+// Read up to limit change records recorded after the record at position
+// after (nil for the start of the feed), oldest first. limit 0 reads all.
+func (vect *Vector) ReadChanges(after []byte, limit int, tr fdb.ReadTransaction) (_ []ChangeRecord, err error) {
+	defer func() { err = vect.opError("ReadChanges", -1, err) }()
+
+	prefix := vect.feedPrefix()
+	kr, err := fdb.PrefixRange(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if after != nil {
+		// first key after prefix+after
+		kr.Begin = fdb.Key(append(append(append([]byte{}, prefix...), after...), 0x00))
+	}
+
+	kvs, err := tr.GetRange(kr, fdb.RangeOptions{Limit: limit}).GetSliceWithError()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]ChangeRecord, 0, len(kvs))
+	for _, kv := range kvs {
+		rec, err := vect.changeRecord(kv)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// Remove the change records of transactions committed before version.
+func (vect *Vector) TrimChanges(version Versionstamp, tr fdb.Transaction) {
+	prefix := vect.feedPrefix()
+	end := append(append([]byte{}, prefix...), version[:]...)
+	tr.ClearRange(fdb.KeyRange{Begin: fdb.Key(prefix), End: fdb.Key(end)})
+}
+
+// Position of the last record in the feed, nil if it is empty.
+func (vect *Vector) lastChange(tr fdb.ReadTransaction) ([]byte, error) {
+	prefix := vect.feedPrefix()
+	kr, err := fdb.PrefixRange(prefix)
+	if err != nil {
+		return nil, err
+	}
+	kvs, err := tr.GetRange(kr, fdb.RangeOptions{Limit: 1, Reverse: true}).GetSliceWithError()
+	if err != nil || len(kvs) == 0 {
+		return nil, err
+	}
+	return append([]byte{}, kvs[0].Key[len(prefix):]...), nil
+}
+
+// Position of the record in the feed, to resume ReadChanges after it.
+func (rec ChangeRecord) Position() []byte {
+	return rec.key
+}
+
+// Record in the change feed that the transaction stores packed (nil when
+// removing) at index. The key is the transaction's versionstamp followed by
+// a sequence number and the index, so every write is kept and the records
+// of a transaction replay in the order it made them.
+func (vect *Vector) writeChange(index int64, packed []byte, tr fdb.Transaction) {
+	vect.writeFeedKey(index, packed, tr)
+}
+
+// Record in the change feed that the transaction clears the vector.
+func (vect *Vector) writeClearChange(tr fdb.Transaction) {
+	vect.writeFeedKey(nil, nil, tr)
+}
+
+// Record a change to index, an int64 or nil for a Clear.
+func (vect *Vector) writeFeedKey(index interface{}, packed []byte, tr fdb.Transaction) {
+	prefix := vect.feedPrefix()
+	suffix := tuple.Tuple{atomic.AddInt64(&feedSeq, 1), index}.Pack()
+
+	// key with a placeholder for the versionstamp, the suffix, and the
+	// little-endian offset of the placeholder
+	key := make([]byte, len(prefix)+len(Versionstamp{})+len(suffix)+2)
+	copy(key, prefix)
+	copy(key[len(prefix)+len(Versionstamp{}):], suffix)
+	binary.LittleEndian.PutUint16(key[len(key)-2:], uint16(len(prefix)))
+
+	if packed == nil {
+		packed = []byte{}
+	}
+	tr.SetVersionstampedKey(fdb.Key(key), packed)
+}
+
+// Decode a change feed key value pair.
+func (vect *Vector) changeRecord(kv fdb.KeyValue) (ChangeRecord, error) {
+	var rec ChangeRecord
+	prefix := vect.feedPrefix()
+	if len(kv.Key) <= len(prefix)+len(rec.Version) || !bytes.HasPrefix(kv.Key, prefix) {
+		return rec, &ForeignKeyError{Key: kv.Key, Reason: "malformed change feed key"}
+	}
+	rec.key = append([]byte{}, kv.Key[len(prefix):]...)
+	copy(rec.Version[:], rec.key)
+
+	t, err := tuple.Unpack(rec.key[len(rec.Version):])
+	if err != nil || len(t) != 2 {
+		return rec, &ForeignKeyError{Key: kv.Key, Reason: "malformed change feed key"}
+	}
+	switch index := t[1].(type) {
+	case nil:
+		rec.Cleared = true
+		return rec, nil
+	case int64:
+		rec.Index = index
+	default:
+		return rec, &ForeignKeyError{Key: kv.Key, Reason: "malformed change feed key"}
+	}
+
+	// empty value marks a removed item
+	if len(kv.Value) > 0 {
+		val, err := ValUnpack(kv.Value)
+		if err != nil {
+			return rec, err
+		}
+		rec.Value = val
+	}
+	return rec, nil
+}
+
+// Prefix of the change feed keys.
+func (vect *Vector) feedPrefix() []byte {
+	return vect.subspace.Pack(tuple.Tuple{"feed"})
+}
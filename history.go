@@ -0,0 +1,247 @@
+package vector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"sync/atomic"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+// Orders the history records written by this process within a transaction.
+var historySeq int64
+
+/*
+ * Versionstamp - the 10 byte commit version + batch order FDB assigns to a
+ * committed transaction. Versionstamps sort in commit order.
+ */
+type Versionstamp [10]byte
+
+func (vs Versionstamp) String() string {
+	return hex.EncodeToString(vs[:])
+}
+
+/*
+ * HistoryRecord - one write to an item recorded with Options.History: the
+ * transaction that made it and the value it replaced. Old is nil when the
+ * item had no stored value before.
+ */
+type HistoryRecord struct {
+	Index   int64
+	Version Versionstamp
+	Old     *Value
+}
+
+// Return the recorded writes to index, oldest first. A transaction that
+// wrote index several times has one record per write, in write order.
+func (vect *Vector) GetHistory(index int64, tr fdb.ReadTransaction) (_ []HistoryRecord, err error) {
+	defer func() { err = vect.opError("GetHistory", index, err) }()
+
+	kr, err := fdb.PrefixRange(vect.historyPrefix(index))
+	if err != nil {
+		return nil, err
+	}
+	kvs, err := tr.GetRange(kr, fdb.RangeOptions{}).GetSliceWithError()
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]HistoryRecord, 0, len(kvs))
+	for _, kv := range kvs {
+		rec, err := vect.historyRecord(index, kv)
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rec)
+	}
+	return records, nil
+}
+
+// Get the item at index as it was just before the transaction with
+// versionstamp version committed. Uses the current value when nothing has
+// been written to index since. An item that had no stored value then is
+// returned with Origin OriginMissing.
+func (vect *Vector) GetAt(index int64, version Versionstamp, tr fdb.ReadTransaction) (_ *Value, err error) {
+	defer func() { err = vect.opError("GetAt", index, err) }()
+
+	prefix := vect.historyPrefix(index)
+	kr, err := fdb.PrefixRange(prefix)
+	if err != nil {
+		return nil, err
+	}
+	// first record of the transaction at version or later
+	kr.Begin = fdb.Key(append(append([]byte{}, prefix...), version[:]...))
+
+	next, err := tr.GetRange(kr, fdb.RangeOptions{Limit: 1}).GetSliceWithError()
+	if err != nil {
+		return nil, err
+	}
+	if len(next) == 0 {
+		return vect.Get(index, tr)
+	}
+
+	rec, err := vect.historyRecord(index, next[0])
+	if err != nil {
+		return nil, err
+	}
+	if rec.Old == nil {
+		return &Value{Origin: OriginMissing}, nil
+	}
+	return rec.Old, nil
+}
+
+// Record that the transaction replaces old (nil if absent) at index. The
+// key is completed with the transaction's versionstamp at commit and
+// followed by a sequence number, so the first record of a transaction
+// holds the value the item had before it.
+func (vect *Vector) writeHistory(index int64, old []byte, tr fdb.Transaction) {
+	prefix := vect.historyPrefix(index)
+	suffix := tuple.Tuple{atomic.AddInt64(&historySeq, 1)}.Pack()
+
+	// key with a placeholder for the versionstamp, the sequence number,
+	// and the little-endian offset of the placeholder
+	key := make([]byte, len(prefix)+len(Versionstamp{})+len(suffix)+2)
+	copy(key, prefix)
+	copy(key[len(prefix)+len(Versionstamp{}):], suffix)
+	binary.LittleEndian.PutUint16(key[len(key)-2:], uint16(len(prefix)))
+
+	if old == nil {
+		old = []byte{}
+	}
+	tr.SetVersionstampedKey(fdb.Key(key), old)
+}
+
+// Decode a history key value pair of index.
+func (vect *Vector) historyRecord(index int64, kv fdb.KeyValue) (HistoryRecord, error) {
+	rec := HistoryRecord{Index: index}
+	prefix := vect.historyPrefix(index)
+	if len(kv.Key) <= len(prefix)+len(rec.Version) || !bytes.HasPrefix(kv.Key, prefix) {
+		return rec, &ForeignKeyError{Key: kv.Key, Reason: "malformed history key"}
+	}
+	copy(rec.Version[:], kv.Key[len(prefix):])
+
+	// empty value marks an item that was absent
+	if len(kv.Value) > 0 {
+		old, err := ValUnpack(kv.Value)
+		if err != nil {
+			return rec, err
+		}
+		rec.Old = old
+	}
+	return rec, nil
+}
+
+// Decode the index of a history key. The versionstamp following it is not
+// a tuple element, so the key cannot be unpacked as a whole.
+func (vect *Vector) historyIndex(key fdb.Key) (int64, bool) {
+	prefix := vect.subspace.Pack(tuple.Tuple{"history"})
+	if len(key) <= len(prefix) || !bytes.HasPrefix(key, prefix) {
+		return 0, false
+	}
+	enc := key[len(prefix):]
+
+	// 0x14 is zero, 0x14+n a positive integer of n big-endian bytes
+	n := int(enc[0]) - 0x14
+	if n < 0 || n > 8 || len(enc) < 1+n || (n == 8 && enc[1]&0x80 != 0) {
+		return 0, false
+	}
+	var index int64
+	for _, c := range enc[1 : 1+n] {
+		index = index<<8 | int64(c)
+	}
+	return index, true
+}
+
+// Prefix of the history keys of index.
+func (vect *Vector) historyPrefix(index int64) []byte {
+	return vect.subspace.Pack(tuple.Tuple{"history", index})
+}
+
+// Restore every item written by the transaction with versionstamp version
+// or later to the value it had just before that transaction, by applying
+// the inverse of the recorded writes. The restoring writes are recorded in
+// the history themselves.
+//
+// Only writes recorded with Options.History can be undone; in particular
+// Clear and the atomic mutations are not. The whole history is scanned in
+// tr, so very long histories need to be pruned first.
+func (vect *Vector) RollbackTo(version Versionstamp, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("RollbackTo", -1, err) }()
+
+	if err := vect.mutation("RollbackTo", -1, tr); err != nil {
+		return err
+	}
+	kr, err := fdb.PrefixRange(vect.subspace.Pack(tuple.Tuple{"history"}))
+	if err != nil {
+		return err
+	}
+
+	ri := tr.GetRange(kr, fdb.RangeOptions{}).Iterator()
+	done := int64(-1) // last index restored, its later records are skipped
+	for ri.Advance() {
+		kv, err := ri.Get()
+		if err != nil {
+			return err
+		}
+		index, ok := vect.historyIndex(kv.Key)
+		if !ok {
+			return &ForeignKeyError{Key: kv.Key, Reason: "malformed history key"}
+		}
+		if index == done {
+			continue
+		}
+
+		rec, err := vect.historyRecord(index, kv)
+		if err != nil {
+			return err
+		}
+		if bytes.Compare(rec.Version[:], version[:]) < 0 {
+			continue
+		}
+
+		// first write at or after version: its old value is the one to restore
+		if err := vect.restore(index, kv.Value, tr); err != nil {
+			return err
+		}
+		done = index
+	}
+
+	if vect.opts.AtomicPush {
+		size, err := vect.Size(tr)
+		if err != nil {
+			return err
+		}
+		tr.Set(vect.sizeKey(), packCounter(size))
+	}
+	return nil
+}
+
+// Put the packed value old (empty for absent) back at index.
+func (vect *Vector) restore(index int64, old []byte, tr fdb.Transaction) error {
+	key := vect.keyAt(index)
+	if len(old) > 0 {
+		// history keeps the stored bytes; setKey stamps them afresh
+		return vect.setKey(key, LazyValue{raw: old}.body(), tr)
+	}
+
+	if vect.opts.History || vect.opts.Aggregates {
+		cur, err := tr.Get(key).Get()
+		if err != nil {
+			return err
+		}
+		if cur == nil {
+			return nil
+		}
+		if vect.opts.History {
+			vect.writeHistory(index, cur, tr)
+		}
+		vect.aggregate(cur, nil, tr)
+	}
+	if vect.opts.ChangeFeed {
+		vect.writeChange(index, nil, tr)
+	}
+	tr.Clear(key)
+	return nil
+}
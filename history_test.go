@@ -0,0 +1,239 @@
+package vector
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+func TestHistory(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	vector, err := Open(db, []string{"tests", "history"}, "")
+	if err != nil {
+		panic(err)
+	}
+	vector = vector.WithOptions(Options{History: true})
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.ClearRange(vector.subspace)
+		return nil, vector.Set(0, "a", tr)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		return nil, vector.Set(0, "b", tr)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		records, err := vector.GetHistory(0, tr)
+		if err != nil {
+			return nil, fmt.Errorf("GetHistory returned error: %s", err)
+		}
+		if len(records) != 2 {
+			return nil, fmt.Errorf("Expected 2 history records, got %d instead", len(records))
+		}
+		if records[0].Old != nil {
+			return nil, fmt.Errorf("Expected first write to replace nothing, got %v", records[0].Old)
+		}
+		if records[1].Old == nil || records[1].Old.String != "a" {
+			return nil, fmt.Errorf("Expected second write to replace 'a', got %v", records[1].Old)
+		}
+
+		val, err := vector.GetAt(0, records[1].Version, tr)
+		if err != nil {
+			return nil, fmt.Errorf("GetAt returned error: %s", err)
+		}
+		if val.String != "a" {
+			return nil, fmt.Errorf("Expected 'a' before second write, got %s instead", val.String)
+		}
+
+		val, err = vector.GetAt(0, records[0].Version, tr)
+		if err != nil {
+			return nil, fmt.Errorf("GetAt returned error: %s", err)
+		}
+		if val.Origin != OriginMissing {
+			return nil, fmt.Errorf("Expected nothing before first write, got %v", val)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestRollbackTo(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	vector, err := Open(db, []string{"tests", "rollback"}, "")
+	if err != nil {
+		panic(err)
+	}
+	vector = vector.WithOptions(Options{History: true})
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.ClearRange(vector.subspace)
+		vector.Push("a", tr)
+		return nil, vector.Push("b", tr)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	// a bad batch job
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Set(0, "bad", tr)
+		return nil, vector.Push("junk", tr)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		records, err := vector.GetHistory(0, tr)
+		if err != nil {
+			return nil, err
+		}
+		// roll back to just before the bad job
+		if err := vector.RollbackTo(records[len(records)-1].Version, tr); err != nil {
+			return nil, fmt.Errorf("RollbackTo returned error: %s", err)
+		}
+
+		size, err := vector.Size(tr)
+		if err != nil {
+			return nil, err
+		}
+		if size != 2 {
+			return nil, fmt.Errorf("Expected vector to be size 2 after rollback, got %d instead", size)
+		}
+		val, err := vector.Get(0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.String != "a" {
+			return nil, fmt.Errorf("Expected 'a' after rollback, got %s instead", val.String)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestRollbackToTimestamps(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	vector, err := Open(db, []string{"tests", "rollback"}, "")
+	if err != nil {
+		panic(err)
+	}
+	vector = vector.WithOptions(Options{History: true, Timestamps: true, Homogeneous: true})
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.ClearRange(vector.subspace)
+		return nil, vector.Push(1, tr)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		return nil, vector.Set(0, 2, tr)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		records, err := vector.GetHistory(0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if err := vector.RollbackTo(records[len(records)-1].Version, tr); err != nil {
+			return nil, fmt.Errorf("RollbackTo returned error: %s", err)
+		}
+
+		raw, err := tr.Get(vector.keyAt(0)).Get()
+		if err != nil {
+			return nil, err
+		}
+		lv := LazyValue{raw: raw}
+		if !lv.IsInt() || len(raw) < 10 || raw[9] == 0x05 {
+			return nil, fmt.Errorf("Expected a singly stamped int after rollback, got % x", raw)
+		}
+		val, err := vector.Get(0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.Int != 1 {
+			return nil, fmt.Errorf("Expected 1 after rollback, got %v instead", val)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestHistorySameTransaction(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	vector, err := Open(db, []string{"tests", "history"}, "")
+	if err != nil {
+		panic(err)
+	}
+	vector = vector.WithOptions(Options{History: true})
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.ClearRange(vector.subspace)
+		return nil, vector.Set(0, "a", tr)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		if err := vector.Set(0, "b", tr); err != nil {
+			return nil, err
+		}
+		return nil, vector.Set(0, "c", tr)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		records, err := vector.GetHistory(0, tr)
+		if err != nil {
+			return nil, fmt.Errorf("GetHistory returned error: %s", err)
+		}
+		if len(records) != 3 {
+			return nil, fmt.Errorf("Expected 3 history records, got %d instead", len(records))
+		}
+		if records[1].Version != records[2].Version {
+			return nil, fmt.Errorf("Expected the last two writes to share a versionstamp")
+		}
+		if records[2].Old == nil || records[2].Old.String != "b" {
+			return nil, fmt.Errorf("Expected third write to replace 'b', got %v", records[2].Old)
+		}
+
+		val, err := vector.GetAt(0, records[1].Version, tr)
+		if err != nil {
+			return nil, fmt.Errorf("GetAt returned error: %s", err)
+		}
+		if val.String != "a" {
+			return nil, fmt.Errorf("Expected 'a' before the transaction, got %s instead", val.String)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
@@ -0,0 +1,124 @@
+package vector
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+/*
+ * OpInfo - an operation on a Vector as seen by Hooks. Index is -1 for
+ * operations without one, Range is set for scans. Bytes is the size of the packed values written
+ * or read, known once the operation is done, as are Duration and Err.
+ */
+type OpInfo struct {
+	Op       string // method name, e.g. "Set"
+	Index    int64
+	Range    VectRange
+	Bytes    int
+	Duration time.Duration
+	Err      error
+
+	start time.Time
+}
+
+/*
+ * Hooks - callbacks run around the Set, Get, GetRange, Push, Pop, PopMany, Drain,
+ * Filter and CountWhere methods of a Vector, applied with WithHooks. OnBeforeOp can veto the
+ * operation by returning an error, which the method then returns without
+ * doing anything. Either hook may be nil. For GetRange OnAfterOp runs once
+ * the iterator is exhausted or closed.
+ */
+type Hooks struct {
+	OnBeforeOp func(info *OpInfo) error
+	OnAfterOp  func(info *OpInfo)
+}
+
+/*
+ * Logger - where slow operations are logged; *log.Logger satisfies it.
+ */
+type Logger interface {
+	Printf(format string, args ...interface{})
+}
+
+// Hooks logging every operation that takes threshold or longer to l, with
+// its index or range, bytes moved and error.
+func SlowOpHooks(l Logger, threshold time.Duration) Hooks {
+	return Hooks{
+		OnAfterOp: func(info *OpInfo) {
+			if info.Duration < threshold {
+				return
+			}
+			target := fmt.Sprintf("index %d", info.Index)
+			if info.Index < 0 {
+				target = fmt.Sprintf("range %d:%d:%d", info.Range.Start, info.Range.Stop, info.Range.Step)
+			}
+			l.Printf("vector: slow %s (%s, %d bytes) took %s, err: %v", info.Op, target, info.Bytes, info.Duration, info.Err)
+		},
+	}
+}
+
+// Return a copy of the Vector running hooks around its operations.
+func (vect *Vector) WithHooks(hooks Hooks) *Vector {
+	v := *vect
+	v.hooks = hooks
+	return &v
+}
+
+// Run the OnBeforeOp hook for op. The returned OpInfo goes to afterOp.
+func (vect *Vector) beforeOp(op string, index int64) (*OpInfo, error) {
+	return vect.startOp(OpInfo{Op: op, Index: index})
+}
+
+// Like beforeOp, for an operation writing to the vector in tr: the
+// mutation is authorized first and audited once the hook lets it through.
+func (vect *Vector) beforeWrite(op string, index int64, tr fdb.Transaction) (*OpInfo, error) {
+	if err := vect.Authorize(op, index); err != nil {
+		return nil, err
+	}
+	info, err := vect.beforeOp(op, index)
+	if err != nil {
+		return nil, err
+	}
+	vect.audit(op, index, tr)
+	vect.notify(tr)
+	return info, nil
+}
+
+// Like beforeOp, for a scan over vro.
+func (vect *Vector) beforeScan(op string, vro VectRange) (*OpInfo, error) {
+	return vect.startOp(OpInfo{Op: op, Index: -1, Range: vro})
+}
+
+func (vect *Vector) startOp(op OpInfo) (*OpInfo, error) {
+	info := &op
+	if vect.hooks.OnBeforeOp == nil && vect.hooks.OnAfterOp == nil {
+		return info, nil
+	}
+	info.start = time.Now()
+	if vect.hooks.OnBeforeOp != nil {
+		if err := vect.hooks.OnBeforeOp(info); err != nil {
+			return nil, err
+		}
+	}
+	return info, nil
+}
+
+// Wrap the error of the operation started with beforeOp in an *OpError
+// and run the OnAfterOp hook. Meant to be deferred with a pointer to the
+// method's named error result.
+func (vect *Vector) afterOp(info *OpInfo, err *error) {
+	*err = vect.opError(info.Op, info.Index, *err)
+	if vect.hooks.OnAfterOp == nil {
+		return
+	}
+	info.Duration = time.Since(info.start)
+	info.Err = *err
+	vect.hooks.OnAfterOp(info)
+}
+
+// Record n bytes moved by the operation.
+func (info *OpInfo) addBytes(n int) {
+	info.Bytes += n
+}
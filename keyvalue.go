@@ -1,11 +1,44 @@
 package vector
 
 import (
-	"bytes"
+	"encoding"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"time"
 )
 
+/*
+ * Counter - an int64 stored in the little-endian layout FDB's atomic ADD
+ * mutation works on, so it can be changed with Vector.Add. It reads back
+ * as an ordinary int (IsInt).
+ */
+type Counter int64
+
+const signBit = 1 << 63
+
+/*
+ * Ordered - an int64 stored so that FDB's atomic MIN/MAX mutations order it
+ * correctly: little-endian with the sign bit flipped, which compares as an
+ * unsigned integer in signed order. Used with Vector.AtomicMin/AtomicMax;
+ * it reads back as an ordinary int (IsInt).
+ */
+type Ordered int64
+
+/*
+ * Raw - an already packed value, stored verbatim by Set and Push. Used to
+ * copy items whose typecode this version does not know, see
+ * PassUnknownTypecodes.
+ */
+type Raw []byte
+
+// Decode values with typecodes this version does not know as raw values
+// (IsRaw, with the packed bytes in Bytes) instead of failing, so readers
+// keep working while newer writers add types. Set it once at startup.
+var PassUnknownTypecodes bool
+
 type IndexValue struct {
 	Index int64
 	Value *Value
@@ -21,67 +54,302 @@ type Value struct {
 	IsFloat  bool
 	IsInt    bool
 	IsString bool
+	IsBinary bool // packed from an encoding.BinaryMarshaler
+	IsRaw    bool // unknown typecode, see PassUnknownTypecodes
 	Float    float64
 	Int      int64
 	String   string
+	Bytes    []byte // marshaled form, see UnmarshalInto; the packed value if IsRaw
+	Raw      []byte // the packed value as stored, for SetRaw
+	Origin   Origin
+	Modified time.Time // last write, when stored with Options.Timestamps
+}
+
+/*
+ * Origin - where a Value came from. A sparse item and an item explicitly
+ * stored with the default value look the same otherwise.
+ */
+type Origin int
+
+const (
+	OriginStored        Origin = iota // decoded from a key in the database
+	OriginSparseDefault               // index inside the vector with no key; the default
+	OriginMissing                     // no item at all, e.g. Back on an empty vector
+)
+
+func (o Origin) String() string {
+	switch o {
+	case OriginStored:
+		return "stored"
+	case OriginSparseDefault:
+		return "sparse-default"
+	case OriginMissing:
+		return "missing"
+	}
+	return fmt.Sprintf("Origin(%d)", int(o))
 }
 
 // Pack Value supported values into a Value byte array
 func ValPack(val interface{}) ([]byte, error) {
+	return AppendPack(nil, val)
+}
 
-	buf := new(bytes.Buffer)
-
-	var err error
+// Append the packed form of val to dst, like ValPack but without
+// allocating when dst has room, e.g. a buffer from AcquireBuffer. On error
+// dst is returned unchanged.
+func AppendPack(dst []byte, val interface{}) ([]byte, error) {
+	var b [8]byte
 
 	switch v := val.(type) {
+	case Raw:
+		if len(v) == 0 {
+			return dst, fmt.Errorf("fdb-vector empty raw value")
+		}
+		return append(dst, v...), nil
+	case Counter:
+		binary.LittleEndian.PutUint64(b[:], uint64(v))
+		return append(append(dst, 0x00), b[:]...), nil
+	case Ordered:
+		binary.LittleEndian.PutUint64(b[:], uint64(v)^signBit)
+		return append(append(dst, 0x04), b[:]...), nil
 	case int64:
-		buf.WriteByte(0x01)
-		err = binary.Write(buf, binary.BigEndian, v)
+		binary.BigEndian.PutUint64(b[:], uint64(v))
+		return append(append(dst, 0x01), b[:]...), nil
 	case int:
-		buf.WriteByte(0x01)
-		err = binary.Write(buf, binary.BigEndian, int64(v))
+		binary.BigEndian.PutUint64(b[:], uint64(v))
+		return append(append(dst, 0x01), b[:]...), nil
 	case float64:
-		buf.WriteByte(0x02)
-		err = binary.Write(buf, binary.BigEndian, v)
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(v))
+		return append(append(dst, 0x02), b[:]...), nil
 	case float32:
-		buf.WriteByte(0x02)
-		err = binary.Write(buf, binary.BigEndian, float64(v))
+		binary.BigEndian.PutUint64(b[:], math.Float64bits(float64(v)))
+		return append(append(dst, 0x02), b[:]...), nil
 	case string:
-		buf.WriteByte(0x03)
-		_, err = buf.WriteString(v)
-	default:
-		err = fmt.Errorf("fdb-vector unencodable element (%v, type %T)", v, v)
+		return append(append(dst, 0x03), v...), nil
+	case encoding.BinaryMarshaler:
+		m, err := v.MarshalBinary()
+		if err != nil {
+			return dst, err
+		}
+		return append(append(dst, 0x06), m...), nil
 	}
-
-	return buf.Bytes(), err
+	return dst, fmt.Errorf("fdb-vector unencodable element (%v, type %T)", val, val)
 }
 
 // Unpack values into a Value structure
 func ValUnpack(b []byte) (*Value, error) {
-
 	v := &Value{}
+	err := unpackInto(b, v)
+	return v, err
+}
+
+// Unpack b into v, overwriting all of it, without allocating a Value.
+func unpackInto(b []byte, v *Value) error {
+
+	*v = Value{}
 
 	if len(b) == 0 {
-		return v, fmt.Errorf("No Byte array to Decode")
+		return fmt.Errorf("No Byte array to Decode")
 	}
 
 	var err error
 	code := b[0]
-	buf := bytes.NewBuffer(b[1:])
 
 	switch {
+	case code == 0x00:
+		var u uint64
+		v.IsInt = true
+		u, err = fixed64(b[1:], binary.LittleEndian)
+		v.Int = int64(u)
 	case code == 0x01:
+		var u uint64
 		v.IsInt = true
-		err = binary.Read(buf, binary.BigEndian, &v.Int)
+		u, err = fixed64(b[1:], binary.BigEndian)
+		v.Int = int64(u)
 	case code == 0x02:
+		var u uint64
 		v.IsFloat = true
-		err = binary.Read(buf, binary.BigEndian, &v.Float)
+		u, err = fixed64(b[1:], binary.BigEndian)
+		v.Float = math.Float64frombits(u)
 	case code == 0x03:
 		v.IsString = true
 		v.String = string(b[1:])
+	case code == 0x04:
+		var u uint64
+		v.IsInt = true
+		u, err = fixed64(b[1:], binary.LittleEndian)
+		v.Int = int64(u ^ signBit)
+	case code == 0x05:
+		// timestamp header wrapping another packed value
+		if len(b) < 10 {
+			return fmt.Errorf("timestamped value too short (%d bytes)", len(b))
+		}
+		nanos := int64(binary.BigEndian.Uint64(b[1:9]))
+		err = unpackInto(b[9:], v)
+		v.Modified = time.Unix(0, nanos)
+	case code == 0x06:
+		v.IsBinary = true
+		v.Bytes = b[1:]
+	case PassUnknownTypecodes:
+		v.IsRaw = true
+		v.Bytes = b
 	default:
 		err = fmt.Errorf("unable to decode tuple element with unknown typecode %02x", code)
 	}
+	v.Raw = b
 
-	return v, err
+	return err
+}
+
+// Read a 64 bit integer from the start of b, failing like binary.Read
+// when b is too short.
+func fixed64(b []byte, order binary.ByteOrder) (uint64, error) {
+	switch {
+	case len(b) == 0:
+		return 0, io.EOF
+	case len(b) < 8:
+		return 0, io.ErrUnexpectedEOF
+	}
+	return order.Uint64(b), nil
+}
+
+// Unmarshal a value packed from an encoding.BinaryMarshaler into target.
+func (v *Value) UnmarshalInto(target encoding.BinaryUnmarshaler) error {
+	if !v.IsBinary {
+		return fmt.Errorf("fdb-vector value is not binary marshaled")
+	}
+	return target.UnmarshalBinary(v.Bytes)
+}
+
+// Copy the value into dest, a pointer, converting as database/sql's Scan
+// does: ints into *int64, *int, *int32, *float64 and *string, floats into
+// *float64, *float32 and *string, strings into *string, *[]byte and, if
+// they parse, the numeric types; binary values into *[]byte or an
+// encoding.BinaryUnmarshaler; raw values into *Raw. Any value goes into
+// *interface{} as its natural Go type. Fails for lossy conversions and
+// values without a type, e.g. a sparse item read with an empty default.
+func (v *Value) Scan(dest interface{}) error {
+	switch {
+	case v.IsInt:
+		return scanInt(v.Int, dest)
+	case v.IsFloat:
+		return scanFloat(v.Float, dest)
+	case v.IsString:
+		return scanString(v.String, dest)
+	case v.IsBinary:
+		switch d := dest.(type) {
+		case *[]byte:
+			*d = append([]byte{}, v.Bytes...)
+			return nil
+		case *interface{}:
+			*d = append([]byte{}, v.Bytes...)
+			return nil
+		case encoding.BinaryUnmarshaler:
+			return d.UnmarshalBinary(v.Bytes)
+		}
+	case v.IsRaw:
+		switch d := dest.(type) {
+		case *Raw:
+			*d = append(Raw{}, v.Bytes...)
+			return nil
+		case *interface{}:
+			*d = append(Raw{}, v.Bytes...)
+			return nil
+		}
+	default:
+		return fmt.Errorf("fdb-vector cannot scan %s value without a type", v.Origin)
+	}
+	return fmt.Errorf("fdb-vector cannot scan value into %T", dest)
+}
+
+func scanInt(n int64, dest interface{}) error {
+	switch d := dest.(type) {
+	case *int64:
+		*d = n
+	case *int:
+		if int64(int(n)) != n {
+			return fmt.Errorf("fdb-vector value %d overflows int", n)
+		}
+		*d = int(n)
+	case *int32:
+		if int64(int32(n)) != n {
+			return fmt.Errorf("fdb-vector value %d overflows int32", n)
+		}
+		*d = int32(n)
+	case *float64:
+		*d = float64(n)
+	case *string:
+		*d = strconv.FormatInt(n, 10)
+	case *interface{}:
+		*d = n
+	default:
+		return fmt.Errorf("fdb-vector cannot scan int into %T", dest)
+	}
+	return nil
+}
+
+func scanFloat(f float64, dest interface{}) error {
+	switch d := dest.(type) {
+	case *float64:
+		*d = f
+	case *float32:
+		*d = float32(f)
+	case *string:
+		*d = strconv.FormatFloat(f, 'g', -1, 64)
+	case *interface{}:
+		*d = f
+	default:
+		return fmt.Errorf("fdb-vector cannot scan float into %T", dest)
+	}
+	return nil
+}
+
+func scanString(s string, dest interface{}) error {
+	switch d := dest.(type) {
+	case *string:
+		*d = s
+	case *[]byte:
+		*d = []byte(s)
+	case *interface{}:
+		*d = s
+	case *int64, *int, *int32:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return fmt.Errorf("fdb-vector cannot scan %q into %T: %w", s, dest, err)
+		}
+		return scanInt(n, dest)
+	case *float64, *float32:
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return fmt.Errorf("fdb-vector cannot scan %q into %T: %w", s, dest, err)
+		}
+		return scanFloat(f, dest)
+	default:
+		return fmt.Errorf("fdb-vector cannot scan string into %T", dest)
+	}
+	return nil
+}
+
+// Pack a counter for the atomic MAX/ADD mutations, which treat values as
+// little-endian integers.
+func packCounter(n int64) []byte {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, uint64(n))
+	return b
+}
+
+// Unpack a counter written by packCounter or an atomic mutation. Shorter
+// values are zero extended as FDB does.
+func unpackCounter(b []byte) int64 {
+	var buf [8]byte
+	copy(buf[:], b)
+	return int64(binary.LittleEndian.Uint64(buf[:]))
+}
+
+// Wrap a packed value in a timestamp header, see Options.Timestamps.
+func stampValue(packed []byte, t time.Time) []byte {
+	b := make([]byte, 9, 9+len(packed))
+	b[0] = 0x05
+	binary.BigEndian.PutUint64(b[1:9], uint64(t.UnixNano()))
+	return append(b, packed...)
 }
@@ -1,6 +1,13 @@
 package vector
 
-import "testing"
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
 
 func TestPackUnpack(t *testing.T) {
 
@@ -45,3 +52,323 @@ func TestPackUnpack(t *testing.T) {
 		t.Error("expected error for unsupported pack type. Instead got none")
 	}
 }
+
+func TestPackUnpackCounter(t *testing.T) {
+
+	b, err := ValPack(Counter(-42))
+	if err != nil {
+		t.Error("valPack fails packing Counter", err)
+	}
+	v, err := ValUnpack(b)
+	if err != nil {
+		t.Error("valPack fails unpacking", err)
+	}
+	if !v.IsInt || v.Int != -42 {
+		t.Error("valPack fails unpacking Counter(-42). Instead got", v.Int)
+	}
+}
+
+func TestOrderedEncoding(t *testing.T) {
+
+	// compare packed values the way MIN/MAX do: as little-endian unsigned
+	less := func(a, b []byte) bool {
+		for i := len(a) - 1; i >= 0; i-- {
+			if a[i] != b[i] {
+				return a[i] < b[i]
+			}
+		}
+		return false
+	}
+
+	vals := []int64{-1 << 63, -100, -1, 0, 1, 100, 1<<63 - 1}
+	for i := 1; i < len(vals); i++ {
+		a, _ := ValPack(Ordered(vals[i-1]))
+		b, _ := ValPack(Ordered(vals[i]))
+		if !less(a, b) {
+			t.Errorf("Ordered(%d) does not sort before Ordered(%d)", vals[i-1], vals[i])
+		}
+		v, err := ValUnpack(b)
+		if err != nil || v.Int != vals[i] {
+			t.Errorf("Ordered(%d) unpacked to %d, %v", vals[i], v.Int, err)
+		}
+	}
+}
+
+func TestStampValue(t *testing.T) {
+
+	now := time.Unix(1400000000, 123)
+	b, err := ValPack("a")
+	if err != nil {
+		t.Error("valPack fails packing 'a'")
+	}
+	v, err := ValUnpack(stampValue(b, now))
+	if err != nil {
+		t.Error("valPack fails unpacking", err)
+	}
+	if !v.IsString || v.String != "a" || !v.Modified.Equal(now) {
+		t.Error("valPack fails unpacking timestamped 'a'. Instead got", v.String, v.Modified)
+	}
+}
+
+func TestPackBinaryMarshaler(t *testing.T) {
+
+	when := time.Date(2020, 1, 2, 3, 4, 5, 6, time.UTC)
+	b, err := ValPack(when)
+	if err != nil {
+		t.Fatal("valPack fails packing time.Time", err)
+	}
+	v, err := ValUnpack(b)
+	if err != nil {
+		t.Fatal("valPack fails unpacking", err)
+	}
+	if !v.IsBinary {
+		t.Fatal("Expected binary marshaled value")
+	}
+
+	var got time.Time
+	if err := v.UnmarshalInto(&got); err != nil {
+		t.Fatal(err)
+	}
+	if !got.Equal(when) {
+		t.Errorf("Expected %v, got %v instead", when, got)
+	}
+
+	b, _ = ValPack("str")
+	v, _ = ValUnpack(b)
+	if err := v.UnmarshalInto(&got); err == nil {
+		t.Error("Expected error unmarshaling a string value")
+	}
+}
+
+func TestValueScan(t *testing.T) {
+
+	iv := &Value{IsInt: true, Int: 42}
+	var i int
+	var f float64
+	var s string
+	if err := iv.Scan(&i); err != nil || i != 42 {
+		t.Errorf("Expected 42, got %d (%v)", i, err)
+	}
+	if err := iv.Scan(&f); err != nil || f != 42 {
+		t.Errorf("Expected 42.0, got %f (%v)", f, err)
+	}
+	if err := iv.Scan(&s); err != nil || s != "42" {
+		t.Errorf("Expected \"42\", got %q (%v)", s, err)
+	}
+
+	sv := &Value{IsString: true, String: "2.5"}
+	if err := sv.Scan(&f); err != nil || f != 2.5 {
+		t.Errorf("Expected 2.5, got %f (%v)", f, err)
+	}
+	if err := sv.Scan(&i); err == nil {
+		t.Error("Expected error scanning \"2.5\" into int")
+	}
+
+	fv := &Value{IsFloat: true, Float: 1.5}
+	if err := fv.Scan(&i); err == nil {
+		t.Error("Expected error scanning float into int")
+	}
+
+	var any interface{}
+	if err := iv.Scan(&any); err != nil || any != int64(42) {
+		t.Errorf("Expected int64 42, got %v (%v)", any, err)
+	}
+
+	if err := (&Value{Origin: OriginSparseDefault}).Scan(&s); err == nil {
+		t.Error("Expected error scanning a value without a type")
+	}
+}
+
+func TestAppendPackPooled(t *testing.T) {
+
+	buf := AcquireBuffer()
+	defer ReleaseBuffer(buf)
+
+	for _, val := range []interface{}{Counter(-3), Ordered(-3), int64(7), 7, 2.5, float32(1.5), "s"} {
+		want, err := ValPack(val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := AppendPack((*buf)[:0], val)
+		if err != nil {
+			t.Fatal(err)
+		}
+		*buf = got
+		if string(got) != string(want) {
+			t.Errorf("AppendPack(%v) = %x, ValPack gives %x", val, got, want)
+		}
+
+		v := AcquireValue()
+		if err := unpackInto(got, v); err != nil {
+			t.Error(err)
+		}
+		ReleaseValue(v)
+	}
+
+	if _, err := AppendPack(nil, struct{}{}); err == nil {
+		t.Error("expected error for unsupported pack type")
+	}
+}
+
+func TestLazyValue(t *testing.T) {
+
+	b, _ := ValPack(Ordered(-5))
+	lv := LazyValue{Index: 1, raw: b}
+	if n, err := lv.Int(); err != nil || n != -5 {
+		t.Errorf("Expected -5, got %d (%v)", n, err)
+	}
+	if _, err := lv.String(); err == nil {
+		t.Error("Expected error reading an int as a string")
+	}
+
+	now := time.Unix(1400000000, 0)
+	b, _ = ValPack("lazy")
+	lv = LazyValue{raw: stampValue(b, now)}
+	if !lv.IsString() || !lv.Modified().Equal(now) {
+		t.Errorf("Expected timestamped string, got typecode %d at %v", lv.Typecode(), lv.Modified())
+	}
+	if s, err := lv.String(); err != nil || s != "lazy" {
+		t.Errorf("Expected \"lazy\", got %q (%v)", s, err)
+	}
+	v, err := lv.Value()
+	if err != nil || v.String != "lazy" {
+		t.Errorf("Expected decoded \"lazy\", got %q (%v)", v.String, err)
+	}
+
+	if (LazyValue{}).Typecode() != -1 {
+		t.Error("Expected typecode -1 for an empty value")
+	}
+}
+
+func TestLazyStringNoCopy(t *testing.T) {
+
+	b, _ := ValPack("zero-copy")
+	lv := LazyValue{raw: b}
+	sb, err := lv.StringBytes()
+	if err != nil || string(sb) != "zero-copy" || &sb[0] != &b[1] {
+		t.Errorf("Expected aliased bytes of \"zero-copy\", got %q (%v)", sb, err)
+	}
+	if s, err := lv.UnsafeString(); err != nil || s != "zero-copy" {
+		t.Errorf("Expected \"zero-copy\", got %q (%v)", s, err)
+	}
+
+	b, _ = ValPack(1.5)
+	if _, err := (LazyValue{raw: b}).UnsafeString(); err == nil {
+		t.Error("Expected error reading a float as a string")
+	}
+}
+
+func TestUnknownTypecode(t *testing.T) {
+
+	packed := []byte{0x7f, 1, 2, 3}
+	if _, err := ValUnpack(packed); err == nil {
+		t.Error("Expected error for unknown typecode")
+	}
+
+	PassUnknownTypecodes = true
+	defer func() { PassUnknownTypecodes = false }()
+
+	v, err := ValUnpack(packed)
+	if err != nil || !v.IsRaw || string(v.Bytes) != string(packed) {
+		t.Fatalf("Expected raw value %x, got %v (%v)", packed, v, err)
+	}
+
+	var raw Raw
+	if err := v.Scan(&raw); err != nil {
+		t.Fatal(err)
+	}
+	repacked, err := ValPack(raw)
+	if err != nil || string(repacked) != string(packed) {
+		t.Errorf("Expected raw value to repack verbatim, got %x (%v)", repacked, err)
+	}
+}
+
+func TestDeltaEncoding(t *testing.T) {
+
+	elems := []int64{1600000000, 1600000001, 1600000005, 1599999990, -1 << 63, 1<<63 - 1}
+	b := encodeDeltas(elems)
+	got, err := decodeDeltas(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(elems) {
+		t.Fatalf("Expected %d elements, got %d", len(elems), len(got))
+	}
+	for i := range elems {
+		if got[i] != elems[i] {
+			t.Errorf("Element %d: expected %d, got %d", i, elems[i], got[i])
+		}
+	}
+	if n, err := deltaCount(b); err != nil || n != len(elems) {
+		t.Errorf("Expected count %d, got %d (%v)", len(elems), n, err)
+	}
+
+	// increasing timestamps take a byte or two each after the first
+	steady := make([]int64, 512)
+	for i := range steady {
+		steady[i] = 1600000000 + int64(i)*10
+	}
+	if n := len(encodeDeltas(steady)); n > 2*len(steady) {
+		t.Errorf("Expected at most %d bytes for small steps, got %d", 2*len(steady), n)
+	}
+
+	if _, err := decodeDeltas(b[:len(b)-1]); err == nil {
+		t.Error("Expected error decoding a truncated chunk")
+	}
+}
+
+func TestOrderedIntPacking(t *testing.T) {
+
+	for _, n := range []int64{-1 << 63, -5, 0, 7, 1<<63 - 1} {
+		if got := unpackOrderedInt(packOrderedInt(n)); got != n {
+			t.Errorf("Expected %d, got %d", n, got)
+		}
+		if got := unpackMinInt(packMinInt(n)); got != n {
+			t.Errorf("Expected %d from the min layout, got %d", n, got)
+		}
+	}
+
+	// MAX over the min layout keeps the smaller int, and the zero a
+	// missing key reads as stands for the largest one
+	small, large := packMinInt(-5), packMinInt(7)
+	if binary.LittleEndian.Uint64(small) <= binary.LittleEndian.Uint64(large) {
+		t.Errorf("Expected -5 to pack above 7 in the min layout")
+	}
+	if n := unpackMinInt(make([]byte, 8)); n != 1<<63-1 {
+		t.Errorf("Expected zero to stand for the largest int, got %d", n)
+	}
+	if n, ok := aggInt(nil); ok {
+		t.Errorf("Expected no int for an absent item, got %d", n)
+	}
+}
+
+type keyArray []fdb.Key
+
+func (ka keyArray) Get() ([]fdb.Key, error) {
+	return ka, nil
+}
+
+// A ReadTransaction of bindings with GetRangeSplitPoints.
+type splitTransaction struct {
+	fdb.ReadTransaction
+}
+
+func (splitTransaction) GetRangeSplitPoints(r fdb.ExactRange, chunkSize int64) keyArray {
+	return keyArray{fdb.Key(fmt.Sprint(chunkSize))}
+}
+
+func TestRangeSplitPoints(t *testing.T) {
+
+	r := fdb.KeyRange{Begin: fdb.Key("a"), End: fdb.Key("b")}
+	keys, ok, err := rangeSplitPoints(splitTransaction{}, r, 42)
+	if err != nil || !ok {
+		t.Fatalf("Expected GetRangeSplitPoints to be found, got %v, %v", ok, err)
+	}
+	if len(keys) != 1 || string(keys[0]) != "42" {
+		t.Errorf("Expected the split keys for chunk size 42, got %v", keys)
+	}
+
+	if _, ok, _ := rangeSplitPoints(struct{ fdb.ReadTransaction }{}, r, 42); ok {
+		t.Errorf("Expected no GetRangeSplitPoints on a plain transaction")
+	}
+}
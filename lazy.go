@@ -0,0 +1,140 @@
+package vector
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+	"unsafe"
+)
+
+/*
+ * LazyValue - a stored item that has not been decoded yet. It holds the
+ * raw packed bytes and decodes only the part a typed accessor asks for,
+ * so scans that look at the type, or reject most items, skip the cost of
+ * a full ValUnpack. The bytes belong to the iteration that produced it;
+ * call Value to keep a decoded copy.
+ */
+type LazyValue struct {
+	Index int64
+	raw   []byte
+}
+
+/*
+ * LazyPredicate - a test applied to each stored item of a FilterLazy scan.
+ */
+type LazyPredicate func(lv LazyValue) bool
+
+// Like Get, but returns the item undecoded; see LazyValue.
+func (vi *Vectorator) GetLazy() (LazyValue, error) {
+	if vi.err != nil {
+		return LazyValue{}, vi.opError(vi.err)
+	}
+	return LazyValue{Index: vi.index, raw: vi.kv.Value}, nil
+}
+
+// The packed value with any timestamp header removed.
+func (lv LazyValue) body() []byte {
+	if len(lv.raw) >= 10 && lv.raw[0] == 0x05 {
+		return lv.raw[9:]
+	}
+	return lv.raw
+}
+
+// The typecode of the value, as written by ValPack; see keyvalue.go. Items
+// stored with Options.Timestamps report the code of the wrapped value.
+// Returns -1 for an empty value.
+func (lv LazyValue) Typecode() int {
+	b := lv.body()
+	if len(b) == 0 {
+		return -1
+	}
+	return int(b[0])
+}
+
+func (lv LazyValue) IsInt() bool {
+	c := lv.Typecode()
+	return c == 0x00 || c == 0x01 || c == 0x04
+}
+
+func (lv LazyValue) IsFloat() bool {
+	return lv.Typecode() == 0x02
+}
+
+func (lv LazyValue) IsString() bool {
+	return lv.Typecode() == 0x03
+}
+
+func (lv LazyValue) IsBinary() bool {
+	return lv.Typecode() == 0x06
+}
+
+// Decode the value as an int; fails when it is not one.
+func (lv LazyValue) Int() (int64, error) {
+	b := lv.body()
+	if !lv.IsInt() {
+		return 0, fmt.Errorf("fdb-vector value with typecode %d is not an int", lv.Typecode())
+	}
+	switch b[0] {
+	case 0x01:
+		u, err := fixed64(b[1:], binary.BigEndian)
+		return int64(u), err
+	case 0x04:
+		u, err := fixed64(b[1:], binary.LittleEndian)
+		return int64(u ^ signBit), err
+	}
+	u, err := fixed64(b[1:], binary.LittleEndian)
+	return int64(u), err
+}
+
+// Decode the value as a float; fails when it is not one.
+func (lv LazyValue) Float() (float64, error) {
+	if !lv.IsFloat() {
+		return 0, fmt.Errorf("fdb-vector value with typecode %d is not a float", lv.Typecode())
+	}
+	u, err := fixed64(lv.body()[1:], binary.BigEndian)
+	return math.Float64frombits(u), err
+}
+
+// Decode the value as a string; fails when it is not one.
+func (lv LazyValue) String() (string, error) {
+	if !lv.IsString() {
+		return "", fmt.Errorf("fdb-vector value with typecode %d is not a string", lv.Typecode())
+	}
+	return string(lv.body()[1:]), nil
+}
+
+// The bytes of a string value without copying them into a new string.
+// They alias the iteration's buffer and must not be modified.
+func (lv LazyValue) StringBytes() ([]byte, error) {
+	if !lv.IsString() {
+		return nil, fmt.Errorf("fdb-vector value with typecode %d is not a string", lv.Typecode())
+	}
+	return lv.body()[1:], nil
+}
+
+// Like String, but the result shares memory with the iteration's buffer
+// instead of copying it. Only for hot scanning paths: the string must not
+// outlive the scan unless copied, and nothing may write to the buffer
+// while it is in use.
+func (lv LazyValue) UnsafeString() (string, error) {
+	b, err := lv.StringBytes()
+	if err != nil || len(b) == 0 {
+		return "", err
+	}
+	return *(*string)(unsafe.Pointer(&b)), nil
+}
+
+// The write time of an item stored with Options.Timestamps, or the zero
+// time.
+func (lv LazyValue) Modified() time.Time {
+	if len(lv.raw) >= 10 && lv.raw[0] == 0x05 {
+		return time.Unix(0, int64(binary.BigEndian.Uint64(lv.raw[1:9])))
+	}
+	return time.Time{}
+}
+
+// Decode the whole value, as Vectorator.Get would have.
+func (lv LazyValue) Value() (*Value, error) {
+	return ValUnpack(lv.raw)
+}
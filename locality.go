@@ -0,0 +1,78 @@
+package vector
+
+import (
+	"bytes"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+/*
+ * Shard - a part of the vector held by one set of storage servers: the
+ * items with index in [Start, Stop) and the addresses of the servers
+ * holding them.
+ */
+type Shard struct {
+	Start     int64
+	Stop      int64
+	Addresses []string
+}
+
+// Return the storage shards the vector's items are spread over, in index
+// order, so data-parallel jobs can read each range from a nearby process.
+// Shard boundaries are translated to the first index at or after them;
+// shards holding no items are left out.
+func (vect *Vector) Locality(tr fdb.Transaction) (_ []Shard, err error) {
+	defer func() { err = vect.opError("Locality", -1, err) }()
+
+	size, err := vect.Size(tr)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return nil, nil
+	}
+
+	rv, err := tr.GetReadVersion().Get()
+	if err != nil {
+		return nil, err
+	}
+	kr := vect.indexRange()
+	boundaries, err := fdb.LocalityGetBoundaryKeys(tr.GetDatabase(), kr, 0, rv)
+	if err != nil {
+		return nil, err
+	}
+
+	// index range start of every shard, the first one starting at 0
+	starts := []int64{0}
+	for _, b := range boundaries {
+		key, err := tr.GetKey(fdb.FirstGreaterOrEqual(b)).Get()
+		if err != nil {
+			return nil, err
+		}
+		_, end := kr.FDBRangeKeys()
+		if bytes.Compare(key, end.FDBKey()) >= 0 {
+			break
+		}
+		index, err := vect.indexAt(key)
+		if err != nil {
+			return nil, err
+		}
+		if index > starts[len(starts)-1] {
+			starts = append(starts, index)
+		}
+	}
+
+	shards := make([]Shard, len(starts))
+	for i, start := range starts {
+		stop := size
+		if i+1 < len(starts) {
+			stop = starts[i+1]
+		}
+		addrs, err := tr.LocalityGetAddressesForKey(vect.keyAt(start)).Get()
+		if err != nil {
+			return nil, err
+		}
+		shards[i] = Shard{Start: start, Stop: stop, Addresses: addrs}
+	}
+	return shards, nil
+}
@@ -0,0 +1,100 @@
+package vector
+
+import (
+	"time"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+/*
+ * Element leases are kept in a sibling subspace of the vector:
+ *
+ *	("lock", index) -> (owner, expiry in unix nanoseconds)
+ *
+ * Expiry uses the clients' wall clocks, so ttl must be well above the
+ * clock skew between workers.
+ */
+
+// Claim the item at index for owner for ttl. Returns true if the lock was
+// free, expired or already held by owner (whose lease is then renewed),
+// false if another owner holds it.
+func (vect *Vector) TryLock(index int64, owner string, ttl time.Duration, tr fdb.Transaction) (_ bool, err error) {
+	defer func() { err = vect.opError("TryLock", index, err) }()
+
+	if err := vect.mutation("TryLock", index, tr); err != nil {
+		return false, err
+	}
+	if index < 0 {
+		return false, outOfRange("vector.trylock", index)
+	}
+	held, _, err := vect.lockHolder(index, tr)
+	if err != nil {
+		return false, err
+	}
+	if held != "" && held != owner {
+		return false, nil
+	}
+	expiry := time.Now().Add(ttl).UnixNano()
+	tr.Set(vect.lockKey(index), tuple.Tuple{owner, expiry}.Pack())
+	return true, nil
+}
+
+// Release the lock owner holds on the item at index. Fails with
+// ErrNotLockOwner if the lock is held by someone else; releasing a free or
+// expired lock is a no-op.
+func (vect *Vector) Unlock(index int64, owner string, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("Unlock", index, err) }()
+
+	if err := vect.mutation("Unlock", index, tr); err != nil {
+		return err
+	}
+	held, _, err := vect.lockHolder(index, tr)
+	if err != nil {
+		return err
+	}
+	if held == "" {
+		return nil
+	}
+	if held != owner {
+		return ErrNotLockOwner
+	}
+	tr.Clear(vect.lockKey(index))
+	return nil
+}
+
+// Get the owner of the lock on the item at index and when it expires. The
+// owner is empty if the item is not locked.
+func (vect *Vector) LockedBy(index int64, tr fdb.ReadTransaction) (_ string, _ time.Time, err error) {
+	defer func() { err = vect.opError("LockedBy", index, err) }()
+
+	return vect.lockHolder(index, tr)
+}
+
+// Read the current, unexpired lock on index.
+func (vect *Vector) lockHolder(index int64, tr fdb.ReadTransaction) (string, time.Time, error) {
+	key := vect.lockKey(index)
+	v, err := tr.Get(key).Get()
+	if err != nil || v == nil {
+		return "", time.Time{}, err
+	}
+	t, err := tuple.Unpack(v)
+	if err != nil || len(t) != 2 {
+		return "", time.Time{}, &ForeignKeyError{Key: key, Reason: "malformed lock"}
+	}
+	owner, ok1 := t[0].(string)
+	expiry, ok2 := t[1].(int64)
+	if !ok1 || !ok2 {
+		return "", time.Time{}, &ForeignKeyError{Key: key, Reason: "malformed lock"}
+	}
+	until := time.Unix(0, expiry)
+	if !until.After(time.Now()) {
+		return "", time.Time{}, nil
+	}
+	return owner, until, nil
+}
+
+// Key of the lock on index.
+func (vect *Vector) lockKey(index int64) fdb.Key {
+	return vect.subspace.Pack(tuple.Tuple{"lock", index})
+}
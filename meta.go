@@ -0,0 +1,183 @@
+package vector
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+/*
+ * Metadata - settings every handle on a vector has to agree on are stored
+ * in ("meta", name) keys next to the items. Init writes them the first
+ * time and checks them afterwards.
+ */
+
+// Key encodings recorded under ("meta", "keys").
+const (
+	keysTuple   = "tuple"
+	keysFixed64 = "fixed64"
+)
+
+// Record the handle's configuration in the vector's metadata if it has
+// none yet, or check that it matches what is recorded. Returns an error
+// matching ErrMetadataMismatch on disagreement. Call it once after opening
+// the vector with its options set.
+//
+// Vectors created before metadata existed count as using tuple keys.
+func (vect *Vector) Init(tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("Init", -1, err) }()
+
+	if err := vect.initDefault(tr); err != nil {
+		return err
+	}
+
+	want := keysTuple
+	if vect.codec != nil {
+		want = vect.codec.Name()
+	} else if vect.opts.FixedKeys {
+		want = keysFixed64
+	}
+
+	got, err := tr.Get(vect.metaKey("keys")).Get()
+	if err != nil {
+		return err
+	}
+	if got == nil {
+		// nothing recorded: fine for an empty vector, otherwise the
+		// items predate metadata and use tuple keys
+		if want != keysTuple {
+			_, end := vect.subspace.FDBRangeKeys()
+			legacy := fdb.KeyRange{Begin: vect.subspace.Pack(tuple.Tuple{int64(0)}), End: end}
+			kvs, err := tr.GetRange(legacy, fdb.RangeOptions{Limit: 1}).GetSliceWithError()
+			if err != nil {
+				return err
+			}
+			if len(kvs) > 0 {
+				got = []byte(keysTuple)
+			}
+		}
+		if got == nil {
+			tr.Set(vect.metaKey("keys"), []byte(want))
+			return nil
+		}
+	}
+
+	if string(got) != want {
+		return fmt.Errorf("vector: key encoding is %q, handle uses %q: %w", got, want, ErrMetadataMismatch)
+	}
+	return nil
+}
+
+// Record the default value in ("meta", "default") if none is recorded yet,
+// or check that it matches the handle's, so every process reads sparse
+// items the same way.
+func (vect *Vector) initDefault(tr fdb.Transaction) error {
+	want, err := ValPack(vect.defaultValue)
+	if err != nil {
+		return err
+	}
+	got, err := tr.Get(vect.metaKey("default")).Get()
+	if err != nil {
+		return err
+	}
+	if got == nil {
+		tr.Set(vect.metaKey("default"), want)
+		return nil
+	}
+	if !bytes.Equal(got, want) {
+		stored, err := ValUnpack(got)
+		if err != nil {
+			return err
+		}
+		return fmt.Errorf("vector: default value is %q, handle uses %q: %w", stored.String, vect.defaultValue, ErrMetadataMismatch)
+	}
+	return nil
+}
+
+// Create a handle on the vector stored in sub, configured with the key
+// encoding and default value recorded in its metadata, for tools walking
+// vectors they did not open. Vectors with a custom KeyCodec, and chunked
+// or delta vectors, cannot be opened this way and fail with
+// ErrMetadataMismatch.
+func openStored(db fdb.Transactor, sub subspace.Subspace) (*Vector, error) {
+	vect := NewVector(sub, "")
+	r, err := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		keys := tr.Get(vect.metaKey("keys"))
+		def := tr.Get(vect.metaKey("default"))
+		k, err := keys.Get()
+		if err != nil {
+			return nil, err
+		}
+		d, err := def.Get()
+		if err != nil {
+			return nil, err
+		}
+		return [2][]byte{k, d}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	meta := r.([2][]byte)
+
+	switch string(meta[0]) {
+	case "", keysTuple:
+	case keysFixed64:
+		vect.opts.FixedKeys = true
+	default:
+		return nil, fmt.Errorf("vector: cannot open a vector with key encoding %q from its metadata: %w", meta[0], ErrMetadataMismatch)
+	}
+	if meta[1] != nil {
+		def, err := ValUnpack(meta[1])
+		if err != nil {
+			return nil, err
+		}
+		vect.defaultValue = def.String
+	}
+	return vect, nil
+}
+
+// Names of the ValPack typecodes, as recorded under ("meta", "type").
+var typeNames = map[byte]string{
+	0x00: "counter",
+	0x01: "int",
+	0x02: "float",
+	0x03: "string",
+	0x04: "ordered",
+	0x06: "binary",
+}
+
+func typeName(code byte) string {
+	if name, ok := typeNames[code]; ok {
+		return name
+	}
+	return fmt.Sprintf("typecode %02x", code)
+}
+
+// Check that packed has the type recorded in ("meta", "type"), recording
+// it if the vector has none yet.
+func (vect *Vector) checkType(index int64, packed []byte, tr fdb.Transaction) error {
+	if len(packed) == 0 {
+		return nil
+	}
+	got := typeName(packed[0])
+	want, err := tr.Get(vect.metaKey("type")).Get()
+	if err != nil {
+		return err
+	}
+	if want == nil {
+		tr.Set(vect.metaKey("type"), []byte(got))
+		return nil
+	}
+	if string(want) != got {
+		return &TypeMismatchError{Index: index, Want: string(want), Got: got}
+	}
+	return nil
+}
+
+// Key of the metadata entry name.
+func (vect *Vector) metaKey(name string) fdb.Key {
+	return vect.subspace.Pack(tuple.Tuple{"meta", name})
+}
@@ -0,0 +1,193 @@
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+// Shortest run of consecutive indexes MultiGet reads with one GetRange
+// instead of point reads.
+const multiGetMinRun = 2
+
+// A run of consecutive indexes, [first, last].
+type indexRun struct {
+	first, last int64
+}
+
+// Get the items at indexes, in the same order, like calling Get for each
+// of them in one transaction. Runs of consecutive indexes are fetched with
+// a single GetRange and the remaining indexes with point reads, all issued
+// before any is waited on, so reading a window of a few hundred items
+// costs one round trip instead of hundreds. Sparse items get the default
+// value; any index past the end fails the whole call.
+func (vect *Vector) MultiGet(indexes []int64, tr fdb.ReadTransaction) (_ []*Value, err error) {
+	info, err := vect.beforeOp("MultiGet", -1)
+	if err != nil {
+		return nil, err
+	}
+	defer vect.afterOp(info, &err)
+
+	if len(indexes) == 0 {
+		return nil, nil
+	}
+
+	size, err := vect.Size(tr)
+	if err != nil {
+		return nil, err
+	}
+	resolved := make([]int64, len(indexes))
+	for i, index := range indexes {
+		if index < 0 && vect.opts.NegativeIndexes {
+			index += size
+		}
+		if index < 0 || index >= size {
+			return nil, outOfRange("vector.multiget", indexes[i])
+		}
+		resolved[i] = index
+	}
+
+	runs, singles := planRuns(resolved)
+
+	ranges := make([]fdb.RangeResult, len(runs))
+	for i, run := range runs {
+		kr := fdb.KeyRange{Begin: vect.keyAt(run.first), End: vect.keyAt(run.last + 1)}
+		ranges[i] = tr.GetRange(kr, fdb.RangeOptions{})
+	}
+	points := make([]fdb.FutureByteSlice, len(singles))
+	for i, index := range singles {
+		points[i] = tr.Get(vect.keyAt(index))
+	}
+
+	found := make(map[int64][]byte, len(resolved))
+	for _, rr := range ranges {
+		kvs, err := rr.GetSliceWithError()
+		if err != nil {
+			return nil, err
+		}
+		for _, kv := range kvs {
+			index, err := vect.indexAt(kv.Key)
+			if err != nil {
+				if vect.opts.SkipForeignKeys && errors.Is(err, ErrForeignKey) {
+					continue
+				}
+				return nil, err
+			}
+			found[index] = kv.Value
+		}
+	}
+	for i, f := range points {
+		b, err := f.Get()
+		if err != nil {
+			return nil, err
+		}
+		if b != nil {
+			found[singles[i]] = b
+		}
+	}
+
+	vals := make([]*Value, len(resolved))
+	for i, index := range resolved {
+		b, ok := found[index]
+		if !ok {
+			if vals[i], err = vect.sparseValue(index); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		info.addBytes(len(b))
+		if vals[i], err = ValUnpack(b); err != nil {
+			return nil, err
+		}
+	}
+	return vals, nil
+}
+
+// Get the stored items of several windows of the vector in one call, in
+// ascending index order. Each window is resolved against the size like
+// the range of GetRange and must not have a negative Step; overlapping
+// and adjacent windows are merged, and the remaining reads are all issued
+// before any is waited on. Items in more than one window are returned
+// once. Sparse items are not returned.
+func (vect *Vector) GetRanges(windows []VectRange, tr fdb.ReadTransaction) (_ []IndexValue, err error) {
+	info, err := vect.beforeOp("GetRanges", -1)
+	if err != nil {
+		return nil, err
+	}
+	defer vect.afterOp(info, &err)
+
+	if len(windows) == 0 {
+		return nil, nil
+	}
+
+	size, err := vect.Size(tr)
+	if err != nil {
+		return nil, err
+	}
+	spans := make([]indexRun, 0, len(windows))
+	for _, w := range windows {
+		if w.Step < 0 {
+			return nil, fmt.Errorf("vector.getranges: window %d:%d has negative step", w.Start, w.Stop)
+		}
+		w = w.resolve(size)
+		if w.Start >= w.Stop {
+			continue
+		}
+		spans = append(spans, indexRun{first: w.Start, last: w.Stop - 1})
+	}
+	spans = coalesce(spans)
+
+	ranges := make([]fdb.RangeResult, len(spans))
+	for i, span := range spans {
+		kr := fdb.KeyRange{Begin: vect.keyAt(span.first), End: vect.keyAt(span.last + 1)}
+		ranges[i] = tr.GetRange(kr, fdb.RangeOptions{Mode: fdb.StreamingModeWantAll})
+	}
+
+	var items []IndexValue
+	for _, rr := range ranges {
+		kvs, err := rr.GetSliceWithError()
+		if err != nil {
+			return nil, err
+		}
+		for _, kv := range kvs {
+			index, err := vect.indexAt(kv.Key)
+			if err != nil {
+				if vect.opts.SkipForeignKeys && errors.Is(err, ErrForeignKey) {
+					continue
+				}
+				return nil, err
+			}
+			info.addBytes(len(kv.Value))
+			val, err := ValUnpack(kv.Value)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, IndexValue{Index: index, Value: val})
+		}
+	}
+	return items, nil
+}
+
+// Split indexes into runs of at least multiGetMinRun consecutive indexes
+// and the indexes outside any run. Duplicates are read once.
+func planRuns(indexes []int64) (runs []indexRun, singles []int64) {
+	sorted := append([]int64{}, indexes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	for i := 0; i < len(sorted); {
+		run := indexRun{first: sorted[i], last: sorted[i]}
+		j := i + 1
+		for ; j < len(sorted) && sorted[j] <= run.last+1; j++ {
+			run.last = sorted[j]
+		}
+		if run.last-run.first+1 >= multiGetMinRun {
+			runs = append(runs, run)
+		} else {
+			singles = append(singles, run.first)
+		}
+		i = j
+	}
+	return runs, singles
+}
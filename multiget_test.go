@@ -0,0 +1,41 @@
+package vector
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestPlanRuns(t *testing.T) {
+
+	runs, singles := planRuns([]int64{7, 3, 4, 5, 5, 10, 20, 21, 1})
+
+	wantRuns := []indexRun{{3, 5}, {20, 21}}
+	if !reflect.DeepEqual(runs, wantRuns) {
+		t.Errorf("Expected runs %v, got %v", wantRuns, runs)
+	}
+	wantSingles := []int64{1, 7, 10}
+	if !reflect.DeepEqual(singles, wantSingles) {
+		t.Errorf("Expected singles %v, got %v", wantSingles, singles)
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+
+	got := coalesce([]indexRun{{10, 12}, {0, 3}, {4, 4}, {11, 20}, {30, 30}})
+	want := []indexRun{{0, 4}, {10, 20}, {30, 30}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %v, got %v", want, got)
+	}
+
+	p := NewReadPlan(nil)
+	vect := &Vector{}
+	p.Get(vect, 5)
+	p.Range(vect, 0, 5)
+	p.Range(vect, 6, 8)
+	if n := p.Reads(vect); n != 1 {
+		t.Errorf("Expected one coalesced read, got %d", n)
+	}
+	if _, err := p.Get(vect, 1).Value(); err == nil {
+		t.Error("Expected error reading a plan that was not executed")
+	}
+}
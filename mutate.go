@@ -0,0 +1,203 @@
+package vector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+/*
+ * Atomic mutations on single items. These never read the item, so many
+ * writers can hit the same index without conflicting. Each one only works
+ * on items stored in the layout it expects (see the individual methods);
+ * applying it to an item of another type corrupts the item.
+ */
+
+// Add delta to the Counter at index with the atomic ADD mutation. A missing
+// item counts as Counter(0). The item must be absent or have been written
+// as a Counter.
+func (vect *Vector) Add(index int64, delta int64, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("Add", index, err) }()
+
+	if err := vect.atomicAllowed("vector.add"); err != nil {
+		return err
+	}
+	if err := vect.mutation("Add", index, tr); err != nil {
+		return err
+	}
+	key, err := vect.prepareWrite("vector.add", index, tr)
+	if err != nil {
+		return err
+	}
+	tr.Add(key, counterParam(delta))
+	return nil
+}
+
+// Refuse the numeric atomic mutations on a vector with Options.Timestamps:
+// they would apply to the timestamp header instead of the value.
+func (vect *Vector) atomicAllowed(op string) error {
+	if vect.opts.Timestamps {
+		return fmt.Errorf("%s: atomic mutation on timestamped items: %w", op, ErrUnsupported)
+	}
+	return nil
+}
+
+// The ADD and BIT_* operand for delta. FDB adds values as little-endian
+// integers of the operand's length: the leading zero leaves the Counter
+// typecode (also zero) unchanged and the payload wraps like int64
+// arithmetic. The bitwise mutations work byte by byte and keep the zero
+// typecode as well. On a missing key the result is a valid Counter.
+func counterParam(delta int64) []byte {
+	buf := new(bytes.Buffer)
+	buf.WriteByte(0x00)
+	binary.Write(buf, binary.LittleEndian, delta)
+	return buf.Bytes()
+}
+
+// Store min(item, candidate) at index without reading the item. The item
+// must already hold an Ordered value: under the API versions this package
+// targets MIN treats a missing key as zero rather than taking the
+// candidate. Seed it with Set(index, Ordered(x), tr).
+//
+// Newer FDB releases offer BYTE_MIN/BYTE_MAX, but the bindings this package
+// builds against do not, so MIN/MAX (API version 300) over the Ordered
+// layout is used instead.
+func (vect *Vector) AtomicMin(index int64, candidate int64, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("AtomicMin", index, err) }()
+
+	if err := vect.atomicAllowed("vector.atomicmin"); err != nil {
+		return err
+	}
+	if err := vect.mutation("AtomicMin", index, tr); err != nil {
+		return err
+	}
+	key, err := vect.prepareWrite("vector.atomicmin", index, tr)
+	if err != nil {
+		return err
+	}
+	param, err := ValPack(Ordered(candidate))
+	if err != nil {
+		return err
+	}
+	tr.Min(key, param)
+	return nil
+}
+
+// Store max(item, candidate) at index without reading the item. A missing
+// item becomes Ordered(candidate); an existing one must hold an Ordered
+// value.
+func (vect *Vector) AtomicMax(index int64, candidate int64, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("AtomicMax", index, err) }()
+
+	if err := vect.atomicAllowed("vector.atomicmax"); err != nil {
+		return err
+	}
+	if err := vect.mutation("AtomicMax", index, tr); err != nil {
+		return err
+	}
+	key, err := vect.prepareWrite("vector.atomicmax", index, tr)
+	if err != nil {
+		return err
+	}
+	param, err := ValPack(Ordered(candidate))
+	if err != nil {
+		return err
+	}
+	tr.Max(key, param)
+	return nil
+}
+
+// Set the Counter at index to item | mask with the atomic BIT_OR mutation.
+// A missing item counts as Counter(0).
+func (vect *Vector) BitOr(index int64, mask int64, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("BitOr", index, err) }()
+
+	if err := vect.atomicAllowed("vector.bitor"); err != nil {
+		return err
+	}
+	if err := vect.mutation("BitOr", index, tr); err != nil {
+		return err
+	}
+	key, err := vect.prepareWrite("vector.bitor", index, tr)
+	if err != nil {
+		return err
+	}
+	tr.BitOr(key, counterParam(mask))
+	return nil
+}
+
+// Set the Counter at index to item & mask with the atomic BIT_AND mutation.
+// A missing item counts as Counter(0).
+func (vect *Vector) BitAnd(index int64, mask int64, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("BitAnd", index, err) }()
+
+	if err := vect.atomicAllowed("vector.bitand"); err != nil {
+		return err
+	}
+	if err := vect.mutation("BitAnd", index, tr); err != nil {
+		return err
+	}
+	key, err := vect.prepareWrite("vector.bitand", index, tr)
+	if err != nil {
+		return err
+	}
+	tr.BitAnd(key, counterParam(mask))
+	return nil
+}
+
+// Set the Counter at index to item ^ mask with the atomic BIT_XOR mutation.
+// A missing item counts as Counter(0).
+func (vect *Vector) BitXor(index int64, mask int64, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("BitXor", index, err) }()
+
+	if err := vect.atomicAllowed("vector.bitxor"); err != nil {
+		return err
+	}
+	if err := vect.mutation("BitXor", index, tr); err != nil {
+		return err
+	}
+	key, err := vect.prepareWrite("vector.bitxor", index, tr)
+	if err != nil {
+		return err
+	}
+	tr.BitXor(key, counterParam(mask))
+	return nil
+}
+
+// Transactions of bindings that expose the APPEND_IF_FITS mutation.
+type appendIfFitser interface {
+	AppendIfFits(key fdb.KeyConvertible, param []byte)
+}
+
+// Append suffix to the string at index with the atomic APPEND_IF_FITS
+// mutation. The item must already be a string (seed it with
+// Set(index, "", tr)); if the result would exceed the value size limit the
+// item is left unchanged.
+//
+// Returns ErrUnsupported when the fdb bindings in use do not expose the
+// mutation (it needs API version 510).
+func (vect *Vector) AppendString(index int64, suffix string, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("AppendString", index, err) }()
+
+	return vect.appendString(index, suffix, tr, tr)
+}
+
+// AppendString, with the mutation applied through t when it has
+// AppendIfFits.
+func (vect *Vector) appendString(index int64, suffix string, tr fdb.Transaction, t interface{}) error {
+	if err := vect.mutation("AppendString", index, tr); err != nil {
+		return err
+	}
+	appender, ok := t.(appendIfFitser)
+	if !ok {
+		return ErrUnsupported
+	}
+	key, err := vect.prepareWrite("vector.appendstring", index, tr)
+	if err != nil {
+		return err
+	}
+	appender.AppendIfFits(key, []byte(suffix))
+	return nil
+}
@@ -0,0 +1,262 @@
+package vector
+
+import (
+	"fmt"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+/*
+ * A Namespace keeps the vectors of each tenant under a shared root:
+ *
+ *	(tenant, "v", name, ...)         -> the vector called name
+ *	(tenant, "usage", "items")       -> stored items of all its vectors
+ *	(tenant, "usage", "bytes")       -> their packed value bytes
+ *	(tenant, "usage", name, "items") -> the same per vector, for Clear
+ *	(tenant, "usage", name, "bytes")
+ *
+ * The counters are little-endian integers changed with the atomic ADD
+ * mutation, so writers to different vectors of a tenant do not conflict.
+ */
+
+/*
+ * Quota - limits on what a tenant may store across all its vectors; 0
+ * means no limit.
+ */
+type Quota struct {
+	MaxItems int64
+	MaxBytes int64
+}
+
+/*
+ * Usage - what a tenant, or one of its vectors, stores.
+ */
+type Usage struct {
+	Items int64
+	Bytes int64
+}
+
+/*
+ * Namespace - the vectors of many tenants under one root, with usage
+ * tracked per tenant and a Quota enforced on writes made through
+ * TenantVector.
+ */
+type Namespace struct {
+	root  subspace.Subspace
+	quota Quota
+}
+
+/*
+ * TenantVector - a vector of a Namespace. Writes made through it are
+ * counted and checked against the quota; use Vector for reads. Writes
+ * made to the underlying Vector directly are not counted.
+ */
+type TenantVector struct {
+	ns     *Namespace
+	tenant string
+	name   string
+	vect   *Vector
+}
+
+// Create a Namespace storing its vectors under root, usually a directory
+// subspace, with quota applied to every tenant.
+func NewNamespace(root subspace.Subspace, quota Quota) *Namespace {
+	return &Namespace{root: root, quota: quota}
+}
+
+// Get the vector called name of tenant. Nothing is written until it is.
+func (ns *Namespace) Vector(tenant, name, defaultValue string) *TenantVector {
+	return &TenantVector{
+		ns:     ns,
+		tenant: tenant,
+		name:   name,
+		vect:   NewVector(ns.root.Sub(tenant, "v", name), defaultValue),
+	}
+}
+
+// Get what tenant stores across all its vectors.
+func (ns *Namespace) Usage(tenant string, tr fdb.ReadTransaction) (Usage, error) {
+	return ns.usage(ns.root.Sub(tenant, "usage"), tr)
+}
+
+func (ns *Namespace) usage(ss subspace.Subspace, tr fdb.ReadTransaction) (Usage, error) {
+	items := tr.Get(ss.Pack(tuple.Tuple{"items"}))
+	bytes := tr.Get(ss.Pack(tuple.Tuple{"bytes"}))
+	i, err := items.Get()
+	if err != nil {
+		return Usage{}, err
+	}
+	b, err := bytes.Get()
+	if err != nil {
+		return Usage{}, err
+	}
+	return Usage{Items: unpackCounter(i), Bytes: unpackCounter(b)}, nil
+}
+
+// Return a copy of the TenantVector using opts, see Vector.WithOptions.
+func (tv *TenantVector) WithOptions(opts Options) *TenantVector {
+	c := *tv
+	c.vect = tv.vect.WithOptions(opts)
+	return &c
+}
+
+// The underlying Vector, for reads.
+func (tv *TenantVector) Vector() *Vector {
+	return tv.vect
+}
+
+// Get what this vector stores.
+func (tv *TenantVector) Usage(tr fdb.ReadTransaction) (Usage, error) {
+	return tv.ns.usage(tv.ns.root.Sub(tv.tenant, "usage", tv.name), tr)
+}
+
+// Set the value at index, see Vector.Set. Fails with ErrQuotaExceeded if
+// the write would take the tenant over its quota.
+func (tv *TenantVector) Set(index int64, val interface{}, tr fdb.Transaction) error {
+	v, err := ValPack(val)
+	if err != nil {
+		return err
+	}
+	index, err = tv.vect.resolveIndex(index, tr)
+	if err != nil {
+		return err
+	}
+	if index < 0 {
+		return outOfRange("vector.set", index)
+	}
+	old, err := tr.Get(tv.vect.keyAt(index)).Get()
+	if err != nil {
+		return err
+	}
+
+	delta := Usage{Bytes: tv.storedLen(v)}
+	if old == nil {
+		delta.Items = 1
+	} else {
+		delta.Bytes -= int64(len(old))
+	}
+	if err := tv.checkQuota(delta, tr); err != nil {
+		return err
+	}
+	if err := tv.vect.Set(index, val, tr); err != nil {
+		return err
+	}
+	tv.count(delta, tr)
+	return nil
+}
+
+// Push an item onto the end, see Vector.Push. Fails with ErrQuotaExceeded
+// if the write would take the tenant over its quota.
+func (tv *TenantVector) Push(val interface{}, tr fdb.Transaction) error {
+	v, err := ValPack(val)
+	if err != nil {
+		return err
+	}
+	delta := Usage{Items: 1, Bytes: tv.storedLen(v)}
+	if err := tv.checkQuota(delta, tr); err != nil {
+		return err
+	}
+	if err := tv.vect.Push(val, tr); err != nil {
+		return err
+	}
+	tv.count(delta, tr)
+	return nil
+}
+
+// Pop the last item, see Vector.Pop. Popping never fails the quota, but
+// the default Pop stores in place of a sparse item before the last one is
+// counted.
+func (tv *TenantVector) Pop(tr fdb.Transaction) (*Value, error) {
+	ropts := fdb.RangeOptions{Limit: 2, Reverse: true}
+	lastTwo, err := tr.GetRange(tv.vect.indexRange(), ropts).GetSliceWithError()
+	if err != nil {
+		return nil, err
+	}
+	var delta Usage
+	if len(lastTwo) > 0 {
+		delta = Usage{Items: -1, Bytes: -int64(len(lastTwo[0].Value))}
+		last, err := tv.vect.indexAt(lastTwo[0].Key)
+		if err != nil {
+			return nil, err
+		}
+		prev := int64(-1)
+		if len(lastTwo) == 2 {
+			if prev, err = tv.vect.indexAt(lastTwo[1].Key); err != nil {
+				return nil, err
+			}
+		}
+		if last > 0 && prev < last-1 {
+			def, err := tv.vect.defaultPacked(last - 1)
+			if err != nil {
+				return nil, err
+			}
+			delta.Items++
+			delta.Bytes += int64(len(def))
+		}
+	}
+	val, err := tv.vect.Pop(tr)
+	if err != nil {
+		return nil, err
+	}
+	tv.count(delta, tr)
+	return val, nil
+}
+
+// Remove all items, see Vector.Clear.
+func (tv *TenantVector) Clear(tr fdb.Transaction) error {
+	own, err := tv.Usage(tr)
+	if err != nil {
+		return err
+	}
+	if err := tv.vect.ClearWithError(tr); err != nil {
+		return err
+	}
+	tv.count(Usage{Items: -own.Items, Bytes: -own.Bytes}, tr)
+	return nil
+}
+
+// Check that delta fits the tenant's quota; it is counted once the write
+// succeeds. The tenant's usage is read at snapshot isolation so concurrent
+// writers do not conflict on it; they can together overshoot the quota by
+// what they write at once.
+func (tv *TenantVector) checkQuota(delta Usage, tr fdb.Transaction) error {
+	quota := tv.ns.quota
+	if (quota.MaxItems > 0 && delta.Items > 0) || (quota.MaxBytes > 0 && delta.Bytes > 0) {
+		used, err := tv.ns.Usage(tv.tenant, tr.Snapshot())
+		if err != nil {
+			return err
+		}
+		if quota.MaxItems > 0 && delta.Items > 0 && used.Items+delta.Items > quota.MaxItems {
+			return fmt.Errorf("tenant %q: %d items, limit %d: %w", tv.tenant, used.Items+delta.Items, quota.MaxItems, ErrQuotaExceeded)
+		}
+		if quota.MaxBytes > 0 && delta.Bytes > 0 && used.Bytes+delta.Bytes > quota.MaxBytes {
+			return fmt.Errorf("tenant %q: %d bytes, limit %d: %w", tv.tenant, used.Bytes+delta.Bytes, quota.MaxBytes, ErrQuotaExceeded)
+		}
+	}
+	return nil
+}
+
+// Add delta to the tenant's and the vector's counters.
+func (tv *TenantVector) count(delta Usage, tr fdb.Transaction) {
+	for _, ss := range []subspace.Subspace{
+		tv.ns.root.Sub(tv.tenant, "usage"),
+		tv.ns.root.Sub(tv.tenant, "usage", tv.name),
+	} {
+		if delta.Items != 0 {
+			tr.Add(ss.Pack(tuple.Tuple{"items"}), packCounter(delta.Items))
+		}
+		if delta.Bytes != 0 {
+			tr.Add(ss.Pack(tuple.Tuple{"bytes"}), packCounter(delta.Bytes))
+		}
+	}
+}
+
+// The bytes a packed value takes once stored.
+func (tv *TenantVector) storedLen(packed []byte) int64 {
+	if tv.vect.opts.Timestamps {
+		return int64(len(packed)) + 9
+	}
+	return int64(len(packed))
+}
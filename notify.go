@@ -0,0 +1,50 @@
+package vector
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+// Get the key of the Options.Notify counter, ("dirty") in the vector's
+// subspace, for processes watching it without this package. It holds a
+// little-endian int64 count of mutations.
+func (vect *Vector) DirtyKey() fdb.Key {
+	return vect.subspace.Pack(tuple.Tuple{"dirty"})
+}
+
+// Bump the dirty counter, when Options.Notify is set. The atomic ADD adds
+// no read conflict, so concurrent writers do not collide on the key.
+func (vect *Vector) notify(tr fdb.Transaction) {
+	if !vect.opts.Notify {
+		return
+	}
+	tr.Add(vect.DirtyKey(), packCounter(1))
+}
+
+// Watch the vector's dirty counter: the future becomes ready once a
+// transaction mutating the vector commits after tr. Requires
+// Options.Notify on every handle writing the vector; returns
+// ErrNotEnabled otherwise. As with any watch, tr must be committed for
+// the watch to become active, and changes may be coalesced, so re-read
+// the vector after it fires rather than counting notifications.
+func (vect *Vector) WatchDirty(tr fdb.Transaction) (_ fdb.FutureNil, err error) {
+	defer func() { err = vect.opError("WatchDirty", -1, err) }()
+
+	if !vect.opts.Notify {
+		return nil, ErrNotEnabled
+	}
+	return tr.Watch(vect.DirtyKey()), nil
+}
+
+// Get the number of mutations recorded by the dirty counter, 0 if none
+// were made with Options.Notify set. Comparing two readings tells whether
+// the vector changed in between.
+func (vect *Vector) DirtyCount(tr fdb.ReadTransaction) (_ int64, err error) {
+	defer func() { err = vect.opError("DirtyCount", -1, err) }()
+
+	b, err := tr.Get(vect.DirtyKey()).Get()
+	if err != nil || b == nil {
+		return 0, err
+	}
+	return unpackCounter(b), nil
+}
@@ -0,0 +1,267 @@
+package vector
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+// Multiply every stored numeric item by factor, cloneChunkSize indexes per
+// transaction, reporting progress like ZipWith. The result is computed in
+// float64; ints, Counter and Ordered items keep their layout when it is
+// integral. Otherwise a plain int is written back as a float, which a
+// Homogeneous vector rejects, and a Counter or Ordered item fails with a
+// *TypeMismatchError. Sparse items are left sparse, so they keep reading
+// as the default. Any other item fails with a *TypeMismatchError, leaving
+// the chunks before it rewritten, since the chunks are separate
+// transactions.
+func (vect *Vector) Scale(db fdb.Transactor, factor float64) (err error) {
+	defer func() { err = vect.opError("Scale", -1, err) }()
+
+	return vect.rewriteNumeric(db, "Scale", func(x float64) float64 { return x * factor })
+}
+
+// Like Scale, adding delta to every stored numeric item.
+func (vect *Vector) AddScalar(db fdb.Transactor, delta float64) (err error) {
+	defer func() { err = vect.opError("AddScalar", -1, err) }()
+
+	return vect.rewriteNumeric(db, "AddScalar", func(x float64) float64 { return x + delta })
+}
+
+// Rewrite every stored item x with fn(x), one UpdateRange per chunk of
+// indexes.
+func (vect *Vector) rewriteNumeric(db fdb.Transactor, op string, fn func(x float64) float64) error {
+	p := Progress{Op: op, Last: -1}
+	for next := int64(0); ; next += cloneChunkSize {
+		var visited, last int64
+		r, err := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			visited, last = 0, -1
+			err := vect.UpdateRange(next, next+cloneChunkSize, func(index int64, val *Value) (interface{}, error) {
+				visited++
+				last = index
+				if err := checkNumeric(index, val); err != nil {
+					return nil, err
+				}
+				return numericResult(index, val, fn(numericFloat(val)))
+			}, tr)
+			if err != nil {
+				return nil, err
+			}
+			return vect.Size(tr)
+		})
+		if err != nil {
+			return err
+		}
+		done := next+cloneChunkSize >= r.(int64)
+		vect.reportProgress(&p, visited, 0, last, done)
+		if done {
+			return nil
+		}
+	}
+}
+
+// Like Vector.Scale, for a ChunkedVector. Every element below Size is
+// rewritten, including elements never written, which read as 0 before:
+// the vector is dense, and the chunks they fall in are stored in full.
+func (cv *ChunkedVector) Scale(db fdb.Transactor, factor float64) error {
+	return cv.rewrite(db, func(x float64) float64 { return x * factor })
+}
+
+// Like Vector.AddScalar, for a ChunkedVector. As with Scale, elements
+// never written are rewritten too, and read as delta afterwards.
+func (cv *ChunkedVector) AddScalar(db fdb.Transactor, delta float64) error {
+	return cv.rewrite(db, func(x float64) float64 { return x + delta })
+}
+
+// Rewrite every element x below Size with fn(x), about cloneChunkSize
+// elements per transaction. Each chunk is read and written whole, so the
+// cost is a key per chunk rather than per element; missing and short
+// chunks are filled.
+func (cv *ChunkedVector) rewrite(db fdb.Transactor, fn func(x float64) float64) error {
+	perTx := cloneChunkSize / cv.chunkSize
+	if perTx < 1 {
+		perTx = 1
+	}
+	for first := int64(0); ; first += perTx {
+		r, err := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			size, err := cv.Size(tr)
+			if err != nil {
+				return nil, err
+			}
+			start := first * cv.chunkSize
+			stop := (first + perTx) * cv.chunkSize
+			if stop > size {
+				stop = size
+			}
+			if start >= stop {
+				return true, nil
+			}
+			chunks := make(map[int64][]byte)
+			err = cv.readChunks(start, stop, tr, func(base int64, b []byte) error {
+				chunks[base/cv.chunkSize] = b
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+			for base := start; base < stop; base += cv.chunkSize {
+				n := stop - base
+				if n > cv.chunkSize {
+					n = cv.chunkSize
+				}
+				b := chunks[base/cv.chunkSize]
+				out := make([]byte, n*8)
+				for i := int64(0); i < n; i++ {
+					putChunkElem(out, i, fn(chunkElem(b, i)))
+				}
+				tr.Set(cv.chunkKey(base/cv.chunkSize), out)
+			}
+			return stop == size, nil
+		})
+		if err != nil {
+			return err
+		}
+		if r.(bool) {
+			return nil
+		}
+	}
+}
+
+// The item to store for x, fn's result for val at index: ints, Counter
+// and Ordered items keep their layout when x is integral, so Homogeneous
+// vectors and the atomic mutations keep working on them. Otherwise a
+// plain int becomes a float, and a Counter or Ordered item fails.
+func numericResult(index int64, val *Value, x float64) (interface{}, error) {
+	if !val.IsInt {
+		return x, nil
+	}
+	integral := x == math.Trunc(x) && x >= math.MinInt64 && x < math.MaxInt64
+	code := LazyValue{raw: val.Raw}.Typecode()
+	switch {
+	case integral && code == 0x00:
+		return Counter(int64(x)), nil
+	case integral && code == 0x04:
+		return Ordered(int64(x)), nil
+	case integral:
+		return int64(x), nil
+	case code == 0x00 || code == 0x04:
+		return nil, &TypeMismatchError{Index: index, Want: typeName(byte(code)), Got: "float"}
+	}
+	return x, nil
+}
+
+// Compute the inner product of a and b, streaming both side by side with
+// Zip in tr. Sparse items count as their default, 0 when it is typeless,
+// and the indexes past the end of the shorter vector as 0. Any item that
+// is not a number fails with a *TypeMismatchError. For vectors too large
+// for one transaction, sum Dot over slices, see DotRange.
+func Dot(a, b *Vector, tr fdb.ReadTransaction) (float64, error) {
+	return DotRange(a, b, VectRange{}, tr)
+}
+
+// Like Dot, over the indexes in vro only, resolved as by Zip.
+func DotRange(a, b *Vector, vro VectRange, tr fdb.ReadTransaction) (float64, error) {
+	z, err := Zip(a, b, vro, tr)
+	if err != nil {
+		return 0, err
+	}
+	var sum float64
+	for z.Advance() {
+		pair := z.Get()
+		if err := checkNumeric(pair.Index, pair.A); err != nil {
+			return 0, err
+		}
+		if err := checkNumeric(pair.Index, pair.B); err != nil {
+			return 0, err
+		}
+		sum += numericFloat(pair.A) * numericFloat(pair.B)
+	}
+	return sum, z.Err()
+}
+
+// Compute the Euclidean norm of the stored numeric items, in one
+// streaming pass in tr. Sparse items count as 0.
+func (vect *Vector) L2Norm(tr fdb.ReadTransaction) (_ float64, err error) {
+	defer func() { err = vect.opError("L2Norm", -1, err) }()
+
+	sq, err := vect.sumSquares(VectRange{}, tr)
+	return math.Sqrt(sq), err
+}
+
+// Scale the vector to an L2 norm of 1, for cosine similarity by Dot. The
+// norm is summed and the items rewritten cloneChunkSize indexes per
+// transaction, so vectors of any size can be normalized; writes made
+// meanwhile may leave the result slightly off. Fails on a vector whose
+// norm is 0.
+func (vect *Vector) NormalizeInPlace(db fdb.Transactor) (err error) {
+	defer func() { err = vect.opError("NormalizeInPlace", -1, err) }()
+
+	var sq float64
+	for next := int64(0); ; next += cloneChunkSize {
+		var part float64
+		r, err := db.ReadTransact(func(tr fdb.ReadTransaction) (interface{}, error) {
+			var err error
+			if part, err = vect.sumSquares(VectRange{Start: next, Stop: next + cloneChunkSize}, tr); err != nil {
+				return nil, err
+			}
+			return vect.Size(tr)
+		})
+		if err != nil {
+			return err
+		}
+		sq += part
+		if next+cloneChunkSize >= r.(int64) {
+			break
+		}
+	}
+	if sq == 0 {
+		return fmt.Errorf("vector.normalize: norm is 0")
+	}
+	return vect.Scale(db, 1/math.Sqrt(sq))
+}
+
+// Sum of the squares of the stored items in vro.
+func (vect *Vector) sumSquares(vro VectRange, tr fdb.ReadTransaction) (float64, error) {
+	col, err := vect.ScanNumeric(vro, tr)
+	if err != nil {
+		return 0, err
+	}
+	var sq float64
+	for _, n := range col.Ints {
+		sq += float64(n) * float64(n)
+	}
+	for _, f := range col.Floats {
+		sq += f * f
+	}
+	return sq, nil
+}
+
+// Compute the Euclidean norm of the elements, in one range read in tr.
+func (cv *ChunkedVector) L2Norm(tr fdb.ReadTransaction) (float64, error) {
+	vals, err := cv.GetRange(VectRange{}, tr)
+	if err != nil {
+		return 0, err
+	}
+	var sq float64
+	for _, v := range vals {
+		sq += v * v
+	}
+	return math.Sqrt(sq), nil
+}
+
+// Like Vector.NormalizeInPlace, for a ChunkedVector. The norm is read in
+// one transaction, the rewrite is chunked as by Scale.
+func (cv *ChunkedVector) NormalizeInPlace(db fdb.Transactor) error {
+	r, err := db.ReadTransact(func(tr fdb.ReadTransaction) (interface{}, error) {
+		return cv.L2Norm(tr)
+	})
+	if err != nil {
+		return err
+	}
+	norm := r.(float64)
+	if norm == 0 {
+		return fmt.Errorf("chunkedvector.normalize: norm is 0")
+	}
+	return cv.Scale(db, 1/norm)
+}
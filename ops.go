@@ -0,0 +1,112 @@
+package vector
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+/*
+ * OpKind - the mutation an Op applies.
+ */
+type OpKind int
+
+const (
+	OpSet   OpKind = iota // store Value at Index
+	OpClear               // clear the item at Index, leaving it sparse
+	OpPush                // push Value onto the end
+	OpFill                // store Value at every index in [Index, Stop)
+)
+
+/*
+ * Op - one mutation for ApplyOps. Use the SetOp, ClearOp, PushOp and
+ * FillOp constructors, or fill in the fields the Kind uses.
+ */
+type Op struct {
+	Kind  OpKind
+	Index int64
+	Stop  int64 // end of an OpFill span, exclusive
+	Value interface{}
+}
+
+func SetOp(index int64, val interface{}) Op {
+	return Op{Kind: OpSet, Index: index, Value: val}
+}
+
+func ClearOp(index int64) Op {
+	return Op{Kind: OpClear, Index: index}
+}
+
+func PushOp(val interface{}) Op {
+	return Op{Kind: OpPush, Value: val}
+}
+
+func FillOp(start, stop int64, val interface{}) Op {
+	return Op{Kind: OpFill, Index: start, Stop: stop, Value: val}
+}
+
+// Apply ops in order and report, for each, the error that kept it from
+// being applied: a value that cannot be encoded, an index out of range or
+// a sparse write in Dense mode. Failed ops are skipped and the rest still
+// applied. A database error aborts the call and is returned on its own,
+// so the transaction can be retried as a whole.
+//
+// Clearing the last item shortens the vector to the item before it.
+func (vect *Vector) ApplyOps(ops []Op, tr fdb.Transaction) (_ []error, err error) {
+	defer func() { err = vect.opError("ApplyOps", -1, err) }()
+
+	errs := make([]error, len(ops))
+	for i, op := range ops {
+		err := vect.applyOp(op, tr)
+		var fe fdb.Error
+		if errors.As(err, &fe) {
+			return nil, err
+		}
+		errs[i] = err
+	}
+	return errs, nil
+}
+
+func (vect *Vector) applyOp(op Op, tr fdb.Transaction) error {
+	switch op.Kind {
+	case OpSet:
+		return vect.Set(op.Index, op.Value, tr)
+	case OpPush:
+		return vect.Push(op.Value, tr)
+	case OpClear:
+		if err := vect.mutation("Clear", op.Index, tr); err != nil {
+			return err
+		}
+		index, err := vect.resolveIndex(op.Index, tr)
+		if err != nil {
+			return err
+		}
+		if index < 0 {
+			return outOfRange("vector.clear", op.Index)
+		}
+		return vect.removeAt(index, tr)
+	case OpFill:
+		if err := vect.mutation("Fill", op.Index, tr); err != nil {
+			return err
+		}
+		v, err := ValPack(op.Value)
+		if err != nil {
+			return err
+		}
+		if op.Index < 0 || op.Stop < op.Index {
+			return outOfRange("vector.fill", op.Index)
+		}
+		for index := op.Index; index < op.Stop; index++ {
+			key, err := vect.prepareWrite("vector.fill", index, tr)
+			if err != nil {
+				return err
+			}
+			if err := vect.setKey(key, v, tr); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("fdb-vector unknown op kind %d", op.Kind)
+}
@@ -0,0 +1,133 @@
+package vector
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+// Leading bytes of a marshaled Patch.
+var patchMagic = []byte("FDBVP1")
+
+/*
+ * Patch - the differences produced by Diff, turning the first vector into
+ * the second. Patches marshal to a compact binary form to be shipped to
+ * other clusters and applied there with ApplyPatch. Values are kept in
+ * their packed form, so a patch reproduces the values exactly. Timestamps
+ * are not carried over: the vector applying the patch stamps the items it
+ * writes if it has Options.Timestamps. Build patches with Diff or
+ * UnmarshalBinary only.
+ */
+type Patch []Difference
+
+// Marshal the patch: magic, then per difference its index (varint), a
+// flag byte (1 old present, 2 new present) and the present packed values,
+// each preceded by its length (uvarint).
+func (p Patch) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	var n [binary.MaxVarintLen64]byte
+	buf.Write(patchMagic)
+	for _, d := range p {
+		buf.Write(n[:binary.PutVarint(n[:], d.Index)])
+		var flags byte
+		if d.oldRaw != nil {
+			flags |= 1
+		}
+		if d.newRaw != nil {
+			flags |= 2
+		}
+		buf.WriteByte(flags)
+		for _, raw := range [][]byte{d.oldRaw, d.newRaw} {
+			if raw != nil {
+				buf.Write(n[:binary.PutUvarint(n[:], uint64(len(raw)))])
+				buf.Write(raw)
+			}
+		}
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal a patch written by MarshalBinary.
+func (p *Patch) UnmarshalBinary(data []byte) error {
+	if !bytes.HasPrefix(data, patchMagic) {
+		return fmt.Errorf("vector.patch: bad header")
+	}
+	data = data[len(patchMagic):]
+
+	var patch Patch
+	for len(data) > 0 {
+		var d Difference
+		index, n := binary.Varint(data)
+		if n <= 0 || len(data) == n {
+			return fmt.Errorf("vector.patch: truncated difference")
+		}
+		d.Index = index
+		flags := data[n]
+		data = data[n+1:]
+
+		for bit, raw := range []*[]byte{&d.oldRaw, &d.newRaw} {
+			if flags&(1<<uint(bit)) == 0 {
+				continue
+			}
+			size, n := binary.Uvarint(data)
+			if n <= 0 || uint64(len(data)-n) < size {
+				return fmt.Errorf("vector.patch: truncated value at index %d", index)
+			}
+			*raw = append([]byte{}, data[n:n+int(size)]...)
+			data = data[n+int(size):]
+		}
+
+		var err error
+		if d.oldRaw != nil {
+			if d.Old, err = ValUnpack(d.oldRaw); err != nil {
+				return err
+			}
+		}
+		if d.newRaw != nil {
+			if d.New, err = ValUnpack(d.newRaw); err != nil {
+				return err
+			}
+		}
+		patch = append(patch, d)
+	}
+	*p = patch
+	return nil
+}
+
+// Apply the patch to the vector. Every difference is checked against the
+// current item first, ignoring timestamps; if one does not hold the old
+// value the patch expects, an error matching ErrPatchConflict is returned
+// and the caller should abort the transaction.
+func (vect *Vector) ApplyPatch(p Patch, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("ApplyPatch", -1, err) }()
+
+	if err := vect.mutation("ApplyPatch", -1, tr); err != nil {
+		return err
+	}
+	for _, d := range p {
+		cur, err := tr.Get(vect.keyAt(d.Index)).Get()
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(LazyValue{raw: cur}.body(), LazyValue{raw: d.oldRaw}.body()) {
+			return fmt.Errorf("vector.applypatch: index %d: %w", d.Index, ErrPatchConflict)
+		}
+
+		if d.newRaw == nil {
+			if err := vect.removeAt(d.Index, tr); err != nil {
+				return err
+			}
+			continue
+		}
+		key, err := vect.prepareWrite("vector.applypatch", d.Index, tr)
+		if err != nil {
+			return err
+		}
+		if err := vect.setKey(key, LazyValue{raw: d.newRaw}.body(), tr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
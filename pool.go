@@ -0,0 +1,56 @@
+package vector
+
+import "sync"
+
+/*
+ * Pools for high-throughput pipelines. Ownership rules:
+ *
+ *   - A Value from AcquireValue belongs to the caller until it is passed
+ *     to ReleaseValue; after that neither it nor its Bytes may be used.
+ *   - A buffer from AcquireBuffer belongs to the caller until it is passed
+ *     to ReleaseBuffer. Transaction writes copy their arguments, so a
+ *     buffer may be released as soon as the Set (or Push, ...) using it
+ *     has returned.
+ *
+ * Releasing is optional; an unreleased object is simply garbage collected.
+ */
+
+// Buffers above this size are not pooled, to keep one huge value from
+// pinning its memory.
+const maxPooledBuffer = 64 << 10
+
+var valuePool = sync.Pool{
+	New: func() interface{} { return new(Value) },
+}
+
+var bufferPool = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 256)
+		return &b
+	},
+}
+
+// Get a zeroed Value, e.g. to decode into with Vectorator.GetInto.
+func AcquireValue() *Value {
+	return valuePool.Get().(*Value)
+}
+
+// Give v back to the pool.
+func ReleaseValue(v *Value) {
+	*v = Value{}
+	valuePool.Put(v)
+}
+
+// Get an empty buffer, e.g. to pack into with AppendPack.
+func AcquireBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+// Give b back to the pool.
+func ReleaseBuffer(b *[]byte) {
+	if cap(*b) > maxPooledBuffer {
+		return
+	}
+	*b = (*b)[:0]
+	bufferPool.Put(b)
+}
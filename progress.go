@@ -0,0 +1,47 @@
+package vector
+
+/*
+ * Progress - how far a multi-transaction operation (BackupTo,
+ * SnapshotClone, ZipWith) has got, reported after every committed chunk.
+ * Items and Bytes count what this call has processed so far; Bytes is 0
+ * where the operation does not see packed values. Last is the last index
+ * done, the place to resume from after a failure, or -1 before the first
+ * item. Done is set on the final report.
+ */
+type Progress struct {
+	Op    string
+	Items int64
+	Bytes int64
+	Last  int64
+	Done  bool
+}
+
+/*
+ * ProgressFunc - receives Progress reports, see WithProgress. It runs on
+ * the goroutine doing the work, between transactions, so it should return
+ * quickly.
+ */
+type ProgressFunc func(p Progress)
+
+// Return a copy of the Vector reporting the progress of its
+// multi-transaction operations to fn. For ZipWith the reports come from
+// the dest vector.
+func (vect *Vector) WithProgress(fn ProgressFunc) *Vector {
+	v := *vect
+	v.progress = fn
+	return &v
+}
+
+// Add a committed chunk to p and report it.
+func (vect *Vector) reportProgress(p *Progress, items, bytes, last int64, done bool) {
+	if vect.progress == nil {
+		return
+	}
+	p.Items += items
+	p.Bytes += bytes
+	if items > 0 {
+		p.Last = last
+	}
+	p.Done = done
+	vect.progress(*p)
+}
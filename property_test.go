@@ -0,0 +1,85 @@
+package vector_test
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	vector "github.com/dedalcom/fdb-vector"
+	"github.com/dedalcom/fdb-vector/vectortest"
+)
+
+// Reference model: a Go slice, nil marking a sparse item.
+type model []interface{}
+
+func TestAgainstModel(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	vect, err := vector.Open(db, []string{"tests", "property"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	for seed := int64(0); seed < 20; seed++ {
+		_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			return nil, runModel(vect, seed, tr)
+		})
+		if e != nil {
+			t.Errorf("seed %d: %v", seed, e)
+		}
+	}
+}
+
+// Apply the same 200 random operations to vect and the model, checking
+// they agree after each one.
+func runModel(vect *vector.Vector, seed int64, tr fdb.Transaction) error {
+	rnd := rand.New(rand.NewSource(seed))
+	vals := vectortest.Values(seed, 200, vectortest.Mixed)
+
+	vect.Clear(tr)
+	var m model
+	for i, val := range vals {
+		var op string
+		switch r := rnd.Intn(10); {
+		case r < 4:
+			op = "push"
+			if err := vect.Push(val, tr); err != nil {
+				return err
+			}
+			m = append(m, val)
+		case r < 7:
+			index := rnd.Int63n(int64(len(m)) + 5)
+			op = fmt.Sprintf("set %d", index)
+			if err := vect.Set(index, val, tr); err != nil {
+				return err
+			}
+			for int64(len(m)) <= index {
+				m = append(m, nil)
+			}
+			m[index] = val
+		case r < 9:
+			op = "pop"
+			if _, err := vect.Pop(tr); err != nil {
+				return err
+			}
+			if len(m) > 0 {
+				m = m[:len(m)-1]
+				// a sparse item that becomes the last one is stored
+				if len(m) > 0 && m[len(m)-1] == nil {
+					m[len(m)-1] = ""
+				}
+			}
+		default:
+			op = "clear"
+			vect.Clear(tr)
+			m = nil
+		}
+
+		if err := vectortest.Equal(vect, m, tr); err != nil {
+			return fmt.Errorf("op %d (%s): %v", i, op, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,68 @@
+package vector
+
+import "github.com/FoundationDB/fdb-go/fdb"
+
+/*
+ * ReadOnlyVector - a handle on a Vector that only exposes the read
+ * methods, all of which accept an fdb.ReadTransaction (a Transaction, or a
+ * Snapshot of one). Hand it out where callers must not be able to modify
+ * the vector.
+ */
+type ReadOnlyVector struct {
+	vect *Vector
+}
+
+// Get a read-only handle on the Vector.
+func (vect *Vector) Freeze() *ReadOnlyVector {
+	return &ReadOnlyVector{vect: vect}
+}
+
+// Get the number of items in the Vector. This number includes the sparsely represented items.
+func (ro *ReadOnlyVector) Size(tr fdb.ReadTransaction) (int64, error) {
+	return ro.vect.Size(tr)
+}
+
+// Get the item at the specified index.
+func (ro *ReadOnlyVector) Get(index int64, tr fdb.ReadTransaction) (*Value, error) {
+	return ro.vect.Get(index, tr)
+}
+
+// Get the value of the last item in the Vector.
+func (ro *ReadOnlyVector) Back(tr fdb.ReadTransaction) (*Value, error) {
+	return ro.vect.Back(tr)
+}
+
+// Get the value of the first item in the Vector.
+func (ro *ReadOnlyVector) Front(tr fdb.ReadTransaction) (*Value, error) {
+	return ro.vect.Front(tr)
+}
+
+// Get up to n stored items from the end of the Vector, last item first.
+func (ro *ReadOnlyVector) Peek(n int, tr fdb.ReadTransaction) ([]IndexValue, error) {
+	return ro.vect.Peek(n, tr)
+}
+
+// Get up to n stored items from the start of the Vector, first item first.
+func (ro *ReadOnlyVector) PeekFront(n int, tr fdb.ReadTransaction) ([]IndexValue, error) {
+	return ro.vect.PeekFront(n, tr)
+}
+
+// Get a range of items in the Vector, returned as a generator.
+func (ro *ReadOnlyVector) GetRange(vro VectRange, tr fdb.ReadTransaction) (*Vectorator, error) {
+	return ro.vect.GetRange(vro, tr)
+}
+
+// Iterate over the stored items in vro.
+func (ro *ReadOnlyVector) GetStoredRange(vro VectRange, tr fdb.ReadTransaction) (*SparseIterator, error) {
+	return ro.vect.GetStoredRange(vro, tr)
+}
+
+// Return the stored items in vro for which fn returns true.
+func (ro *ReadOnlyVector) Filter(fn Predicate, vro VectRange, tr fdb.ReadTransaction) ([]IndexValue, error) {
+	return ro.vect.Filter(fn, vro, tr)
+}
+
+// Count the stored items in vro for which fn returns true.
+func (ro *ReadOnlyVector) CountWhere(fn Predicate, vro VectRange, tr fdb.ReadTransaction) (int64, error) {
+	return ro.vect.CountWhere(fn, vro, tr)
+}
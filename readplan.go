@@ -0,0 +1,357 @@
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+/*
+ * ReadPlan - collects the reads a transaction is going to need and issues
+ * them together. Requested indexes and ranges of the same vector that
+ * overlap or touch are coalesced into one GetRange, and every range is
+ * in flight before Execute blocks on any of them. Results are read from
+ * the returned handles once Execute has returned.
+ *
+ * Besides single items and ranges, a plan takes the reads of MultiGet
+ * (MultiGet), of Zip (Zip) and of a whole window of items with defaults
+ * (PlannedRange.Values), so helpers reading overlapping parts of the same
+ * vectors share their range reads.
+ */
+type ReadPlan struct {
+	tr    fdb.ReadTransaction
+	reads map[*Vector]*plannedReads
+	order []*Vector // vectors in the order first planned
+	done  bool
+}
+
+// The reads planned on one vector and, after Execute, their results.
+type plannedReads struct {
+	spans []indexRun
+	size  int64
+	found map[int64][]byte
+}
+
+/*
+ * PlannedValue - the item at one index of a ReadPlan.
+ */
+type PlannedValue struct {
+	plan  *ReadPlan
+	vect  *Vector
+	index int64
+}
+
+/*
+ * PlannedValues - the items at several indexes of a ReadPlan, see
+ * ReadPlan.MultiGet.
+ */
+type PlannedValues struct {
+	plan    *ReadPlan
+	vect    *Vector
+	indexes []int64
+}
+
+/*
+ * PlannedRange - the stored items of a range of a ReadPlan.
+ */
+type PlannedRange struct {
+	plan        *ReadPlan
+	vect        *Vector
+	start, stop int64
+}
+
+/*
+ * PlannedZip - two vectors side by side over a range of a ReadPlan, see
+ * ReadPlan.Zip.
+ */
+type PlannedZip struct {
+	a, b        *PlannedRange
+	start, stop int64
+}
+
+// Start an empty plan reading with tr.
+func NewReadPlan(tr fdb.ReadTransaction) *ReadPlan {
+	return &ReadPlan{tr: tr, reads: map[*Vector]*plannedReads{}}
+}
+
+// The reads planned on vect, registering it so its size is read even if
+// no range of it is.
+func (p *ReadPlan) vector(vect *Vector) *plannedReads {
+	r, ok := p.reads[vect]
+	if !ok {
+		r = &plannedReads{}
+		p.reads[vect] = r
+		p.order = append(p.order, vect)
+	}
+	return r
+}
+
+func (p *ReadPlan) add(vect *Vector, run indexRun) {
+	r := p.vector(vect)
+	r.spans = append(r.spans, run)
+}
+
+// Plan reading the item at index, which must not be negative.
+func (p *ReadPlan) Get(vect *Vector, index int64) *PlannedValue {
+	p.vector(vect)
+	if index >= 0 {
+		p.add(vect, indexRun{first: index, last: index})
+	}
+	return &PlannedValue{plan: p, vect: vect, index: index}
+}
+
+// Plan reading the stored items in [start, stop).
+func (p *ReadPlan) Range(vect *Vector, start, stop int64) *PlannedRange {
+	if start < 0 {
+		start = 0
+	}
+	p.vector(vect)
+	if stop > start {
+		p.add(vect, indexRun{first: start, last: stop - 1})
+	}
+	return &PlannedRange{plan: p, vect: vect, start: start, stop: stop}
+}
+
+// Plan reading the items at indexes, like Vector.MultiGet. Indexes must not
+// be negative; consecutive ones end up in the same range read.
+func (p *ReadPlan) MultiGet(vect *Vector, indexes []int64) *PlannedValues {
+	p.vector(vect)
+	for _, index := range indexes {
+		if index >= 0 {
+			p.add(vect, indexRun{first: index, last: index})
+		}
+	}
+	return &PlannedValues{plan: p, vect: vect, indexes: indexes}
+}
+
+// Plan iterating a and b side by side over [start, stop), like Zip. stop
+// is cut to the size of the longer vector once the plan is executed.
+func (p *ReadPlan) Zip(a, b *Vector, start, stop int64) *PlannedZip {
+	if start < 0 {
+		start = 0
+	}
+	return &PlannedZip{a: p.Range(a, start, stop), b: p.Range(b, start, stop), start: start, stop: stop}
+}
+
+// Issue every planned read, coalesced, and wait for all of them. The size
+// of each vector is read alongside so Get handles can tell sparse items
+// from indexes past the end.
+func (p *ReadPlan) Execute() error {
+	if p.done {
+		return fmt.Errorf("fdb-vector read plan already executed")
+	}
+	p.done = true
+
+	type pending struct {
+		vect *Vector
+		rr   fdb.RangeResult
+	}
+	var inflight []pending
+	for _, vect := range p.order {
+		r := p.reads[vect]
+		r.spans = coalesce(r.spans)
+		r.found = map[int64][]byte{}
+		for _, span := range r.spans {
+			kr := fdb.KeyRange{Begin: vect.keyAt(span.first), End: vect.keyAt(span.last + 1)}
+			inflight = append(inflight, pending{vect, p.tr.GetRange(kr, fdb.RangeOptions{})})
+		}
+	}
+
+	sizes := make([]SizeFuture, len(p.order))
+	for i, vect := range p.order {
+		sizes[i] = vect.SizeAsync(p.tr)
+	}
+	for i, vect := range p.order {
+		size, err := sizes[i].Get()
+		if err != nil {
+			return err
+		}
+		p.reads[vect].size = size
+	}
+
+	for _, pr := range inflight {
+		kvs, err := pr.rr.GetSliceWithError()
+		if err != nil {
+			return err
+		}
+		r := p.reads[pr.vect]
+		for _, kv := range kvs {
+			index, err := pr.vect.indexAt(kv.Key)
+			if err != nil {
+				if pr.vect.opts.SkipForeignKeys && errors.Is(err, ErrForeignKey) {
+					continue
+				}
+				return err
+			}
+			r.found[index] = kv.Value
+		}
+	}
+	return nil
+}
+
+// The number of range reads Execute issues (or issued) for vect.
+func (p *ReadPlan) Reads(vect *Vector) int {
+	r, ok := p.reads[vect]
+	if !ok {
+		return 0
+	}
+	return len(coalesce(r.spans))
+}
+
+func (p *ReadPlan) results(vect *Vector) (*plannedReads, error) {
+	if !p.done {
+		return nil, fmt.Errorf("fdb-vector read plan not executed")
+	}
+	return p.reads[vect], nil
+}
+
+// The planned item, like Vector.Get: the default for a sparse item and an
+// out of range error past the end.
+func (pv *PlannedValue) Value() (*Value, error) {
+	r, err := pv.plan.results(pv.vect)
+	if err != nil {
+		return nil, err
+	}
+	if pv.index < 0 || pv.index >= r.size {
+		return nil, outOfRange("vector.get", pv.index)
+	}
+	b, ok := r.found[pv.index]
+	if !ok {
+		return pv.vect.sparseValue(pv.index)
+	}
+	return ValUnpack(b)
+}
+
+// The planned items in the order of the indexes, like Vector.MultiGet:
+// sparse items get the default and any index past the end fails.
+func (pv *PlannedValues) Values() ([]*Value, error) {
+	r, err := pv.plan.results(pv.vect)
+	if err != nil {
+		return nil, err
+	}
+	vals := make([]*Value, len(pv.indexes))
+	for i, index := range pv.indexes {
+		if index < 0 || index >= r.size {
+			return nil, outOfRange("vector.multiget", index)
+		}
+		b, ok := r.found[index]
+		if !ok {
+			if vals[i], err = pv.vect.sparseValue(index); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if vals[i], err = ValUnpack(b); err != nil {
+			return nil, err
+		}
+	}
+	return vals, nil
+}
+
+// Every item of the planned range up to the end of the vector, sparse
+// ones as the default, so vals[i] is the item at start+i.
+func (pr *PlannedRange) Values() ([]*Value, error) {
+	r, err := pr.plan.results(pr.vect)
+	if err != nil {
+		return nil, err
+	}
+	stop := pr.stop
+	if stop > r.size {
+		stop = r.size
+	}
+	if stop <= pr.start {
+		return []*Value{}, nil
+	}
+	vals := make([]*Value, stop-pr.start)
+	for i := range vals {
+		index := pr.start + int64(i)
+		b, ok := r.found[index]
+		if !ok {
+			if vals[i], err = pr.vect.sparseValue(index); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if vals[i], err = ValUnpack(b); err != nil {
+			return nil, err
+		}
+	}
+	return vals, nil
+}
+
+// A Zipper over the planned range, visiting indexes like the one Zip
+// returns but reading the items from the plan.
+func (pz *PlannedZip) Zipper() (*Zipper, error) {
+	ra, err := pz.a.plan.results(pz.a.vect)
+	if err != nil {
+		return nil, err
+	}
+	rb, err := pz.b.plan.results(pz.b.vect)
+	if err != nil {
+		return nil, err
+	}
+	size := ra.size
+	if rb.size > size {
+		size = rb.size
+	}
+	stop := pz.stop
+	if stop > size {
+		stop = size
+	}
+	start := pz.start
+	if start > stop {
+		start = stop
+	}
+	return &Zipper{
+		a:     zipSide{vect: pz.a.vect, size: ra.size, found: ra.found},
+		b:     zipSide{vect: pz.b.vect, size: rb.size, found: rb.found},
+		index: start,
+		stop:  stop,
+	}, nil
+}
+
+// The stored items of the planned range in index order. Sparse items are
+// not included.
+func (pr *PlannedRange) Items() ([]IndexValue, error) {
+	r, err := pr.plan.results(pr.vect)
+	if err != nil {
+		return nil, err
+	}
+	var items []IndexValue
+	for index := pr.start; index < pr.stop && index < r.size; index++ {
+		b, ok := r.found[index]
+		if !ok {
+			continue
+		}
+		v, err := ValUnpack(b)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, IndexValue{Index: index, Value: v})
+	}
+	return items, nil
+}
+
+// Merge spans that overlap or are adjacent, returning them sorted.
+func coalesce(spans []indexRun) []indexRun {
+	if len(spans) == 0 {
+		return nil
+	}
+	sorted := append([]indexRun{}, spans...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].first < sorted[j].first })
+
+	merged := []indexRun{sorted[0]}
+	for _, s := range sorted[1:] {
+		last := &merged[len(merged)-1]
+		if s.first <= last.last+1 {
+			if s.last > last.last {
+				last.last = s.last
+			}
+			continue
+		}
+		merged = append(merged, s)
+	}
+	return merged
+}
@@ -0,0 +1,268 @@
+package vector
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"time"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+/*
+ * ConflictPolicy - what a Replicator does with a change to an item of the
+ * destination that was modified there since the Replicator last wrote it
+ * (or that it never wrote but finds stored).
+ */
+type ConflictPolicy int
+
+const (
+	SourceWins      ConflictPolicy = iota // overwrite the destination item
+	DestinationWins                       // keep it, and keep it out of replication
+	FailOnConflict                        // fail the batch with a *ReplicaConflictError
+)
+
+/*
+ * ReplicaConflictError - a change a Replicator with FailOnConflict could
+ * not apply because the destination item diverged. Matches
+ * ErrReplicaConflict with errors.Is.
+ */
+type ReplicaConflictError struct {
+	Index    int64
+	Incoming *Value // nil for a removal
+	Current  *Value // nil if the destination item is absent
+}
+
+func (e *ReplicaConflictError) Error() string {
+	return fmt.Sprintf("vector: replicated change at index %d conflicts with the destination", e.Index)
+}
+
+func (e *ReplicaConflictError) Is(target error) bool {
+	return target == ErrReplicaConflict
+}
+
+/*
+ * Replicator - tails the change feed of a vector with Options.ChangeFeed
+ * on one cluster and applies the changes to a vector on another, e.g. for
+ * disaster recovery or to migrate a vector between clusters. Every batch
+ * is applied in one destination transaction together with the position of
+ * its last record, so each change is applied exactly once and a restarted
+ * Replicator with the same name carries on where it stopped.
+ *
+ * Key layout, in the destination vector's subspace:
+ *
+ *	("replica", name, "at")         position of the last applied record
+ *	("replica", name, "crc", index) CRC-32 of the value last written to
+ *	                                index, to detect local changes
+ *
+ * A Clear of the source clears the destination whatever the policy.
+ * Writes the source makes without recording them in its feed (atomic
+ * mutations, SnapshotClone) are not replicated; seed a new destination
+ * with SnapshotClone or a backup before starting.
+ */
+type Replicator struct {
+	BatchSize    int            // records per transaction, default 500
+	PollInterval time.Duration  // wait when the feed is drained, default 1s
+	Policy       ConflictPolicy // default SourceWins
+
+	src, dest     *Vector
+	srcDB, destDB fdb.Transactor
+	name          string
+}
+
+// Create a Replicator named name applying the change feed of src, read in
+// srcDB, to dest in destDB.
+func NewReplicator(src *Vector, srcDB fdb.Transactor, dest *Vector, destDB fdb.Transactor, name string) *Replicator {
+	return &Replicator{src: src, srcDB: srcDB, dest: dest, destDB: destDB, name: name}
+}
+
+// Apply batches until ctx is done or a batch fails, polling the feed
+// whenever it is drained.
+func (r *Replicator) Run(ctx context.Context) error {
+	poll := r.PollInterval
+	if poll <= 0 {
+		poll = time.Second
+	}
+
+	for {
+		n, err := r.Step(ctx)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(poll):
+		}
+	}
+}
+
+// Apply the next batch of records after the checkpoint. Returns the
+// number of records read, 0 when the feed is drained. Returns
+// ErrNotEnabled if the source does not record a change feed.
+func (r *Replicator) Step(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	if !r.src.opts.ChangeFeed {
+		return 0, ErrNotEnabled
+	}
+	limit := r.BatchSize
+	if limit <= 0 {
+		limit = 500
+	}
+
+	after, err := r.destDB.ReadTransact(func(tr fdb.ReadTransaction) (interface{}, error) {
+		return tr.Get(r.key("at")).Get()
+	})
+	if err != nil {
+		return 0, err
+	}
+	recs, err := r.srcDB.ReadTransact(func(tr fdb.ReadTransaction) (interface{}, error) {
+		return r.src.ReadChanges(after.([]byte), limit, tr)
+	})
+	if err != nil {
+		return 0, err
+	}
+	records := recs.([]ChangeRecord)
+	if len(records) == 0 {
+		return 0, nil
+	}
+
+	_, err = r.destDB.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		at, err := tr.Get(r.key("at")).Get()
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(at, after.([]byte)) {
+			// another Replicator of the same name got there first; the
+			// batch is read again from its checkpoint next Step
+			return nil, nil
+		}
+		if err := r.apply(records, tr); err != nil {
+			return nil, err
+		}
+		tr.Set(r.key("at"), records[len(records)-1].Position())
+		return nil, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return len(records), nil
+}
+
+// Get the versionstamp of the last applied record, zero if none was.
+func (r *Replicator) Checkpoint(tr fdb.ReadTransaction) (Versionstamp, error) {
+	var vs Versionstamp
+	pos, err := tr.Get(r.key("at")).Get()
+	if err != nil {
+		return vs, err
+	}
+	copy(vs[:], pos)
+	return vs, nil
+}
+
+// Apply records to the destination in tr.
+func (r *Replicator) apply(records []ChangeRecord, tr fdb.Transaction) error {
+	dest := r.dest
+	if err := dest.mutation("Replicate", -1, tr); err != nil {
+		return err
+	}
+	for _, rec := range records {
+		if rec.Cleared {
+			if err := dest.ClearWithError(tr); err != nil {
+				return err
+			}
+			tr.ClearRange(dest.subspace.Sub("replica", r.name, "crc"))
+			continue
+		}
+
+		var incoming []byte
+		if rec.Value != nil {
+			incoming = LazyValue{raw: rec.Value.Raw}.body()
+		}
+		apply, err := r.resolve(rec, incoming, tr)
+		if err != nil {
+			return err
+		}
+		if !apply {
+			continue
+		}
+
+		if incoming == nil {
+			if err := dest.removeAt(rec.Index, tr); err != nil {
+				return err
+			}
+			tr.Clear(r.crcKey(rec.Index))
+			continue
+		}
+		key, err := dest.prepareWrite("vector.replicate", rec.Index, tr)
+		if err != nil {
+			return err
+		}
+		if err := dest.setKey(key, incoming, tr); err != nil {
+			return err
+		}
+		tr.Set(r.crcKey(rec.Index), packCRC(incoming))
+	}
+	return nil
+}
+
+// Decide by the policy whether the change of rec, incoming (nil for a
+// removal), is applied.
+func (r *Replicator) resolve(rec ChangeRecord, incoming []byte, tr fdb.Transaction) (bool, error) {
+	if r.Policy == SourceWins {
+		return true, nil
+	}
+	cur, err := tr.Get(r.dest.keyAt(rec.Index)).Get()
+	if err != nil {
+		return false, err
+	}
+	crc, err := tr.Get(r.crcKey(rec.Index)).Get()
+	if err != nil {
+		return false, err
+	}
+
+	var diverged bool
+	if cur == nil {
+		// removed here since, unless the source removes it as well
+		diverged = crc != nil && incoming != nil
+	} else {
+		body := LazyValue{raw: cur}.body()
+		diverged = !bytes.Equal(body, incoming) && !bytes.Equal(crc, packCRC(body))
+	}
+	if !diverged {
+		return true, nil
+	}
+	if r.Policy == DestinationWins {
+		return false, nil
+	}
+
+	conflict := &ReplicaConflictError{Index: rec.Index, Incoming: rec.Value}
+	if cur != nil {
+		if conflict.Current, err = ValUnpack(cur); err != nil {
+			return false, err
+		}
+	}
+	return false, conflict
+}
+
+func (r *Replicator) key(name string) fdb.Key {
+	return r.dest.subspace.Pack(tuple.Tuple{"replica", r.name, name})
+}
+
+func (r *Replicator) crcKey(index int64) fdb.Key {
+	return r.dest.subspace.Pack(tuple.Tuple{"replica", r.name, "crc", index})
+}
+
+func packCRC(b []byte) []byte {
+	var sum [4]byte
+	binary.BigEndian.PutUint32(sum[:], crc32.ChecksumIEEE(b))
+	return sum[:]
+}
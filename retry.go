@@ -0,0 +1,159 @@
+package vector
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+// FDB error code returned on commit when the transaction lost a conflict.
+const errNotCommitted = 1020
+
+/*
+ * RetryOptions - tuning for RunWithRetry.
+ *
+ * Hot vectors (many clients pushing onto the same tail) conflict a lot. The
+ * default fdb retry loop retries almost immediately, so the same clients
+ * collide again; RunWithRetry adds a jittered exponential backoff on top of
+ * the binding's own and lets the caller pick the transaction priority and
+ * timeout. The zero value retries until success with sensible defaults.
+ */
+type RetryOptions struct {
+	MaxAttempts    int           // give up after this many attempts, 0 for no limit
+	InitialBackoff time.Duration // first sleep after a retryable error, default 10ms
+	MaxBackoff     time.Duration // upper bound for the backoff, default 1s
+	Timeout        time.Duration // fdb timeout applied to each attempt, 0 for none
+	PriorityBatch  bool          // run at batch priority
+	PriorityHigh   bool          // run at system immediate priority
+
+	// Logger, if set, gets a line for every call that takes SlowThreshold
+	// or longer, with its attempts and conflicts. Op and Range, if set,
+	// name the operation fn runs and the range it scans in that line.
+	Logger        Logger
+	SlowThreshold time.Duration
+	Op            string
+	Range         *VectRange
+}
+
+/*
+ * RetryStats - what happened while RunWithRetry was trying to commit.
+ */
+type RetryStats struct {
+	Attempts  int           // number of times fn was run
+	Conflicts int           // attempts that failed with not_committed
+	Backoff   time.Duration // total time slept between attempts
+	Elapsed   time.Duration // wall time from first attempt to return
+}
+
+// Run fn in a transaction on db and commit it, retrying retryable errors
+// with exponential backoff. The returned RetryStats are valid even when an
+// error is returned.
+func RunWithRetry(db fdb.Database, opts RetryOptions, fn func(fdb.Transaction) (interface{}, error)) (interface{}, RetryStats, error) {
+	ret, stats, err := runWithRetry(db, opts, fn)
+	if opts.Logger != nil && stats.Elapsed >= opts.SlowThreshold {
+		op := opts.Op
+		if op == "" {
+			op = "transaction"
+		}
+		if opts.Range != nil {
+			op += fmt.Sprintf(" (range %d:%d:%d)", opts.Range.Start, opts.Range.Stop, opts.Range.Step)
+		}
+		opts.Logger.Printf("vector: slow %s took %s, %d attempts, %d conflicts, %s backoff, err: %v",
+			op, stats.Elapsed, stats.Attempts, stats.Conflicts, stats.Backoff, err)
+	}
+	return ret, stats, err
+}
+
+func runWithRetry(db fdb.Database, opts RetryOptions, fn func(fdb.Transaction) (interface{}, error)) (interface{}, RetryStats, error) {
+	var stats RetryStats
+	start := time.Now()
+
+	tr, err := db.CreateTransaction()
+	if err != nil {
+		return nil, stats, err
+	}
+
+	backoff := opts.InitialBackoff
+	if backoff <= 0 {
+		backoff = 10 * time.Millisecond
+	}
+	maxBackoff := opts.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = time.Second
+	}
+
+	for {
+		stats.Attempts++
+
+		// options do not survive OnError, so they are set on every attempt
+		if err = opts.apply(tr); err != nil {
+			stats.Elapsed = time.Since(start)
+			return nil, stats, err
+		}
+
+		ret, err := attempt(tr, fn)
+		if err == nil {
+			stats.Elapsed = time.Since(start)
+			return ret, stats, nil
+		}
+
+		fe, ok := err.(fdb.Error)
+		if !ok {
+			stats.Elapsed = time.Since(start)
+			return nil, stats, err
+		}
+		if fe.Code == errNotCommitted {
+			stats.Conflicts++
+		}
+		if opts.MaxAttempts > 0 && stats.Attempts >= opts.MaxAttempts {
+			stats.Elapsed = time.Since(start)
+			return nil, stats, err
+		}
+
+		// OnError returns the error again if it is not retryable
+		if err = tr.OnError(fe).Get(); err != nil {
+			stats.Elapsed = time.Since(start)
+			return nil, stats, err
+		}
+
+		sleep := backoff/2 + time.Duration(rand.Int63n(int64(backoff/2)+1))
+		time.Sleep(sleep)
+		stats.Backoff += sleep
+
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// Apply the transaction options to tr.
+func (opts RetryOptions) apply(tr fdb.Transaction) error {
+	return TxOptions{
+		Timeout:       opts.Timeout,
+		PriorityBatch: opts.PriorityBatch,
+		PriorityHigh:  opts.PriorityHigh,
+	}.apply(tr)
+}
+
+// Run fn and commit, turning fdb.Error panics (from MustGet and friends)
+// into returned errors the same way Database.Transact does.
+func attempt(tr fdb.Transaction, fn func(fdb.Transaction) (interface{}, error)) (ret interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(fdb.Error)
+			if !ok {
+				panic(r)
+			}
+			err = e
+		}
+	}()
+
+	ret, err = fn(tr)
+	if err == nil {
+		err = tr.Commit().Get()
+	}
+	return
+}
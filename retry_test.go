@@ -0,0 +1,141 @@
+package vector
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/directory"
+)
+
+func TestRunWithRetry(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	vector := Vector{subspace: subspace}
+
+	opts := RetryOptions{
+		MaxAttempts: 5,
+		Timeout:     5 * time.Second,
+	}
+
+	_, stats, e := RunWithRetry(db, opts, func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		return nil, vector.Push("a", tr)
+	})
+	if e != nil {
+		t.Error(e)
+	}
+	if stats.Attempts < 1 {
+		t.Errorf("Expected at least one attempt, got %d", stats.Attempts)
+	}
+
+	_, stats, e = RunWithRetry(db, opts, func(tr fdb.Transaction) (interface{}, error) {
+		return nil, fmt.Errorf("not retryable")
+	})
+	if e == nil {
+		t.Error("Expected error from RunWithRetry")
+	}
+	if stats.Attempts != 1 {
+		t.Errorf("Expected non-fdb error to stop after 1 attempt, got %d", stats.Attempts)
+	}
+}
+
+func TestWithTxOptions(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	vector := Vector{subspace: subspace}
+
+	tdb := WithTxOptions(db, TxOptions{Timeout: 5 * time.Second, RetryLimit: 3, PriorityBatch: true})
+	_, e := tdb.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		return nil, vector.Push("a", tr)
+	})
+	if e != nil {
+		t.Error(e)
+	}
+
+	size, e := tdb.ReadTransact(func(tr fdb.ReadTransaction) (interface{}, error) {
+		return vector.Size(tr)
+	})
+	if e != nil {
+		t.Error(e)
+	}
+	if size != int64(1) {
+		t.Errorf("Expected size 1, got %v instead", size)
+	}
+}
+
+type testLogger struct {
+	lines []string
+}
+
+func (l *testLogger) Printf(format string, args ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, args...))
+}
+
+func TestSlowOpLogging(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	logger := &testLogger{}
+	vector := NewVector(subspace, "").WithHooks(SlowOpHooks(logger, 0))
+
+	opts := RetryOptions{Logger: logger}
+	_, _, e := RunWithRetry(db, opts, func(tr fdb.Transaction) (interface{}, error) {
+		logger.lines = nil
+		vector.Clear(tr)
+		if err := vector.Push("a", tr); err != nil {
+			return nil, err
+		}
+		return vector.CountWhere(nil, VectRange{}, tr)
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(logger.lines) != 3 {
+		t.Errorf("Expected Push, CountWhere and transaction to be logged, got %q", logger.lines)
+	}
+
+	scan := VectRange{Start: 0, Stop: 10}
+	opts = RetryOptions{Logger: logger, Op: "scan", Range: &scan}
+	_, _, e = RunWithRetry(db, opts, func(tr fdb.Transaction) (interface{}, error) {
+		logger.lines = nil
+		vi, err := vector.GetRange(scan, tr)
+		if err != nil {
+			return nil, err
+		}
+		for vi.Advance() {
+			if _, err := vi.Get(); err != nil {
+				return nil, err
+			}
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(logger.lines) != 2 || !strings.HasPrefix(logger.lines[0], "vector: slow GetRange (range 0:10:0, ") ||
+		!strings.HasPrefix(logger.lines[1], "vector: slow scan (range 0:10:0) took ") ||
+		!strings.Contains(logger.lines[1], " 1 attempts,") {
+		t.Errorf("Expected the exhausted GetRange and the scan with its attempts to be logged, got %q", logger.lines)
+	}
+}
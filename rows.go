@@ -0,0 +1,113 @@
+package vector
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/directory"
+)
+
+/*
+ * RowVector - stores structs in a Table: every mapped field is a column
+ * and the fields of the struct at index i are row i. Fields are mapped by their `vector:"name"` tag,
+ * or their name when untagged; `vector:"-"` and unexported fields are
+ * skipped. Field types must be packable by ValPack and scannable by
+ * Value.Scan.
+ *
+ *	type Point struct {
+ *		X, Y  float64
+ *		Label string `vector:"label"`
+ *	}
+ *	rows, err := vector.OpenRowVector(db, directory.Root(), []string{"points"}, Point{})
+ *	err = rows.PutStruct(0, Point{1, 2, "a"}, tr)
+ */
+type RowVector struct {
+	typ    reflect.Type
+	fields []int // struct field index of each column
+	table  *Table
+}
+
+// Open the table at path for the struct type of sample.
+func OpenRowVector(t fdb.Transactor, parent directory.Directory, path []string, sample interface{}) (*RowVector, error) {
+	typ := reflect.TypeOf(sample)
+	if typ == nil || typ.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("vector.openrowvector: %T is not a struct", sample)
+	}
+
+	rv := &RowVector{typ: typ}
+	var names []string
+	for i := 0; i < typ.NumField(); i++ {
+		name, ok := columnName(typ.Field(i))
+		if !ok {
+			continue
+		}
+		rv.fields = append(rv.fields, i)
+		names = append(names, name)
+	}
+
+	table, err := OpenTable(t, parent, path, names)
+	if err != nil {
+		return nil, err
+	}
+	rv.table = table
+	return rv, nil
+}
+
+// Get the Table holding the columns.
+func (rv *RowVector) Table() *Table {
+	return rv.table
+}
+
+// Store the fields of v, a struct of the RowVector's type or a pointer to
+// one, at index.
+func (rv *RowVector) PutStruct(index int64, v interface{}, tr fdb.Transaction) error {
+	sv := reflect.Indirect(reflect.ValueOf(v))
+	if sv.Type() != rv.typ {
+		return fmt.Errorf("vector.putstruct: got %T, want %s", v, rv.typ)
+	}
+	row := make(map[string]interface{}, len(rv.fields))
+	for i, name := range rv.table.names {
+		row[name] = sv.Field(rv.fields[i]).Interface()
+	}
+	return rv.table.Set(index, row, tr)
+}
+
+// Load the struct at index into v, a pointer to the RowVector's type.
+// Fields whose column is sparse at index keep their value.
+func (rv *RowVector) GetStruct(index int64, v interface{}, tr fdb.ReadTransaction) error {
+	pv := reflect.ValueOf(v)
+	if pv.Kind() != reflect.Ptr || pv.Elem().Type() != rv.typ {
+		return fmt.Errorf("vector.getstruct: got %T, want *%s", v, rv.typ)
+	}
+	row, err := rv.table.Get(index, tr)
+	if err != nil {
+		return err
+	}
+	sv := pv.Elem()
+	for i, name := range rv.table.names {
+		val, ok := row[name]
+		if !ok {
+			continue
+		}
+		if err := val.Scan(sv.Field(rv.fields[i]).Addr().Interface()); err != nil {
+			return fmt.Errorf("vector.getstruct: field %s: %w", rv.typ.Field(rv.fields[i]).Name, err)
+		}
+	}
+	return nil
+}
+
+// Column name of f and whether it is mapped at all.
+func columnName(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	switch tag := f.Tag.Get("vector"); tag {
+	case "-":
+		return "", false
+	case "":
+		return f.Name, true
+	default:
+		return tag, true
+	}
+}
@@ -0,0 +1,108 @@
+package vector
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/directory"
+)
+
+type testRow struct {
+	X       float64
+	Count   int
+	Label   string `vector:"label"`
+	Ignored string `vector:"-"`
+}
+
+func TestRowVector(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	rows, err := OpenRowVector(db, directory.Root(), []string{"tests", "rows"}, testRow{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		for _, col := range rows.Table().columns {
+			col.Clear(tr)
+		}
+		if err := rows.PutStruct(0, testRow{X: 1.5, Count: 3, Label: "a", Ignored: "x"}, tr); err != nil {
+			return nil, err
+		}
+
+		var got testRow
+		if err := rows.GetStruct(0, &got, tr); err != nil {
+			return nil, err
+		}
+		if got != (testRow{X: 1.5, Count: 3, Label: "a"}) {
+			return nil, fmt.Errorf("Unexpected row %+v", got)
+		}
+
+		if err := rows.PutStruct(1, struct{ X int }{1}, tr); err == nil {
+			return nil, fmt.Errorf("Expected error storing a struct of another type")
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestTable(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	tbl, err := OpenTable(db, directory.Root(), []string{"tests", "table"}, []string{"name", "age"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		for _, col := range tbl.columns {
+			col.Clear(tr)
+		}
+		if _, err := tbl.Insert(map[string]interface{}{"name": "ann", "age": 31}, tr); err != nil {
+			return nil, err
+		}
+		row, err := tbl.Insert(map[string]interface{}{"name": "bob"}, tr)
+		if err != nil {
+			return nil, err
+		}
+		if row != 1 {
+			return nil, fmt.Errorf("Expected row 1, got %d instead", row)
+		}
+
+		var age int
+		if err := tbl.Scan(0, "age", &age, tr); err != nil || age != 31 {
+			return nil, fmt.Errorf("Expected age 31, got %d (%v)", age, err)
+		}
+		vals, err := tbl.Get(1, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) != 1 || vals["name"].String != "bob" {
+			return nil, fmt.Errorf("Expected only name bob, got %v instead", vals)
+		}
+
+		if err := tbl.Delete(0, tr); err != nil {
+			return nil, err
+		}
+		vals, err = tbl.Get(0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) != 0 {
+			return nil, fmt.Errorf("Expected deleted row to be empty, got %v instead", vals)
+		}
+
+		if _, err := tbl.Insert(map[string]interface{}{"email": "x"}, tr); err == nil {
+			return nil, fmt.Errorf("Expected error inserting unknown column")
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
@@ -0,0 +1,244 @@
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+/*
+ * Predicate - a test applied to each stored item of a scan.
+ */
+type Predicate func(index int64, val *Value) bool
+
+// Return the stored items in vro for which fn returns true. Items are
+// streamed from the database, so memory is bounded by the number of
+// matches rather than the size of the range. Sparse items are not
+// visited.
+func (vect *Vector) Filter(fn Predicate, vro VectRange, tr fdb.ReadTransaction) (_ []IndexValue, err error) {
+	info, err := vect.beforeScan("Filter", vro)
+	if err != nil {
+		return nil, err
+	}
+	defer vect.afterOp(info, &err)
+
+	vi, err := vect.getRange(vro, tr)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []IndexValue
+	for vi.Advance() {
+		iv, err := vi.Get()
+		if err != nil {
+			return nil, err
+		}
+		info.addBytes(len(vi.kv.Value))
+		if fn(iv.Index, iv.Value) {
+			matches = append(matches, iv)
+		}
+	}
+	return matches, nil
+}
+
+// Count the stored items in vro for which fn returns true, streaming the
+// range without collecting the items. A nil fn counts every stored item.
+func (vect *Vector) CountWhere(fn Predicate, vro VectRange, tr fdb.ReadTransaction) (_ int64, err error) {
+	info, err := vect.beforeScan("CountWhere", vro)
+	if err != nil {
+		return 0, err
+	}
+	defer vect.afterOp(info, &err)
+
+	vi, err := vect.getRange(vro, tr)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int64
+	for vi.Advance() {
+		iv, err := vi.Get()
+		if err != nil {
+			return 0, err
+		}
+		info.addBytes(len(vi.kv.Value))
+		if fn == nil || fn(iv.Index, iv.Value) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// Like Filter, but fn sees each item undecoded and only the matches are
+// decoded, so selective filters over large ranges skip most of the
+// decoding work.
+func (vect *Vector) FilterLazy(fn LazyPredicate, vro VectRange, tr fdb.ReadTransaction) (_ []IndexValue, err error) {
+	info, err := vect.beforeScan("FilterLazy", vro)
+	if err != nil {
+		return nil, err
+	}
+	defer vect.afterOp(info, &err)
+
+	vi, err := vect.getRange(vro, tr)
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []IndexValue
+	for vi.Advance() {
+		lv, err := vi.GetLazy()
+		if err != nil {
+			return nil, err
+		}
+		info.addBytes(len(vi.kv.Value))
+		if !fn(lv) {
+			continue
+		}
+		val, err := lv.Value()
+		if err != nil {
+			return nil, err
+		}
+		matches = append(matches, IndexValue{Index: lv.Index, Value: val})
+	}
+	return matches, nil
+}
+
+// Count the numeric items in vro per bucket of bounds, in one streamed
+// pass holding only the counts. bounds must be ascending; the result has
+// len(bounds)+1 counts, where count i is of the values v with
+// bounds[i-1] <= v < bounds[i], the first below bounds[0] and the last at
+// or above the last bound. Items that are not ints or floats, and sparse
+// items, are not counted.
+func (vect *Vector) Histogram(bounds []float64, vro VectRange, tr fdb.ReadTransaction) (_ []int64, err error) {
+	info, err := vect.beforeScan("Histogram", vro)
+	if err != nil {
+		return nil, err
+	}
+	defer vect.afterOp(info, &err)
+
+	if !sort.Float64sAreSorted(bounds) {
+		return nil, fmt.Errorf("vector.histogram: bounds are not ascending")
+	}
+
+	vi, err := vect.getRange(vro, tr)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make([]int64, len(bounds)+1)
+	for vi.Advance() {
+		lv, err := vi.GetLazy()
+		if err != nil {
+			return nil, err
+		}
+		info.addBytes(len(vi.kv.Value))
+
+		var x float64
+		switch {
+		case lv.IsInt():
+			n, err := lv.Int()
+			if err != nil {
+				return nil, err
+			}
+			x = float64(n)
+		case lv.IsFloat():
+			if x, err = lv.Float(); err != nil {
+				return nil, err
+			}
+		default:
+			continue
+		}
+		counts[bucketOf(bounds, x)]++
+	}
+	return counts, nil
+}
+
+// Bucket of x in a Histogram with bounds.
+func bucketOf(bounds []float64, x float64) int {
+	return sort.Search(len(bounds), func(i int) bool { return x < bounds[i] })
+}
+
+/*
+ * NumericColumn - the stored items of a range as plain slices, see
+ * ScanNumeric. Ints is set when every item is an int (including Counter
+ * and Ordered items), Floats when any item is a float, the ints among
+ * them converted.
+ */
+type NumericColumn struct {
+	Indexes []int64 // index of each element
+	Ints    []int64
+	Floats  []float64
+}
+
+// Read the stored items in vro into a NumericColumn, decoding them
+// straight from the fetched bytes without a Value per item, for analytics
+// over millions of elements. The range is fetched in large batches.
+// Sparse items are left out, Indexes tells where each element sits. A
+// non-numeric item fails the scan with a *TypeMismatchError. ChunkedVector
+// and DeltaVector return their ranges as slices already.
+func (vect *Vector) ScanNumeric(vro VectRange, tr fdb.ReadTransaction) (_ *NumericColumn, err error) {
+	info, err := vect.beforeScan("ScanNumeric", vro)
+	if err != nil {
+		return nil, err
+	}
+	defer vect.afterOp(info, &err)
+
+	kr, reverse, err := vect.keyRange(vro, tr)
+	if err != nil {
+		return nil, err
+	}
+	ri := tr.GetRange(kr, fdb.RangeOptions{Reverse: reverse, Mode: fdb.StreamingModeWantAll}).Iterator()
+
+	col := &NumericColumn{}
+	for ri.Advance() {
+		kv, err := ri.Get()
+		if err != nil {
+			return nil, err
+		}
+		index, err := vect.indexAt(kv.Key)
+		if err != nil {
+			if vect.opts.SkipForeignKeys && errors.Is(err, ErrForeignKey) {
+				continue
+			}
+			return nil, err
+		}
+		info.addBytes(len(kv.Value))
+
+		lv := LazyValue{Index: index, raw: kv.Value}
+		switch {
+		case lv.IsInt():
+			n, err := lv.Int()
+			if err != nil {
+				return nil, err
+			}
+			if col.Floats != nil {
+				col.Floats = append(col.Floats, float64(n))
+			} else {
+				col.Ints = append(col.Ints, n)
+			}
+		case lv.IsFloat():
+			f, err := lv.Float()
+			if err != nil {
+				return nil, err
+			}
+			if col.Floats == nil {
+				col.Floats = make([]float64, len(col.Ints), len(col.Ints)+1)
+				for i, n := range col.Ints {
+					col.Floats[i] = float64(n)
+				}
+				col.Ints = nil
+			}
+			col.Floats = append(col.Floats, f)
+		default:
+			got := "empty value"
+			if c := lv.Typecode(); c >= 0 {
+				got = typeName(byte(c))
+			}
+			return nil, &TypeMismatchError{Index: index, Want: "numeric", Got: got}
+		}
+		col.Indexes = append(col.Indexes, index)
+	}
+	return col, nil
+}
@@ -0,0 +1,201 @@
+package vector
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/directory"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+/*
+ * Schemas are stored in a registry subspace, one key per name:
+ *
+ *	(name) -> (type, keys, default, max len, bounded, min, max)
+ *
+ * bounded is 0 or 1 and min and max are float64 bits, as the tuple layer
+ * of the bindings in use has no bool or float types.
+ * A vector opened with a schema records its name under ("meta", "schema")
+ * so it cannot later be opened with another one.
+ */
+
+/*
+ * Schema - a named contract for the vectors opened with it. Type is an
+ * element type name as recorded by Options.Homogeneous ("int", "float",
+ * "string", "counter", "ordered", "binary"), empty for any. Keys is the
+ * key encoding, "tuple" (or empty) or "fixed64". MaxLen limits the length
+ * of string and binary items; Min and Max bound numeric items when
+ * Bounded is set.
+ */
+type Schema struct {
+	Name    string
+	Type    string
+	Keys    string
+	Default string
+	MaxLen  int
+	Bounded bool
+	Min     float64
+	Max     float64
+}
+
+/*
+ * SchemaRegistry - the schemas shared by the vectors of an application,
+ * stored in a subspace of their own.
+ */
+type SchemaRegistry struct {
+	space subspace.Subspace
+}
+
+// Get the registry stored in space, usually a directory subspace.
+func NewSchemaRegistry(space subspace.Subspace) *SchemaRegistry {
+	return &SchemaRegistry{space: space}
+}
+
+// Store s under its name. Registering a name again with a different
+// schema fails with ErrMetadataMismatch: vectors already rely on it.
+func (reg *SchemaRegistry) Register(s Schema, tr fdb.Transaction) error {
+	if s.Keys == "" {
+		s.Keys = keysTuple
+	}
+	if s.Keys != keysTuple && s.Keys != keysFixed64 {
+		return fmt.Errorf("vector: schema %q has unknown key encoding %q", s.Name, s.Keys)
+	}
+	old, err := reg.Lookup(s.Name, tr)
+	switch {
+	case err == nil && old != s:
+		return fmt.Errorf("vector: schema %q is already registered differently: %w", s.Name, ErrMetadataMismatch)
+	case err == nil:
+		return nil
+	case err != ErrUnknownSchema:
+		return err
+	}
+	var bounded int64
+	if s.Bounded {
+		bounded = 1
+	}
+	tr.Set(reg.space.Pack(tuple.Tuple{s.Name}), tuple.Tuple{
+		s.Type, s.Keys, s.Default, int64(s.MaxLen), bounded,
+		int64(math.Float64bits(s.Min)), int64(math.Float64bits(s.Max)),
+	}.Pack())
+	return nil
+}
+
+// Get the schema called name, or ErrUnknownSchema.
+func (reg *SchemaRegistry) Lookup(name string, tr fdb.ReadTransaction) (Schema, error) {
+	v, err := tr.Get(reg.space.Pack(tuple.Tuple{name})).Get()
+	if err != nil {
+		return Schema{}, err
+	}
+	if v == nil {
+		return Schema{}, ErrUnknownSchema
+	}
+	t, err := tuple.Unpack(v)
+	if err != nil || len(t) != 7 {
+		return Schema{}, fmt.Errorf("vector: malformed schema %q", name)
+	}
+	s := Schema{Name: name}
+	var ok [7]bool
+	var maxLen, bounded, min, max int64
+	s.Type, ok[0] = t[0].(string)
+	s.Keys, ok[1] = t[1].(string)
+	s.Default, ok[2] = t[2].(string)
+	maxLen, ok[3] = t[3].(int64)
+	bounded, ok[4] = t[4].(int64)
+	min, ok[5] = t[5].(int64)
+	max, ok[6] = t[6].(int64)
+	for _, o := range ok {
+		if !o {
+			return Schema{}, fmt.Errorf("vector: malformed schema %q", name)
+		}
+	}
+	s.MaxLen = int(maxLen)
+	s.Bounded = bounded != 0
+	s.Min = math.Float64frombits(uint64(min))
+	s.Max = math.Float64frombits(uint64(max))
+	return s, nil
+}
+
+// Create or open the Vector at path relative to parent with the schema
+// called name: the schema's default, key encoding and element type are
+// used and checked against the vector's metadata, and every write is
+// validated against its constraints, failing with ErrSchemaViolation.
+// WithOptions on the result must keep the FixedKeys and Homogeneous
+// settings the schema implies.
+func OpenWithSchema(t fdb.Transactor, parent directory.Directory, path []string, reg *SchemaRegistry, name string) (*Vector, error) {
+	s, err := t.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		return reg.Lookup(name, rtr)
+	})
+	if err != nil {
+		return nil, err
+	}
+	schema := s.(Schema)
+
+	vect, err := OpenWith(t, parent, path, schema.Default)
+	if err != nil {
+		return nil, err
+	}
+	vect.opts.FixedKeys = schema.Keys == keysFixed64
+	vect.opts.Homogeneous = schema.Type != ""
+	vect.schema = &schema
+
+	_, err = t.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		if err := vect.Init(tr); err != nil {
+			return nil, err
+		}
+		if err := vect.checkMeta("schema", schema.Name, tr); err != nil {
+			return nil, err
+		}
+		if schema.Type != "" {
+			return nil, vect.checkMeta("type", schema.Type, tr)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return vect, nil
+}
+
+// The schema the vector was opened with, nil if none.
+func (vect *Vector) Schema() *Schema {
+	return vect.schema
+}
+
+// Record want under ("meta", name) unless something is recorded already,
+// which must then be want.
+func (vect *Vector) checkMeta(name, want string, tr fdb.Transaction) error {
+	got, err := tr.Get(vect.metaKey(name)).Get()
+	if err != nil {
+		return err
+	}
+	if got == nil {
+		tr.Set(vect.metaKey(name), []byte(want))
+		return nil
+	}
+	if string(got) != want {
+		return fmt.Errorf("vector: %s is %q, handle uses %q: %w", name, got, want, ErrMetadataMismatch)
+	}
+	return nil
+}
+
+// Check a packed value against the constraints of the schema.
+func (s *Schema) validate(index int64, packed []byte) error {
+	if s.MaxLen == 0 && !s.Bounded {
+		return nil
+	}
+	v, err := ValUnpack(packed)
+	if err != nil {
+		return err
+	}
+	switch {
+	case s.MaxLen > 0 && v.IsString && len(v.String) > s.MaxLen,
+		s.MaxLen > 0 && v.IsBinary && len(v.Bytes) > s.MaxLen:
+		return fmt.Errorf("vector: index %d longer than %d in schema %q: %w", index, s.MaxLen, s.Name, ErrSchemaViolation)
+	case s.Bounded && v.IsInt && (float64(v.Int) < s.Min || float64(v.Int) > s.Max),
+		s.Bounded && v.IsFloat && (v.Float < s.Min || v.Float > s.Max):
+		return fmt.Errorf("vector: index %d outside [%g, %g] in schema %q: %w", index, s.Min, s.Max, s.Name, ErrSchemaViolation)
+	}
+	return nil
+}
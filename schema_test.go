@@ -0,0 +1,66 @@
+package vector
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/directory"
+)
+
+func TestOpenWithSchema(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	for _, path := range [][]string{{"tests", "schemas"}, {"tests", "schema", "scores"}} {
+		if _, err := directory.Root().Remove(db, path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	space, err := directory.CreateOrOpen(db, []string{"tests", "schemas"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := NewSchemaRegistry(space)
+
+	scores := Schema{Name: "scores", Type: "int", Bounded: true, Min: 0, Max: 100}
+	_, err = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		if err := reg.Register(scores, tr); err != nil {
+			return nil, err
+		}
+		changed := scores
+		changed.Max = 10
+		if err := reg.Register(changed, tr); !errors.Is(err, ErrMetadataMismatch) {
+			return nil, fmt.Errorf("Expected ErrMetadataMismatch re-registering, got %v", err)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := OpenWithSchema(db, directory.Root(), []string{"tests", "schema", "scores"}, reg, "missing"); err != ErrUnknownSchema {
+		t.Errorf("Expected ErrUnknownSchema, got %v", err)
+	}
+
+	vect, err := OpenWithSchema(db, directory.Root(), []string{"tests", "schema", "scores"}, reg, "scores")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		if err := vect.Push(42, tr); err != nil {
+			return nil, err
+		}
+		if err := vect.Push(101, tr); !errors.Is(err, ErrSchemaViolation) {
+			return nil, fmt.Errorf("Expected ErrSchemaViolation, got %v", err)
+		}
+		if err := vect.Push("42", tr); !errors.Is(err, ErrTypeMismatch) {
+			return nil, fmt.Errorf("Expected ErrTypeMismatch, got %v", err)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,164 @@
+package vector
+
+import (
+	"strings"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+// Find where val belongs in the vector, which must be sorted in ascending
+// order: the first index whose item is not less than val, or Size when
+// every item is. The search reads the size and then O(log n) single items
+// with point reads, instead of scanning. Ints and floats compare by value,
+// strings bytewise; comparing other kinds, or a number with a string,
+// fails with a *TypeMismatchError. Sparse items compare as their default,
+// so they are only usable with a typed one, see WithDefaultFunc.
+func (vect *Vector) SearchSorted(val interface{}, tr fdb.ReadTransaction) (_ int64, err error) {
+	info, err := vect.beforeOp("SearchSorted", -1)
+	if err != nil {
+		return 0, err
+	}
+	defer vect.afterOp(info, &err)
+
+	packed, err := ValPack(val)
+	if err != nil {
+		return 0, err
+	}
+	target, err := ValUnpack(packed)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := vect.Size(tr)
+	if err != nil {
+		return 0, err
+	}
+	lo, hi := int64(0), size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		item, err := vect.itemAt(mid, tr)
+		if err != nil {
+			return 0, err
+		}
+		c, err := compareValues(mid, item, target)
+		if err != nil {
+			return 0, err
+		}
+		if c < 0 {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo, nil
+}
+
+// Insert val into the vector, which must be sorted in ascending order, at
+// the index SearchSorted finds for it, shifting the items from there on
+// up by one. Returns the index val was stored at. Sparse gaps shift with
+// the items; tags, locks and claim counts belong to indexes and do not
+// move.
+// Shifting rewrites every item after the insertion point, so this suits
+// short or append-mostly lists; all of it conflicts with any concurrent
+// write at or past that point.
+func (vect *Vector) InsertSorted(val interface{}, tr fdb.Transaction) (_ int64, err error) {
+	info, err := vect.beforeWrite("InsertSorted", -1, tr)
+	if err != nil {
+		return 0, err
+	}
+	defer vect.afterOp(info, &err)
+
+	packed, err := ValPack(val)
+	if err != nil {
+		return 0, err
+	}
+	pos, err := vect.SearchSorted(val, tr)
+	if err != nil {
+		return 0, err
+	}
+
+	_, end := vect.indexRange().FDBRangeKeys()
+	kvs, err := tr.GetRange(fdb.KeyRange{Begin: vect.keyAt(pos), End: end}, fdb.RangeOptions{Mode: fdb.StreamingModeWantAll}).GetSliceWithError()
+	if err != nil {
+		return 0, err
+	}
+
+	indexes := make([]int64, len(kvs))
+	for i, kv := range kvs {
+		if indexes[i], err = vect.indexAt(kv.Key); err != nil {
+			return 0, err
+		}
+	}
+
+	// from the top down, so every item is moved before it is overwritten
+	for i := len(kvs) - 1; i >= 0; i-- {
+		index := indexes[i]
+		key := vect.keyAt(index + 1)
+		if i == len(kvs)-1 {
+			// the new last item
+			if key, err = vect.prepareWrite("vector.insertsorted", index+1, tr); err != nil {
+				return 0, err
+			}
+		}
+		moved := LazyValue{raw: kvs[i].Value}.body() // restamped by setKey
+		if err := vect.setKey(key, moved, tr); err != nil {
+			return 0, err
+		}
+		// the index the item left becomes a gap, unless the item below
+		// moves up into it or val goes there
+		if index > pos && (i == 0 || indexes[i-1] != index-1) {
+			if err := vect.removeAt(index, tr); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	key, err := vect.prepareWrite("vector.insertsorted", pos, tr)
+	if err != nil {
+		return 0, err
+	}
+	info.addBytes(len(packed))
+	return pos, vect.setKey(key, packed, tr)
+}
+
+// Read the item at index, inside the vector, with a point read.
+func (vect *Vector) itemAt(index int64, tr fdb.ReadTransaction) (*Value, error) {
+	b, err := tr.Get(vect.keyAt(index)).Get()
+	if err != nil {
+		return nil, err
+	}
+	if b == nil {
+		return vect.sparseValue(index)
+	}
+	return ValUnpack(b)
+}
+
+// Order the item at index against target: negative when it sorts before.
+func compareValues(index int64, item, target *Value) (int, error) {
+	switch {
+	case item.IsInt && target.IsInt:
+		return compareInts(item.Int, target.Int), nil
+	case (item.IsInt || item.IsFloat) && (target.IsInt || target.IsFloat):
+		return compareFloats(numericFloat(item), numericFloat(target)), nil
+	case item.IsString && target.IsString:
+		return strings.Compare(item.String, target.String), nil
+	}
+	return 0, &TypeMismatchError{Index: index, Want: valueKind(target), Got: valueKind(item)}
+}
+
+// Name of the kind of v, for errors.
+func valueKind(v *Value) string {
+	switch {
+	case v.IsInt:
+		return "int"
+	case v.IsFloat:
+		return "float"
+	case v.IsString:
+		return "string"
+	case v.IsBinary:
+		return "binary"
+	case v.IsRaw:
+		return "raw"
+	}
+	return "untyped " + v.Origin.String()
+}
@@ -0,0 +1,109 @@
+package vector
+
+import (
+	"context"
+	"errors"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+/*
+ * SparseIterator - walks only the items that are physically stored, handing
+ * out the key, index and packed bytes without decoding them. Copy and
+ * replication tools can move Raw() verbatim; Value() decodes on demand.
+ *
+ * Unlike Vectorator it never synthesizes anything for sparse gaps.
+ */
+type SparseIterator struct {
+	ri   *fdb.RangeIterator
+	vect *Vector
+
+	kv    fdb.KeyValue
+	index int64
+	err   error
+
+	closed bool
+}
+
+// Iterate over the stored items in vro, using the same range rules as
+// GetRange.
+func (vect *Vector) GetStoredRange(vro VectRange, tr fdb.ReadTransaction) (_ *SparseIterator, err error) {
+	defer func() { err = vect.opError("GetStoredRange", -1, err) }()
+
+	kr, reverse, err := vect.keyRange(vro, tr)
+	if err != nil {
+		return nil, err
+	}
+
+	rr := tr.GetRange(kr, fdb.RangeOptions{Reverse: reverse})
+
+	return &SparseIterator{ri: rr.Iterator(), vect: vect}, nil
+}
+
+// Move to the next stored item. Returns false at the end of the range.
+func (si *SparseIterator) Advance() bool {
+	if si.closed {
+		return false
+	}
+	for si.ri.Advance() {
+		si.kv, si.err = si.ri.Get()
+		if si.err != nil {
+			return true
+		}
+		si.index, si.err = si.vect.indexAt(si.kv.Key)
+		if si.err != nil && si.vect.opts.SkipForeignKeys && errors.Is(si.err, ErrForeignKey) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Like Advance, but stops and closes the iterator once ctx is done.
+func (si *SparseIterator) AdvanceContext(ctx context.Context) bool {
+	if si.closed {
+		return false
+	}
+	if err := ctx.Err(); err != nil {
+		si.Close()
+		si.err = err
+		return false
+	}
+	return si.Advance()
+}
+
+// Stop iterating and release the underlying range read.
+func (si *SparseIterator) Close() {
+	si.closed = true
+	si.ri = nil
+	si.kv = fdb.KeyValue{}
+}
+
+// Error reading or decoding the key of the current item, if any.
+func (si *SparseIterator) Err() error {
+	return si.err
+}
+
+// Index of the current item.
+func (si *SparseIterator) Index() int64 {
+	return si.index
+}
+
+// Database key of the current item.
+func (si *SparseIterator) Key() fdb.Key {
+	return si.kv.Key
+}
+
+// Packed bytes of the current item, as written by ValPack. The slice must
+// not be modified.
+func (si *SparseIterator) Raw() []byte {
+	return si.kv.Value
+}
+
+// Decode the current item.
+func (si *SparseIterator) Value() (*Value, error) {
+	if si.err != nil {
+		return nil, si.err
+	}
+	return ValUnpack(si.kv.Value)
+}
@@ -0,0 +1,260 @@
+package vector
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math/rand"
+	"reflect"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+/*
+ * Stats - physical layout of a vector's items, see Vector.Stats.
+ */
+type Stats struct {
+	Keys       int64   // stored items
+	KeyBytes   int64   // total size of their keys
+	ValueBytes int64   // total size of their values
+	MinIndex   int64   // smallest stored index, -1 if empty
+	MaxIndex   int64   // largest stored index, -1 if empty
+	Size       int64   // logical size, MaxIndex + 1
+	Sparsity   float64 // fraction of Size not physically stored
+}
+
+// Compute Stats by streaming over every stored item. Bookkeeping keys
+// (metadata, history, tags) are not included. Large vectors may need more
+// than the 5 second transaction limit; use a snapshot read on a dedicated
+// transaction.
+func (vect *Vector) Stats(tr fdb.ReadTransaction) (_ Stats, err error) {
+	defer func() { err = vect.opError("Stats", -1, err) }()
+
+	st := Stats{MinIndex: -1, MaxIndex: -1}
+
+	ri := tr.GetRange(vect.indexRange(), fdb.RangeOptions{Mode: fdb.StreamingModeWantAll}).Iterator()
+	for ri.Advance() {
+		kv, err := ri.Get()
+		if err != nil {
+			return st, err
+		}
+		index, err := vect.indexAt(kv.Key)
+		if err != nil {
+			if vect.opts.SkipForeignKeys && errors.Is(err, ErrForeignKey) {
+				continue
+			}
+			return st, err
+		}
+
+		if st.MinIndex < 0 {
+			st.MinIndex = index
+		}
+		st.MaxIndex = index
+		st.Keys++
+		st.KeyBytes += int64(len(kv.Key))
+		st.ValueBytes += int64(len(kv.Value))
+	}
+
+	st.Size = st.MaxIndex + 1
+	if st.Size > 0 {
+		st.Sparsity = float64(st.Size-st.Keys) / float64(st.Size)
+	}
+	return st, nil
+}
+
+// Transactions of bindings that can estimate the size of a range.
+type rangeSizeEstimator interface {
+	GetEstimatedRangeSizeBytes(r fdb.ExactRange) fdb.FutureInt64
+}
+
+// Estimate the bytes used by the vector's items from the storage servers'
+// sampled statistics, without scanning it. The estimate is rough for small
+// vectors (below a few MB). Returns ErrUnsupported when the fdb bindings in
+// use do not expose range size estimates (API version 630).
+func (vect *Vector) EstimatedSizeBytes(tr fdb.ReadTransaction) (_ int64, err error) {
+	defer func() { err = vect.opError("EstimatedSizeBytes", -1, err) }()
+
+	est, ok := tr.(rangeSizeEstimator)
+	if !ok {
+		return 0, ErrUnsupported
+	}
+	return est.GetEstimatedRangeSizeBytes(vect.indexRange()).Get()
+}
+
+// Return index boundaries b[0] = 0 < b[1] < ... < b[n] = Size splitting the
+// vector into ranges [b[i], b[i+1]) of roughly chunkBytes each, so n
+// workers can each scan one range.
+//
+// With bindings exposing GetRangeSplitPoints (API version 700) the split
+// keys come from the storage servers, and each is moved to the first
+// stored item at or after it. Otherwise the index space is split evenly
+// based on EstimatedSizeBytes, which is accurate for vectors whose items
+// are spread evenly over the indexes. Returns ErrUnsupported when neither
+// is available.
+func (vect *Vector) SplitPoints(tr fdb.ReadTransaction, chunkBytes int64) (_ []int64, err error) {
+	defer func() { err = vect.opError("SplitPoints", -1, err) }()
+
+	if chunkBytes <= 0 {
+		return nil, fmt.Errorf("vector.splitpoints: chunkBytes must be positive, got %d", chunkBytes)
+	}
+
+	size, err := vect.Size(tr)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return []int64{0}, nil
+	}
+
+	keys, ok, err := rangeSplitPoints(tr, vect.indexRange(), chunkBytes)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return vect.splitIndexes(keys, size, tr)
+	}
+
+	total, err := vect.EstimatedSizeBytes(tr)
+	if err != nil {
+		return nil, err
+	}
+
+	n := (total + chunkBytes - 1) / chunkBytes
+	if n < 1 {
+		n = 1
+	}
+	if n > size {
+		n = size
+	}
+
+	points := make([]int64, 0, n+1)
+	for i := int64(0); i < n; i++ {
+		// size*i/n without overflowing for huge sizes
+		points = append(points, size/n*i+size%n*i/n)
+	}
+	return append(points, size), nil
+}
+
+// Futures of the split keys GetRangeSplitPoints returns.
+type keyArrayFuture interface {
+	Get() ([]fdb.Key, error)
+}
+
+// Get the split keys of r from the transaction's GetRangeSplitPoints. Its
+// FutureKeyArray result type does not exist in older bindings, so the
+// method cannot be named by an interface here and is looked up by
+// reflection instead; ok is false when the bindings do not have it.
+func rangeSplitPoints(tr fdb.ReadTransaction, r fdb.ExactRange, chunkBytes int64) (_ []fdb.Key, ok bool, err error) {
+	m := reflect.ValueOf(tr).MethodByName("GetRangeSplitPoints")
+	if !m.IsValid() {
+		return nil, false, nil
+	}
+	mt := m.Type()
+	if mt.NumIn() != 2 || mt.NumOut() != 1 ||
+		!reflect.TypeOf(r).AssignableTo(mt.In(0)) || mt.In(1).Kind() != reflect.Int64 {
+		return nil, false, nil
+	}
+	out := m.Call([]reflect.Value{reflect.ValueOf(r), reflect.ValueOf(chunkBytes).Convert(mt.In(1))})
+	future, ok := out[0].Interface().(keyArrayFuture)
+	if !ok {
+		return nil, false, nil
+	}
+	keys, err := future.Get()
+	return keys, true, err
+}
+
+// Turn split keys into index boundaries from 0 to size, moving each key to
+// the first stored item at or after it. The reads are issued at once.
+func (vect *Vector) splitIndexes(keys []fdb.Key, size int64, tr fdb.ReadTransaction) ([]int64, error) {
+	firsts := make([]fdb.FutureKey, len(keys))
+	for i, k := range keys {
+		firsts[i] = tr.GetKey(fdb.FirstGreaterOrEqual(k))
+	}
+	_, end := vect.indexRange().FDBRangeKeys()
+
+	points := []int64{0}
+	for _, f := range firsts {
+		key, err := f.Get()
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Compare(key, end.FDBKey()) >= 0 {
+			continue
+		}
+		index, err := vect.indexAt(key)
+		if err != nil {
+			if vect.opts.SkipForeignKeys && errors.Is(err, ErrForeignKey) {
+				continue
+			}
+			return nil, err
+		}
+		// the range's own begin and end keys come back as split keys too
+		if index > points[len(points)-1] && index < size {
+			points = append(points, index)
+		}
+	}
+	return append(points, size), nil
+}
+
+// Get about n stored items spread over the whole vector, in index order,
+// without scanning it: the index space is cut into n equal strata, a
+// random index is picked in each and the first stored item at or after it
+// is read with a one-item range read, all issued at once. Over a sparse
+// vector, items following long gaps are more likely to be picked, and
+// strata landing on the same item yield it once, so fewer than n items
+// may be returned.
+func (vect *Vector) Sample(n int, tr fdb.ReadTransaction) (_ []IndexValue, err error) {
+	defer func() { err = vect.opError("Sample", -1, err) }()
+
+	if n <= 0 {
+		return []IndexValue{}, nil
+	}
+	size, err := vect.Size(tr)
+	if err != nil {
+		return nil, err
+	}
+	if size == 0 {
+		return []IndexValue{}, nil
+	}
+	if int64(n) > size {
+		n = int(size)
+	}
+
+	_, end := vect.indexRange().FDBRangeKeys()
+	reads := make([]fdb.RangeResult, n)
+	for i := range reads {
+		// stratum [lo, hi) of size*i/n to size*(i+1)/n, without overflowing
+		lo := size/int64(n)*int64(i) + size%int64(n)*int64(i)/int64(n)
+		hi := size/int64(n)*int64(i+1) + size%int64(n)*int64(i+1)/int64(n)
+		target := lo + rand.Int63n(hi-lo)
+		kr := fdb.KeyRange{Begin: vect.keyAt(target), End: end}
+		reads[i] = tr.GetRange(kr, fdb.RangeOptions{Limit: 1})
+	}
+
+	items := make([]IndexValue, 0, n)
+	for _, rr := range reads {
+		kvs, err := rr.GetSliceWithError()
+		if err != nil {
+			return nil, err
+		}
+		if len(kvs) == 0 {
+			continue
+		}
+		index, err := vect.indexAt(kvs[0].Key)
+		if err != nil {
+			if vect.opts.SkipForeignKeys && errors.Is(err, ErrForeignKey) {
+				continue
+			}
+			return nil, err
+		}
+		if len(items) > 0 && items[len(items)-1].Index >= index {
+			continue
+		}
+		val, err := ValUnpack(kvs[0].Value)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, IndexValue{Index: index, Value: val})
+	}
+	return items, nil
+}
@@ -0,0 +1,156 @@
+package vector
+
+import (
+	"fmt"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/directory"
+)
+
+/*
+ * Table - named column Vectors below one directory path whose indexes are
+ * aligned: row i is item i of every column. Rows are written and read as
+ * maps from column name to value; a column missing from a written row is
+ * left sparse.
+ *
+ *	tbl, err := vector.OpenTable(db, directory.Root(), []string{"users"}, []string{"name", "age"})
+ *	row, err := tbl.Insert(map[string]interface{}{"name": "ann", "age": 31}, tr)
+ *	var age int
+ *	err = tbl.Scan(row, "age", &age, tr)
+ */
+type Table struct {
+	names   []string
+	columns map[string]*Vector
+}
+
+// Open the table at path with the given columns, creating the column
+// vectors that do not exist yet.
+func OpenTable(t fdb.Transactor, parent directory.Directory, path []string, columns []string) (*Table, error) {
+	tbl := &Table{columns: make(map[string]*Vector, len(columns))}
+	for _, name := range columns {
+		if _, ok := tbl.columns[name]; ok {
+			return nil, fmt.Errorf("vector.opentable: duplicate column %q", name)
+		}
+		col, err := OpenWith(t, parent, append(append([]string{}, path...), name), "")
+		if err != nil {
+			return nil, err
+		}
+		tbl.names = append(tbl.names, name)
+		tbl.columns[name] = col
+	}
+	return tbl, nil
+}
+
+// The column names, in the order given to OpenTable.
+func (tbl *Table) Columns() []string {
+	return append([]string{}, tbl.names...)
+}
+
+// Get the Vector of column name.
+func (tbl *Table) Column(name string) (*Vector, error) {
+	col, ok := tbl.columns[name]
+	if !ok {
+		return nil, fmt.Errorf("vector.table: unknown column %q", name)
+	}
+	return col, nil
+}
+
+// Get the number of rows, the size of the longest column.
+func (tbl *Table) Size(tr fdb.ReadTransaction) (int64, error) {
+	var size int64
+	for _, name := range tbl.names {
+		n, err := tbl.columns[name].Size(tr)
+		if err != nil {
+			return 0, err
+		}
+		if n > size {
+			size = n
+		}
+	}
+	return size, nil
+}
+
+// Append row and return its index.
+func (tbl *Table) Insert(row map[string]interface{}, tr fdb.Transaction) (int64, error) {
+	index, err := tbl.Size(tr)
+	if err != nil {
+		return 0, err
+	}
+	return index, tbl.Set(index, row, tr)
+}
+
+// Store the values of row at index. Columns missing from row are left as
+// they are.
+func (tbl *Table) Set(index int64, row map[string]interface{}, tr fdb.Transaction) error {
+	for name, val := range row {
+		col, err := tbl.Column(name)
+		if err != nil {
+			return err
+		}
+		if err := col.Set(index, val, tr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read the row at index. Columns sparse at index are omitted.
+func (tbl *Table) Get(index int64, tr fdb.ReadTransaction) (map[string]*Value, error) {
+	row := make(map[string]*Value, len(tbl.names))
+	for _, name := range tbl.names {
+		val, err := tbl.cell(index, name, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.Origin == OriginStored {
+			row[name] = val
+		}
+	}
+	return row, nil
+}
+
+// Scan the value of column name at index into dest, see Value.Scan.
+func (tbl *Table) Scan(index int64, name string, dest interface{}, tr fdb.ReadTransaction) error {
+	val, err := tbl.cell(index, name, tr)
+	if err != nil {
+		return err
+	}
+	return val.Scan(dest)
+}
+
+// Remove the row at index from every column, leaving a sparse gap.
+func (tbl *Table) Delete(index int64, tr fdb.Transaction) error {
+	if index < 0 {
+		return outOfRange("vector.table.delete", index)
+	}
+	for _, name := range tbl.names {
+		col := tbl.columns[name]
+		if err := col.mutation("Delete", index, tr); err != nil {
+			return err
+		}
+		if err := col.removeAt(index, tr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Read the value of column name at index; indexes past the end of a
+// shorter column read as sparse.
+func (tbl *Table) cell(index int64, name string, tr fdb.ReadTransaction) (*Value, error) {
+	col, err := tbl.Column(name)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 {
+		return nil, outOfRange("vector.table.get", index)
+	}
+	v, err := tr.Get(col.keyAt(index)).Get()
+	if err != nil {
+		return nil, err
+	}
+	if v == nil {
+		return col.sparseValue(index)
+	}
+	return ValUnpack(v)
+}
@@ -0,0 +1,194 @@
+package vector
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
+)
+
+/*
+ * Tags are kept in two sibling subspaces of the vector:
+ *
+ *	("tag", tag, index)  -> ""   lookup by tag
+ *	("tags", index, tag) -> ""   tags of an item, to clean up on Pop
+ *
+ * Both need Options.Tags.
+ */
+
+// Set the value at index and replace its tags with tags.
+func (vect *Vector) SetWithTags(index int64, val interface{}, tags []string, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("SetWithTags", index, err) }()
+
+	if !vect.opts.Tags {
+		return ErrNotEnabled
+	}
+	if err := vect.Set(index, val, tr); err != nil {
+		return err
+	}
+	index, err = vect.resolveIndex(index, tr)
+	if err != nil {
+		return err
+	}
+	if err := vect.clearTags(index, tr); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		vect.tag(index, tag, tr)
+	}
+	return nil
+}
+
+// Add tag to the item at index.
+func (vect *Vector) Tag(index int64, tag string, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("Tag", index, err) }()
+
+	if err := vect.mutation("Tag", index, tr); err != nil {
+		return err
+	}
+	if !vect.opts.Tags {
+		return ErrNotEnabled
+	}
+	if index < 0 {
+		return outOfRange("vector.tag", index)
+	}
+	vect.tag(index, tag, tr)
+	return nil
+}
+
+// Remove tag from the item at index.
+func (vect *Vector) Untag(index int64, tag string, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("Untag", index, err) }()
+
+	if err := vect.mutation("Untag", index, tr); err != nil {
+		return err
+	}
+	if !vect.opts.Tags {
+		return ErrNotEnabled
+	}
+	tr.Clear(vect.subspace.Pack(tuple.Tuple{"tag", tag, index}))
+	tr.Clear(vect.subspace.Pack(tuple.Tuple{"tags", index, tag}))
+	return nil
+}
+
+// Get the tags of the item at index, sorted.
+func (vect *Vector) Tags(index int64, tr fdb.ReadTransaction) (_ []string, err error) {
+	defer func() { err = vect.opError("Tags", index, err) }()
+
+	if !vect.opts.Tags {
+		return nil, ErrNotEnabled
+	}
+	kvs, err := tr.GetRange(vect.subspace.Sub("tags", index), fdb.RangeOptions{}).GetSliceWithError()
+	if err != nil {
+		return nil, err
+	}
+	tags := make([]string, 0, len(kvs))
+	for _, kv := range kvs {
+		tag, err := vect.tagElem(kv.Key)
+		if err == nil {
+			if s, ok := tag.(string); ok {
+				tags = append(tags, s)
+				continue
+			}
+			err = &ForeignKeyError{Key: kv.Key, Reason: "malformed tag key"}
+		}
+		if vect.opts.SkipForeignKeys {
+			continue
+		}
+		return nil, err
+	}
+	return tags, nil
+}
+
+// Get the items tagged with tag, in index order. The items are read
+// concurrently; tags left on indexes at or past the end are skipped.
+func (vect *Vector) GetByTag(tag string, tr fdb.ReadTransaction) (_ []IndexValue, err error) {
+	defer func() { err = vect.opError("GetByTag", -1, err) }()
+
+	if !vect.opts.Tags {
+		return nil, ErrNotEnabled
+	}
+	size := vect.SizeAsync(tr)
+	kvs, err := tr.GetRange(vect.subspace.Sub("tag", tag), fdb.RangeOptions{}).GetSliceWithError()
+	if err != nil {
+		return nil, err
+	}
+
+	indexes := make([]int64, 0, len(kvs))
+	reads := make([]fdb.FutureByteSlice, 0, len(kvs))
+	for _, kv := range kvs {
+		elem, err := vect.tagElem(kv.Key)
+		if err == nil {
+			if index, ok := elem.(int64); ok {
+				indexes = append(indexes, index)
+				reads = append(reads, tr.Get(vect.keyAt(index)))
+				continue
+			}
+			err = &ForeignKeyError{Key: kv.Key, Reason: "malformed tag key"}
+		}
+		if vect.opts.SkipForeignKeys {
+			continue
+		}
+		return nil, err
+	}
+	n, err := size.Get()
+	if err != nil {
+		return nil, err
+	}
+
+	ivs := make([]IndexValue, 0, len(kvs))
+	for i, index := range indexes {
+		b, err := reads[i].Get()
+		if err != nil {
+			return nil, err
+		}
+		if index >= n {
+			continue
+		}
+		var val *Value
+		if b == nil {
+			val, err = vect.sparseValue(index)
+		} else {
+			val, err = ValUnpack(b)
+		}
+		if err != nil {
+			return nil, err
+		}
+		ivs = append(ivs, IndexValue{Index: index, Value: val})
+	}
+	return ivs, nil
+}
+
+func (vect *Vector) tag(index int64, tag string, tr fdb.Transaction) {
+	tr.Set(vect.subspace.Pack(tuple.Tuple{"tag", tag, index}), []byte{})
+	tr.Set(vect.subspace.Pack(tuple.Tuple{"tags", index, tag}), []byte{})
+}
+
+// Remove all tags of the item at index.
+func (vect *Vector) clearTags(index int64, tr fdb.Transaction) error {
+	tags := vect.subspace.Sub("tags", index)
+	kvs, err := tr.GetRange(tags, fdb.RangeOptions{}).GetSliceWithError()
+	if err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		tag, err := vect.tagElem(kv.Key)
+		if err != nil {
+			if vect.opts.SkipForeignKeys {
+				continue
+			}
+			return err
+		}
+		tr.Clear(vect.subspace.Pack(tuple.Tuple{"tag", tag, index}))
+	}
+	tr.ClearRange(tags)
+	return nil
+}
+
+// Decode the last element of a tag key, ("tag", tag, index) or
+// ("tags", index, tag).
+func (vect *Vector) tagElem(key fdb.Key) (tuple.TupleElement, error) {
+	t, err := vect.subspace.Unpack(key)
+	if err != nil || len(t) != 3 {
+		return nil, &ForeignKeyError{Key: key, Reason: "malformed tag key"}
+	}
+	return t[2], nil
+}
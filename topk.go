@@ -0,0 +1,153 @@
+package vector
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+// A numeric item kept by topK.
+type rankedItem struct {
+	index int64
+	isInt bool
+	i     int64
+	f     float64
+	raw   []byte
+}
+
+// Whether a ranks before b: the larger number (the smaller one when
+// smallest is set), the lower index on ties.
+func (a rankedItem) before(b rankedItem, smallest bool) bool {
+	var c int
+	switch {
+	case a.isInt && b.isInt:
+		c = compareInts(a.i, b.i)
+	default:
+		c = compareFloats(a.number(), b.number())
+	}
+	if c == 0 {
+		return a.index < b.index
+	}
+	return (c > 0) != smallest
+}
+
+func (a rankedItem) number() float64 {
+	if a.isInt {
+		return float64(a.i)
+	}
+	return a.f
+}
+
+func compareInts(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloats(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// Min-heap of the best items seen so far: the root is the worst of them,
+// the one to drop when a better item turns up.
+type rankedHeap struct {
+	items    []rankedItem
+	smallest bool
+}
+
+func (h *rankedHeap) Len() int           { return len(h.items) }
+func (h *rankedHeap) Less(i, j int) bool { return h.items[j].before(h.items[i], h.smallest) }
+func (h *rankedHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *rankedHeap) Push(x interface{}) { h.items = append(h.items, x.(rankedItem)) }
+func (h *rankedHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// Return the k stored items in [start, stop) with the largest numeric
+// values, largest first, ties by index. start and stop are interpreted as
+// by GetRange. The range is streamed and only k items are held, so large
+// ranges can be ranked without reading them into memory; items that are
+// not ints or floats, and sparse items, are skipped.
+func (vect *Vector) TopK(k int, start, stop int64, tr fdb.ReadTransaction) (_ []IndexValue, err error) {
+	defer func() { err = vect.opError("TopK", -1, err) }()
+
+	return vect.topK("TopK", k, start, stop, false, tr)
+}
+
+// Like TopK, but the k smallest values, smallest first.
+func (vect *Vector) BottomK(k int, start, stop int64, tr fdb.ReadTransaction) (_ []IndexValue, err error) {
+	defer func() { err = vect.opError("BottomK", -1, err) }()
+
+	return vect.topK("BottomK", k, start, stop, true, tr)
+}
+
+func (vect *Vector) topK(op string, k int, start, stop int64, smallest bool, tr fdb.ReadTransaction) (_ []IndexValue, err error) {
+	vro := VectRange{Start: start, Stop: stop}
+	info, err := vect.beforeScan(op, vro)
+	if err != nil {
+		return nil, err
+	}
+	defer vect.afterOp(info, &err)
+
+	if k <= 0 {
+		return nil, nil
+	}
+	vi, err := vect.getRange(vro, tr)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &rankedHeap{smallest: smallest}
+	for vi.Advance() {
+		lv, err := vi.GetLazy()
+		if err != nil {
+			return nil, err
+		}
+		info.addBytes(len(lv.raw))
+
+		item := rankedItem{index: lv.Index, raw: lv.raw}
+		switch {
+		case lv.IsInt():
+			item.isInt = true
+			item.i, err = lv.Int()
+		case lv.IsFloat():
+			item.f, err = lv.Float()
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if h.Len() < k {
+			heap.Push(h, item)
+		} else if item.before(h.items[0], smallest) {
+			h.items[0] = item
+			heap.Fix(h, 0)
+		}
+	}
+
+	sort.Slice(h.items, func(i, j int) bool { return h.items[i].before(h.items[j], smallest) })
+	result := make([]IndexValue, len(h.items))
+	for i, item := range h.items {
+		val, err := ValUnpack(item.raw)
+		if err != nil {
+			return nil, err
+		}
+		result[i] = IndexValue{Index: item.index, Value: val}
+	}
+	return result, nil
+}
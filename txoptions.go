@@ -0,0 +1,81 @@
+package vector
+
+import (
+	"time"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+/*
+ * TxOptions - fdb transaction options for the transactions the package
+ * runs itself (SnapshotClone, BackupTo, Exporter, Atomic, ...). The zero
+ * value keeps the binding's defaults.
+ */
+type TxOptions struct {
+	Timeout       time.Duration // fdb timeout of each transaction, 0 for none
+	RetryLimit    int           // retries before giving up, 0 for the default
+	PriorityBatch bool          // run at batch priority
+	PriorityHigh  bool          // run at system immediate priority
+}
+
+// Wrap db so that every transaction it runs gets opts applied. Pass the
+// result to the helpers taking an fdb.Transactor:
+//
+//	err := src.SnapshotClone(dest, vector.WithTxOptions(db, vector.TxOptions{
+//		Timeout:       10 * time.Second,
+//		PriorityBatch: true,
+//	}))
+func WithTxOptions(db fdb.Transactor, opts TxOptions) fdb.Transactor {
+	return optionsTransactor{db: db, opts: opts}
+}
+
+type optionsTransactor struct {
+	db   fdb.Transactor
+	opts TxOptions
+}
+
+func (ot optionsTransactor) Transact(f func(fdb.Transaction) (interface{}, error)) (interface{}, error) {
+	return ot.db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		// options do not survive a retry, so they are set on every attempt
+		if err := ot.opts.apply(tr); err != nil {
+			return nil, err
+		}
+		return f(tr)
+	})
+}
+
+func (ot optionsTransactor) ReadTransact(f func(fdb.ReadTransaction) (interface{}, error)) (interface{}, error) {
+	return ot.db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		if tr, ok := rtr.(fdb.Transaction); ok {
+			if err := ot.opts.apply(tr); err != nil {
+				return nil, err
+			}
+		}
+		return f(rtr)
+	})
+}
+
+// Apply the transaction options to tr.
+func (opts TxOptions) apply(tr fdb.Transaction) error {
+	if opts.Timeout > 0 {
+		if err := tr.Options().SetTimeout(int64(opts.Timeout / time.Millisecond)); err != nil {
+			return err
+		}
+	}
+	if opts.RetryLimit > 0 {
+		if err := tr.Options().SetRetryLimit(int64(opts.RetryLimit)); err != nil {
+			return err
+		}
+	}
+	if opts.PriorityBatch {
+		if err := tr.Options().SetPriorityBatch(); err != nil {
+			return err
+		}
+	}
+	if opts.PriorityHigh {
+		if err := tr.Options().SetPrioritySystemImmediate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package vector
+
+import "github.com/FoundationDB/fdb-go/fdb"
+
+/*
+ * Updater - computes the new value for an item. Returning nil leaves the
+ * item untouched.
+ */
+type Updater func(index int64, val *Value) (interface{}, error)
+
+// Rewrite every stored item with index in [start, stop) with the result of
+// fn, in one pass over the range. Sparse items are left sparse. stop is
+// clamped to the size of the vector.
+func (vect *Vector) UpdateRange(start, stop int64, fn Updater, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("UpdateRange", -1, err) }()
+
+	return vect.updateRange(start, stop, fn, false, tr)
+}
+
+// Like UpdateRange, but sparse items in the span are passed to fn as the
+// sparse default Value and the result is stored, filling the gaps.
+func (vect *Vector) UpdateRangeFill(start, stop int64, fn Updater, tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("UpdateRangeFill", -1, err) }()
+
+	return vect.updateRange(start, stop, fn, true, tr)
+}
+
+func (vect *Vector) updateRange(start, stop int64, fn Updater, fill bool, tr fdb.Transaction) error {
+	op := "UpdateRange"
+	if fill {
+		op = "UpdateRangeFill"
+	}
+	if err := vect.mutation(op, start, tr); err != nil {
+		return err
+	}
+	if start < 0 {
+		return outOfRange("vector.updaterange", start)
+	}
+
+	size, err := vect.Size(tr)
+	if err != nil {
+		return err
+	}
+	if stop > size {
+		stop = size
+	}
+	if start >= stop {
+		return nil
+	}
+
+	kr := fdb.KeyRange{Begin: vect.keyAt(start), End: vect.keyAt(stop)}
+	ri := tr.GetRange(kr, fdb.RangeOptions{}).Iterator()
+
+	next := start // first index not yet visited, for gap filling
+	for ri.Advance() {
+		kv, err := ri.Get()
+		if err != nil {
+			return err
+		}
+		index, err := vect.indexAt(kv.Key)
+		if err != nil {
+			return err
+		}
+
+		if fill {
+			for ; next < index; next++ {
+				if err := vect.updateSparse(next, fn, tr); err != nil {
+					return err
+				}
+			}
+			next = index + 1
+		}
+
+		val, err := ValUnpack(kv.Value)
+		if err != nil {
+			return err
+		}
+		if err := vect.update(index, val, fn, tr); err != nil {
+			return err
+		}
+	}
+
+	if fill {
+		for ; next < stop; next++ {
+			if err := vect.updateSparse(next, fn, tr); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Apply fn to a single item and write back the result.
+func (vect *Vector) update(index int64, val *Value, fn Updater, tr fdb.Transaction) error {
+	nv, err := fn(index, val)
+	if err != nil || nv == nil {
+		return err
+	}
+	packed, err := ValPack(nv)
+	if err != nil {
+		return err
+	}
+	return vect.setKey(vect.keyAt(index), packed, tr)
+}
+
+// Run fn on the sparse item at index and store the result.
+func (vect *Vector) updateSparse(index int64, fn Updater, tr fdb.Transaction) error {
+	val, err := vect.sparseValue(index)
+	if err != nil {
+		return err
+	}
+	return vect.update(index, val, fn, tr)
+}
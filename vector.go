@@ -2,11 +2,15 @@ package vector
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/FoundationDB/fdb-go/fdb"
 	"github.com/FoundationDB/fdb-go/fdb/directory"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
 	"github.com/FoundationDB/fdb-go/fdb/tuple"
 )
 
@@ -22,70 +26,346 @@ import (
  *
  * By creating Vector with a Subspace, all kv pairs modified by the
  * layer will have keys that start within that Subspace.
+ *
+ * Vectors can live inside a directory partition (see OpenIn): every key is
+ * derived from the vector's own directory subspace, which the directory
+ * layer allocates inside the partition, and the layer never reads or
+ * writes outside that subspace. The root of a partition cannot hold a
+ * vector itself, because the partition does not allow keys to be packed
+ * directly under it.
+ *
+ * Items are keyed by a single int64 tuple element. Any bookkeeping keys the
+ * layer keeps (counters, metadata) are packed under string tuple elements,
+ * which sort before every index key and so never take part in Size or
+ * range reads.
  */
 
+// First byte after the prefix of FixedKeys index keys. It sorts after the
+// tuple type codes of the bookkeeping keys and before the end of the
+// subspace range (0xff).
+const fixedKeyMarker = 0xfe
+
+// Layer of directory partitions.
+var partitionLayer = []byte("partition")
+
 type Vector struct {
-	subspace     directory.DirectorySubspace
+	subspace     subspace.Subspace
 	defaultValue string
+	db           *fdb.Database // set when opened through Open
+	opts         Options
+	hooks        Hooks
+	codec        KeyCodec // index key encoding, see NewVectorAt
+	authorizer   Authorizer
+	principal    string // recorded in audit records, see WithPrincipal
+	defaultFunc  DefaultFunc
+	schema       *Schema // see OpenWithSchema
+	progress     ProgressFunc
+}
+
+/*
+ * Options - per-vector behaviour switches, applied with WithOptions.
+ * The zero value gives the classic sparse vector.
+ */
+type Options struct {
+	// Dense makes Set fail with ErrSparseWrite when index > Size instead
+	// of silently leaving a sparse gap.
+	Dense bool
+
+	// NegativeIndexes makes Get and Set treat index < 0 as an offset from
+	// the end, Python style: -1 is the last item. The offset is resolved
+	// against Size in the same transaction.
+	NegativeIndexes bool
+
+	// SkipForeignKeys makes Size and iteration step over keys in the
+	// subspace that are not vector indexes instead of failing with a
+	// *ForeignKeyError.
+	SkipForeignKeys bool
+
+	// History makes Set, Push and Pop record the value each write replaced
+	// under (index, versionstamp) in a history subspace, queried with
+	// GetHistory and GetAt. Costs a read of the old value per write.
+	// Atomic mutations (Add, AtomicMax, ...) are not recorded.
+	History bool
+
+	// Timestamps stores the client's wall clock time with every value
+	// written by Set, Push and UpdateRange, reported as Value.Modified.
+	// Add, AtomicMin, AtomicMax and the Bit* mutations fail with
+	// ErrUnsupported on a vector with Timestamps.
+	Timestamps bool
+
+	// Tags enables SetWithTags, Tag, Untag and GetByTag, kept as sibling
+	// index subspaces. Pop and Clear then also drop the tags of the items
+	// they remove.
+	Tags bool
+
+	// FixedKeys stores items under the prefix, a marker byte and the index
+	// as 8 byte big-endian integer instead of a tuple-encoded int. Keys
+	// sort identically and are turned into indexes without tuple decoding.
+	// The choice is recorded in the vector's metadata by Init and cannot
+	// be changed once the vector holds items.
+	FixedKeys bool
+
+	// AtomicPush makes Push reserve its slot from a size counter key
+	// maintained with the atomic MAX mutation instead of reading the last
+	// key of the vector. Concurrent pushers then only conflict when they
+	// race for the very same slot, rather than with every write near the
+	// tail. Set bumps the counter and Pop rewrites it so it stays in step;
+	// a Pop racing a Push may leave a sparse slot behind.
+	// MAX requires API version 300 or later.
+	AtomicPush bool
+
+	// ChangeFeed records every write made by Set, Push, Pop, UpdateRange
+	// and Clear in a change feed ordered by commit version, read with
+	// ReadChanges or tailed by an Exporter. Atomic mutations are not
+	// recorded.
+	ChangeFeed bool
+
+	// MaxClaims moves an item claimed this many times without an Ack to
+	// the dead-letter subspace on its next Claim; 0 retries forever.
+	MaxClaims int
+
+	// DeadLetter is the subspace dead-lettered items are moved to, by
+	// default ("dead") in the vector's subspace.
+	DeadLetter subspace.Subspace
+
+	// Audit, when set, is the subspace every mutation is recorded in
+	// with the principal of the handle making it; see QueryAudit.
+	Audit subspace.Subspace
+
+	// Homogeneous records the type of the first value written in the
+	// vector's metadata and rejects writes of any other type with a
+	// *TypeMismatchError. Counter, Ordered and plain ints count as
+	// different types since their layouts differ. The items Pop stores
+	// in place of sparse ones are not checked, so pick a default of the
+	// vector's type.
+	Homogeneous bool
+
+	// ElideDefault makes writes of the vector's default value clear the
+	// item's key instead of storing it, so long runs of the default cost
+	// no keys at all and read back as sparse items (with Origin
+	// OriginSparseDefault). The default must come from WithDefaultFunc,
+	// since only then do sparse items read back as it; writes fail with
+	// ErrNoDefaultFunc otherwise. The last item is stored regardless, and
+	// eliding costs a size read per write of the default. Timestamps are
+	// not kept for elided items. Existing items are compacted with
+	// CompactDefaults.
+	ElideDefault bool
+
+	// Aggregates maintains the sum, count, minimum and maximum of the
+	// vector's int items in keys of their own with atomic mutations on
+	// every Set, Push, Pop and removal, read in one round trip with
+	// Vector.Aggregates. Costs a read of the old value per write, like
+	// History. Floats are not aggregated, FDB has no atomic float add, and
+	// the atomic mutations on items (Add, AtomicMax, ...) are not tracked.
+	Aggregates bool
+
+	// Notify bumps a ("dirty") counter key with an atomic ADD on every
+	// mutation, so other processes can learn that the vector changed by
+	// watching a single key (see WatchDirty) instead of tailing the
+	// change feed.
+	Notify bool
 }
 
 /*
  * VectRange - A structure for holding vector range parameters
+ *
+ * Start is inclusive and Stop exclusive. Both are resolved against the
+ * size of the vector in the transaction reading the range: a negative
+ * Start or Stop counts from the end (Start -3 is the last three items)
+ * and a Stop of 0 means the end of the vector. Set Open to read to the
+ * end regardless of Stop, which also lets a descending range (negative
+ * Step) run down to index 0. A Step of 0 is ascending unless Start is
+ * past Stop.
  */
 type VectRange struct {
 	Start int64
 	Stop  int64
 	Step  int64
+	Open  bool
 }
 
-/*****************************************************************************
- * Public Methods
- ****************************************************************************/
+// Range of the items from start to the end of the vector.
+func RangeFrom(start int64) VectRange {
+	return VectRange{Start: start, Open: true}
+}
 
-// Get the number of items in the Vector. This number includes the sparsely represented items.
-func (vect *Vector) Size(tr fdb.Transaction) (int64, error) {
+// Range of the last n items of the vector, fewer if it is shorter, none
+// if n is not positive.
+func RangeLast(n int64) VectRange {
+	if n <= 0 {
+		return VectRange{Start: math.MaxInt64, Open: true}
+	}
+	return VectRange{Start: -n, Open: true}
+}
 
-	begin, end := vect.subspace.FDBRangeKeys()
+// Create a Vector storing its items in subspace, usually a directory
+// subspace. Sparse items read back as defaultValue.
+func NewVector(subspace subspace.Subspace, defaultValue string) *Vector {
+	return &Vector{
+		subspace:     subspace,
+		defaultValue: defaultValue,
+	}
+}
 
-	// GET is a blocking operation
-	lastkey, err := tr.GetKey(fdb.LastLessOrEqual(end)).Get()
-	if err != nil {
-		return 0, err
+// Create a Vector storing its items under the raw key prefix, for callers
+// managing their own keyspace without the directory layer (and its reads
+// when opening). Keys are laid out exactly as under a directory subspace
+// with the same prefix.
+func NewVectorWithPrefix(prefix []byte, defaultValue string) *Vector {
+	return NewVector(subspace.FromBytes(prefix), defaultValue)
+}
+
+// Create a Vector rooted at any key, such as a tuple.Tuple of the
+// caller's own composite key scheme, with index keys encoded by codec
+// (TupleKeys when nil). Bookkeeping keys are tuple-packed under root as
+// usual. No directory layer is involved.
+func NewVectorAt(root fdb.KeyConvertible, codec KeyCodec, defaultValue string) *Vector {
+	if codec == nil {
+		codec = TupleKeys
 	}
-	// lastkey < beginKey indicates an empty vector
-	if bytes.Compare(lastkey, begin.FDBKey()) == -1 {
-		return 0, nil
+	vect := NewVector(subspace.FromBytes(root.FDBKey()), defaultValue)
+	vect.codec = codec
+	return vect
+}
+
+// Create or open the Vector at path in the directory layer. The returned
+// Vector remembers db so that helpers composing several vectors can check
+// they all live in the same database.
+func Open(db fdb.Database, path []string, defaultValue string) (*Vector, error) {
+	return OpenIn(db, directory.Root(), path, defaultValue)
+}
+
+// Create or open the Vector at path relative to parent, e.g. a directory
+// partition. Fails with ErrPartitionRoot if path is itself a partition.
+func OpenIn(db fdb.Database, parent directory.Directory, path []string, defaultValue string) (*Vector, error) {
+	vect, err := OpenWith(db, parent, path, defaultValue)
+	if err != nil {
+		return nil, err
 	}
+	vect.db = &db
+	return vect, nil
+}
 
-	index, err := vect.indexAt(lastkey)
+// Create or open the Vector at path relative to parent using any
+// Transactor. With bindings that support tenants (API version 710) t can
+// be a Tenant, so the directory and every key of the vector live in the
+// tenant's keyspace; the Vector must then only be used with transactions
+// of that tenant.
+//
+// The default value is recorded in the vector's metadata when it is first
+// opened; opening it with a different one fails with ErrMetadataMismatch.
+func OpenWith(t fdb.Transactor, parent directory.Directory, path []string, defaultValue string) (*Vector, error) {
+	subspace, err := parent.CreateOrOpen(t, path, nil)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	if bytes.Equal(subspace.GetLayer(), partitionLayer) {
+		return nil, ErrPartitionRoot
+	}
+	vect := NewVector(subspace, defaultValue)
+	_, err = t.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		return nil, vect.initDefault(tr)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return vect, nil
+}
+
+// Return a copy of the Vector using opts. The receiver is left unchanged
+// so handles shared between goroutines stay consistent.
+func (vect *Vector) WithOptions(opts Options) *Vector {
+	v := *vect
+	v.opts = opts
+	return &v
+}
+
+/*
+ * DefaultFunc - computes the value of the sparse item at index, in place
+ * of a single default for the whole vector, see WithDefaultFunc.
+ */
+type DefaultFunc func(index int64) interface{}
 
-	return index + 1, nil
+// Return a copy of the Vector reading sparse items as fn(index), e.g.
+// zeros or ids derived from the index. The items Pop and PopMany store in
+// place of sparse ones come from fn as well. fn must be deterministic and
+// return values ValPack can encode.
+func (vect *Vector) WithDefaultFunc(fn DefaultFunc) *Vector {
+	v := *vect
+	v.defaultFunc = fn
+	return &v
+}
+
+// The options the Vector was configured with.
+func (vect *Vector) Options() Options {
+	return vect.opts
+}
+
+/*****************************************************************************
+ * Public Methods
+ ****************************************************************************/
+
+// Get the number of items in the Vector. This number includes the sparsely represented items.
+func (vect *Vector) Size(tr fdb.ReadTransaction) (int64, error) {
+	return vect.SizeAsync(tr).Get()
 }
 
 // Set the value at a particular index in the Vector.
-func (vect *Vector) Set(index int64, val interface{}, tr fdb.Transaction) error {
-	v, err := ValPack(val)
+// In Dense mode index may be at most Size, i.e. overwrite or append.
+func (vect *Vector) Set(index int64, val interface{}, tr fdb.Transaction) (err error) {
+	info, err := vect.beforeWrite("Set", index, tr)
 	if err != nil {
 		return err
 	}
-	tr.Set(vect.keyAt(index), v)
-	return nil
+	defer vect.afterOp(info, &err)
+
+	buf := AcquireBuffer()
+	defer ReleaseBuffer(buf)
+	v, err := AppendPack(*buf, val)
+	if err != nil {
+		return err
+	}
+	*buf = v
+	info.addBytes(len(v))
+	key, err := vect.prepareWrite("vector.set", index, tr)
+	if err != nil {
+		return err
+	}
+	return vect.setKey(key, v, tr)
+}
+
+// Store packed, a value as ValPack or Value.Raw returns it, at index
+// without decoding it, so tools moving items between vectors copy them
+// verbatim. With Options.Timestamps the item is stamped with the time of
+// this write, replacing any stamp it carried.
+func (vect *Vector) SetRaw(index int64, packed []byte, tr fdb.Transaction) error {
+	if vect.opts.Timestamps && len(packed) >= 10 && packed[0] == 0x05 {
+		packed = packed[9:]
+	}
+	return vect.Set(index, Raw(packed), tr)
 }
 
 // Get the item at the specified index.
-func (vect *Vector) Get(index int64, tr fdb.Transaction) (*Value, error) {
+func (vect *Vector) Get(index int64, tr fdb.ReadTransaction) (_ *Value, err error) {
+	info, err := vect.beforeOp("Get", index)
+	if err != nil {
+		return nil, err
+	}
+	defer vect.afterOp(info, &err)
+
+	index, err = vect.resolveIndex(index, tr)
+	if err != nil {
+		return nil, err
+	}
 	if index < 0 {
-		return nil, fmt.Errorf("vector.get: index '%d' out of range", index)
+		return nil, outOfRange("vector.get", index)
 	}
 
 	// Instead of getting key directly we want to ensure key is within vector
 	// subspace and if it is even if no key exists, provide a sparse default value.
 	// If key is not within vector extents, then we throw an out-of-range error.
 	start := vect.keyAt(index)
-	_, end := vect.subspace.FDBRangeKeys()
+	_, end := vect.indexRange().FDBRangeKeys()
 	keyRange := fdb.KeyRange{
 		Begin: start,
 		End:   end,
@@ -97,10 +377,11 @@ func (vect *Vector) Get(index int64, tr fdb.Transaction) (*Value, error) {
 		return nil, err
 	}
 	if len(justOne) == 0 {
-		return nil, fmt.Errorf("vector.get: index '%d' out of range", index)
+		return nil, outOfRange("vector.get", index)
 	}
 	// if this is a direct hit we return the value at the key index.
 	if bytes.Compare(start, justOne[0].Key) == 0 {
+		info.addBytes(len(justOne[0].Value))
 		v, err := ValUnpack(justOne[0].Value)
 		if err != nil {
 			return nil, err
@@ -108,28 +389,69 @@ func (vect *Vector) Get(index int64, tr fdb.Transaction) (*Value, error) {
 		return v, nil
 	}
 	// If it is not, we fullfill sparsity and return the default Value.
-	return &Value{}, nil
+	return vect.sparseValue(index)
+}
+
+// Like Get, but a sparse item reads as fallback instead of the vector's
+// default, still with Origin OriginSparseDefault. fallback is packed and
+// unpacked like a stored value, so it comes back typed the same way.
+// Indexes past the end still fail with ErrIndexOutOfRange.
+func (vect *Vector) GetOr(index int64, fallback interface{}, tr fdb.ReadTransaction) (_ *Value, err error) {
+	defer func() { err = vect.opError("GetOr", index, err) }()
+
+	packed, err := ValPack(fallback)
+	if err != nil {
+		return nil, err
+	}
+	plain := *vect
+	plain.defaultFunc = nil
+	val, err := plain.Get(index, tr)
+	if err != nil || val.Origin != OriginSparseDefault {
+		return val, err
+	}
+	if val, err = ValUnpack(packed); err != nil {
+		return nil, err
+	}
+	val.Origin = OriginSparseDefault
+	return val, nil
 }
 
 // Push a single item onto the end of the Vector.
-func (vect *Vector) Push(val interface{}, tr fdb.Transaction) error {
-	size, err := vect.Size(tr)
+func (vect *Vector) Push(val interface{}, tr fdb.Transaction) (err error) {
+	info, err := vect.beforeWrite("Push", -1, tr)
 	if err != nil {
 		return err
 	}
+	defer vect.afterOp(info, &err)
 
-	v, err := ValPack(val)
+	buf := AcquireBuffer()
+	defer ReleaseBuffer(buf)
+	v, err := AppendPack(*buf, val)
 	if err != nil {
 		return err
 	}
+	*buf = v
+	info.addBytes(len(v))
 
-	tr.Set(vect.keyAt(size), v)
+	if vect.opts.AtomicPush {
+		return vect.atomicPush(v, tr)
+	}
 
-	return nil
+	size, err := vect.Size(tr)
+	if err != nil {
+		return err
+	}
+
+	return vect.setKey(vect.keyAt(size), v, tr)
 }
 
 // Get and pops the last item off the Vector.
-func (vect *Vector) Pop(tr fdb.Transaction) (*Value, error) {
+func (vect *Vector) Pop(tr fdb.Transaction) (_ *Value, err error) {
+	info, err := vect.beforeWrite("Pop", -1, tr)
+	if err != nil {
+		return nil, err
+	}
+	defer vect.afterOp(info, &err)
 
 	// Read the last two entries so we can check if the second to last item
 	// is being represented sparsely. If so, we will be required to set it
@@ -138,7 +460,7 @@ func (vect *Vector) Pop(tr fdb.Transaction) (*Value, error) {
 		Limit:   2,
 		Reverse: true,
 	}
-	lastTwo, err := tr.GetRange(vect.subspace, ropts).GetSliceWithError()
+	lastTwo, err := tr.GetRange(vect.indexRange(), ropts).GetSliceWithError()
 	if err != nil {
 		return nil, err
 	}
@@ -154,21 +476,43 @@ func (vect *Vector) Pop(tr fdb.Transaction) (*Value, error) {
 
 	// Vector was empty // Should this be an error?
 	if len(lastTwo) == 0 {
-		return &Value{}, nil
+		return &Value{Origin: OriginMissing}, nil
 
 	} else if indices[0] == 0 {
 		// pass
 	} else if len(lastTwo) == 1 || indices[0] > indices[1]+1 {
 		// Second to last item is being represented sparsely
-		v, err := ValPack(vect.defaultValue) //
+		v, err := vect.defaultPacked(indices[0] - 1)
 		if err != nil {
 			return nil, err
 		}
 		tr.Set(vect.keyAt(indices[0]-1), v)
+		if vect.opts.ChangeFeed {
+			vect.writeChange(indices[0]-1, v, tr)
+		}
+		vect.aggregate(nil, v, tr)
 	}
 
+	if vect.opts.History {
+		vect.writeHistory(indices[0], lastTwo[0].Value, tr)
+	}
+	if vect.opts.ChangeFeed {
+		vect.writeChange(indices[0], nil, tr)
+	}
+	vect.aggregate(lastTwo[0].Value, nil, tr)
 	tr.Clear(lastTwo[0].Key)
+	tr.Clear(vect.lockKey(indices[0]))
+	tr.Clear(vect.attemptsKey(indices[0]))
+	if vect.opts.Tags {
+		if err := vect.clearTags(indices[0], tr); err != nil {
+			return nil, err
+		}
+	}
+	if vect.opts.AtomicPush {
+		tr.Set(vect.sizeKey(), packCounter(indices[0]))
+	}
 
+	info.addBytes(len(lastTwo[0].Value))
 	val, err := ValUnpack(lastTwo[0].Value)
 	if err != nil {
 		return nil, err
@@ -177,44 +521,350 @@ func (vect *Vector) Pop(tr fdb.Transaction) (*Value, error) {
 	return val, nil
 }
 
-// Get the value of the last item in the Vector.
-func (vect *Vector) Back(tr fdb.Transaction) (*Value, error) {
-	ropts := fdb.RangeOptions{
-		Limit:   1,
-		Reverse: true,
+// Pop up to n items off the Vector, last item first, with one range read
+// and one range clear. Returns the same items as n calls to Pop, sparse
+// items included as the default value.
+func (vect *Vector) PopMany(n int, tr fdb.Transaction) (_ []*Value, err error) {
+	info, err := vect.beforeWrite("PopMany", -1, tr)
+	if err != nil {
+		return nil, err
 	}
-	last, err := tr.GetRange(vect.subspace, ropts).GetSliceWithError()
+	defer vect.afterOp(info, &err)
+
+	last, err := tr.GetRange(vect.indexRange(), fdb.RangeOptions{Limit: 1, Reverse: true}).GetSliceWithError()
 	if err != nil {
 		return nil, err
 	}
-	if len(last) == 0 {
-		// should this be an error?
-		return &Value{}, nil
+	if len(last) == 0 || n <= 0 {
+		return []*Value{}, nil
+	}
+	size, err := vect.indexAt(last[0].Key)
+	if err != nil {
+		return nil, err
+	}
+	size++
+	newSize := size - int64(n)
+	if newSize < 0 {
+		newSize = 0
 	}
 
-	val, err := ValUnpack(last[0].Value)
+	// the popped items and the one that becomes the last, which has to be
+	// stored if it is sparse
+	begin := newSize - 1
+	if begin < 0 {
+		begin = 0
+	}
+	_, end := vect.indexRange().FDBRangeKeys()
+	kvs, err := tr.GetRange(fdb.KeyRange{Begin: vect.keyAt(begin), End: end}, fdb.RangeOptions{}).GetSliceWithError()
 	if err != nil {
 		return nil, err
 	}
+	stored := make(map[int64][]byte, len(kvs))
+	for _, kv := range kvs {
+		index, err := vect.indexAt(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		stored[index] = kv.Value
+	}
 
-	return val, nil
+	if _, ok := stored[newSize-1]; newSize > 0 && !ok {
+		def, err := vect.defaultPacked(newSize - 1)
+		if err != nil {
+			return nil, err
+		}
+		tr.Set(vect.keyAt(newSize-1), def)
+		if vect.opts.ChangeFeed {
+			vect.writeChange(newSize-1, def, tr)
+		}
+		vect.aggregate(nil, def, tr)
+	}
+
+	vals := make([]*Value, 0, size-newSize)
+	for index := size - 1; index >= newSize; index-- {
+		packed, ok := stored[index]
+		info.addBytes(len(packed))
+		if !ok {
+			if packed, err = vect.defaultPacked(index); err != nil {
+				return nil, err
+			}
+		} else {
+			if vect.opts.History {
+				vect.writeHistory(index, packed, tr)
+			}
+			if vect.opts.ChangeFeed {
+				vect.writeChange(index, nil, tr)
+			}
+			vect.aggregate(packed, nil, tr)
+		}
+		if vect.opts.Tags {
+			if err := vect.clearTags(index, tr); err != nil {
+				return nil, err
+			}
+		}
+		val, err := ValUnpack(packed)
+		if err != nil {
+			return nil, err
+		}
+		vals = append(vals, val)
+	}
+
+	tr.ClearRange(fdb.KeyRange{Begin: vect.keyAt(newSize), End: end})
+	for _, name := range []string{"lock", "attempts"} {
+		_, subEnd := vect.subspace.Sub(name).FDBRangeKeys()
+		from := vect.subspace.Pack(tuple.Tuple{name, newSize})
+		tr.ClearRange(fdb.KeyRange{Begin: from, End: subEnd})
+	}
+	if vect.opts.AtomicPush {
+		tr.Set(vect.sizeKey(), packCounter(newSize))
+	}
+	return vals, nil
+}
+
+// Get the value of the last item in the Vector.
+func (vect *Vector) Back(tr fdb.ReadTransaction) (_ *Value, err error) {
+	defer func() { err = vect.opError("Back", -1, err) }()
+
+	return vect.BackAsync(tr).Get()
 }
 
 // Get the value of the first item in the Vector.
-func (vect *Vector) Front(tr fdb.Transaction) (*Value, error) {
+func (vect *Vector) Front(tr fdb.ReadTransaction) (_ *Value, err error) {
+	defer func() { err = vect.opError("Front", 0, err) }()
+
 	return vect.Get(0, tr)
 }
 
+// Get up to n stored items from the end of the Vector, last item first,
+// without removing them. Sparse items are not returned.
+func (vect *Vector) Peek(n int, tr fdb.ReadTransaction) (_ []IndexValue, err error) {
+	defer func() { err = vect.opError("Peek", -1, err) }()
+
+	if n <= 0 {
+		return []IndexValue{}, nil
+	}
+	return vect.peek(n, true, tr)
+}
+
+// Get up to n stored items from the start of the Vector, first item first,
+// without removing them. Sparse items are not returned.
+func (vect *Vector) PeekFront(n int, tr fdb.ReadTransaction) (_ []IndexValue, err error) {
+	defer func() { err = vect.opError("PeekFront", -1, err) }()
+
+	if n <= 0 {
+		return []IndexValue{}, nil
+	}
+	return vect.peek(n, false, tr)
+}
+
+// Get the indexes of up to n stored items from the start of the Vector,
+// in ascending order. Only keys are read: each index is resolved by a key
+// selector of its own, all issued at once, so no value is fetched or
+// decoded. Meant for small n, e.g. to Watch or lock the items about to be
+// consumed.
+func (vect *Vector) HeadIndexes(n int, tr fdb.ReadTransaction) (_ []int64, err error) {
+	defer func() { err = vect.opError("HeadIndexes", -1, err) }()
+
+	if n <= 0 {
+		return []int64{}, nil
+	}
+	begin, _ := vect.indexRange().FDBRangeKeys()
+	sels := make([]fdb.KeySelector, n)
+	for i := range sels {
+		sels[i] = fdb.KeySelector{Key: begin, Offset: i + 1} // FirstGreaterOrEqual, then i further
+	}
+	return vect.indexesAt(sels, tr)
+}
+
+// Like HeadIndexes, but from the end of the Vector, last index first.
+func (vect *Vector) TailIndexes(n int, tr fdb.ReadTransaction) (_ []int64, err error) {
+	defer func() { err = vect.opError("TailIndexes", -1, err) }()
+
+	if n <= 0 {
+		return []int64{}, nil
+	}
+	_, end := vect.indexRange().FDBRangeKeys()
+	sels := make([]fdb.KeySelector, n)
+	for i := range sels {
+		sels[i] = fdb.KeySelector{Key: end, Offset: -i} // LastLessThan, then i further back
+	}
+	return vect.indexesAt(sels, tr)
+}
+
+// Resolve sels concurrently to the indexes of their keys, stopping at the
+// first one falling outside the index range.
+func (vect *Vector) indexesAt(sels []fdb.KeySelector, tr fdb.ReadTransaction) ([]int64, error) {
+	futures := make([]fdb.FutureKey, len(sels))
+	for i, sel := range sels {
+		futures[i] = tr.GetKey(sel)
+	}
+
+	kr := vect.indexRange()
+	begin, end := kr.FDBRangeKeys()
+	indexes := make([]int64, 0, len(sels))
+	for _, f := range futures {
+		key, err := f.Get()
+		if err != nil {
+			return nil, err
+		}
+		if bytes.Compare(key, begin.FDBKey()) < 0 || bytes.Compare(key, end.FDBKey()) >= 0 {
+			break
+		}
+		index, err := vect.indexAt(key)
+		if err != nil {
+			if vect.opts.SkipForeignKeys && errors.Is(err, ErrForeignKey) {
+				continue
+			}
+			return nil, err
+		}
+		indexes = append(indexes, index)
+	}
+	return indexes, nil
+}
+
 // Get a range of items in the Vector, returned as a generator.
 // To get the range to the last value, set endIdx as -1.
 // Empty VectRange (or setting all values to 0) will return the
 // full range.
-func (vect *Vector) GetRange(vro VectRange, tr fdb.Transaction) (*Vectorator, error) {
-	size, err := vect.Size(tr)
+//
+// The OnAfterOp hook runs once the iterator is exhausted or closed, so its
+// Duration and Bytes cover the whole scan.
+func (vect *Vector) GetRange(vro VectRange, tr fdb.ReadTransaction) (_ *Vectorator, err error) {
+	info, err := vect.beforeScan("GetRange", vro)
+	if err != nil {
+		return nil, err
+	}
+
+	vi, err := vect.getRange(vro, tr)
+	if err != nil {
+		vect.afterOp(info, &err)
+		return nil, err
+	}
+	vi.info = info
+	return vi, nil
+}
+
+// GetRange without hooks, for the scans running their own.
+func (vect *Vector) getRange(vro VectRange, tr fdb.ReadTransaction) (_ *Vectorator, err error) {
+	defer func() { err = vect.opError("GetRange", -1, err) }()
+
+	kr, reverse, err := vect.keyRange(vro, tr)
 	if err != nil {
 		return nil, err
 	}
 
+	rr := tr.GetRange(kr, fdb.RangeOptions{Reverse: reverse})
+
+	return &Vectorator{ri: rr.Iterator(), vect: vect}, nil
+
+}
+
+// Remove all items from the Vector, with their tags, locks and claim
+// counts. History records, the change feed and dead letters are kept.
+// Does nothing when the Authorizer denies it; use ClearWithError to learn
+// why.
+func (vect *Vector) Clear(tr fdb.Transaction) {
+	vect.ClearWithError(tr)
+}
+
+// Like Clear, returning the Authorizer's error when it denies the clear.
+func (vect *Vector) ClearWithError(tr fdb.Transaction) (err error) {
+	defer func() { err = vect.opError("ClearWithError", -1, err) }()
+
+	if err := vect.mutation("Clear", -1, tr); err != nil {
+		return err
+	}
+	tr.ClearRange(vect.indexRange())
+	tr.Clear(vect.sizeKey())
+	vect.clearAggregates(tr)
+	if vect.opts.ChangeFeed {
+		vect.writeClearChange(tr)
+	}
+	if vect.opts.Tags {
+		tr.ClearRange(vect.subspace.Sub("tag"))
+		tr.ClearRange(vect.subspace.Sub("tags"))
+	}
+	tr.ClearRange(vect.subspace.Sub("lock"))
+	tr.ClearRange(vect.subspace.Sub("attempts"))
+	return nil
+}
+
+// Return all stored items and clear the Vector in the same transaction,
+// so items pushed concurrently are either returned or left in place.
+// Sparse items are not returned. Needs the Authorizer to allow both
+// "Drain" and "Clear".
+func (vect *Vector) Drain(tr fdb.Transaction) (_ []IndexValue, err error) {
+	info, err := vect.beforeWrite("Drain", -1, tr)
+	if err != nil {
+		return nil, err
+	}
+	defer vect.afterOp(info, &err)
+
+	items, err := vect.peek(0, false, tr)
+	if err != nil {
+		return nil, err
+	}
+	if err := vect.ClearWithError(tr); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+/*****************************************************************************
+ * Private Methods
+ ****************************************************************************/
+
+// Resolve vro against the size of the vector into the key range to read
+// and whether to read it in reverse.
+func (vect *Vector) keyRange(vro VectRange, tr fdb.ReadTransaction) (fdb.KeyRange, bool, error) {
+	size, err := vect.Size(tr)
+	if err != nil {
+		return fdb.KeyRange{}, false, err
+	}
+	kr, reverse := vect.rangeKeys(vro, size)
+	return kr, reverse, nil
+}
+
+// The keys of vro in a vector of the given size, and whether the range
+// is read in reverse.
+func (vect *Vector) rangeKeys(vro VectRange, size int64) (fdb.KeyRange, bool) {
+	vro = vro.resolve(size)
+
+	kr := fdb.KeyRange{}
+
+	if vro.Step > 0 {
+		kr.Begin = vect.keyAt(vro.Start)
+		kr.End = vect.keyAt(vro.Stop)
+	} else {
+		kr.End = vect.keyAt(vro.Start + 1)
+		kr.Begin = vect.keyAt(vro.Stop + 1)
+	}
+
+	return kr, vro.Step < 0
+}
+
+// Resolve a Stop of 0 to size, negative Start and Stop to offsets from
+// size and an unset Step to the direction from Start to Stop. An Open
+// range gets the Stop of its end: size, or -1 when descending.
+func (vro VectRange) resolve(size int64) VectRange {
+	if vro.Open {
+		if vro.Start < 0 {
+			vro.Start = int64(math.Max(0.0, float64(size+vro.Start)))
+		}
+		if vro.Step < 0 {
+			vro.Stop = -1
+			if vro.Start >= size {
+				vro.Start = size - 1
+			}
+			return vro
+		}
+		vro.Step, vro.Stop = 1, size
+		if vro.Start > size {
+			vro.Start = size
+		}
+		return vro
+	}
+
 	if vro.Stop == 0 {
 		vro.Stop = size
 	} else if vro.Stop < 0 {
@@ -233,43 +883,295 @@ func (vect *Vector) GetRange(vro VectRange, tr fdb.Transaction) (*Vectorator, er
 			vro.Step = -1
 		}
 	}
+	return vro
+}
 
-	kr := fdb.KeyRange{}
+// Push the packed value v into the slot reserved by the size counter.
+//
+// The counter is read at snapshot isolation so that other pushers bumping
+// it do not conflict with us; the read conflict on the slot key makes two
+// pushers that reserved the same slot conflict, so neither write is lost.
+func (vect *Vector) atomicPush(v []byte, tr fdb.Transaction) error {
+	slot, err := vect.counterSize(tr)
+	if err != nil {
+		return err
+	}
 
-	if vro.Step > 0 {
-		kr.Begin = vect.keyAt(vro.Start)
-		kr.End = vect.keyAt(vro.Stop)
-	} else {
-		kr.End = vect.keyAt(vro.Start + 1)
-		kr.Begin = vect.keyAt(vro.Stop + 1)
+	key := vect.keyAt(slot)
+	if err := tr.AddReadConflictKey(key); err != nil {
+		return err
 	}
+	tr.Max(vect.sizeKey(), packCounter(slot+1))
+	return vect.setKey(key, v, tr)
+}
 
-	rr := tr.GetRange(kr, fdb.RangeOptions{Reverse: vro.Step < 0})
+// Read the AtomicPush size counter at snapshot isolation, seeding it from
+// the keys when the vector was filled before AtomicPush was turned on.
+func (vect *Vector) counterSize(tr fdb.Transaction) (int64, error) {
+	counter, err := tr.Snapshot().Get(vect.sizeKey()).Get()
+	if err != nil {
+		return 0, err
+	}
+	if counter == nil {
+		return vect.Size(tr)
+	}
+	return unpackCounter(counter), nil
+}
 
-	return &Vectorator{rr.Iterator(), vect}, nil
+// Store the packed value at key, recording the value it replaces when
+// History is on and the write when ChangeFeed is on, after checking its
+// type when Homogeneous is on.
+func (vect *Vector) setKey(key fdb.Key, packed []byte, tr fdb.Transaction) error {
+	var index int64
+	if vect.opts.History || vect.opts.ChangeFeed || vect.opts.Homogeneous || vect.opts.ElideDefault || vect.schema != nil {
+		var err error
+		if index, err = vect.indexAt(key); err != nil {
+			return err
+		}
+	}
+	if vect.schema != nil {
+		if err := vect.schema.validate(index, packed); err != nil {
+			return err
+		}
+	}
+	if vect.opts.Homogeneous {
+		if err := vect.checkType(index, packed, tr); err != nil {
+			return err
+		}
+	}
+	var old []byte
+	if vect.opts.History || vect.opts.Aggregates {
+		var err error
+		if old, err = tr.Get(key).Get(); err != nil {
+			return err
+		}
+	}
+	if vect.opts.History {
+		vect.writeHistory(index, old, tr)
+	}
+	if vect.opts.ElideDefault {
+		elide, err := vect.elides(index, packed, tr)
+		if err != nil {
+			return err
+		}
+		if elide {
+			if vect.opts.ChangeFeed {
+				vect.writeChange(index, packed, tr)
+			}
+			vect.aggregate(old, nil, tr)
+			tr.Clear(key)
+			return nil
+		}
+	}
+	vect.aggregate(old, packed, tr)
+	if vect.opts.Timestamps {
+		packed = stampValue(packed, time.Now())
+	}
+	if vect.opts.ChangeFeed {
+		vect.writeChange(index, packed, tr)
+	}
+	tr.Set(key, packed)
+	return nil
+}
 
+// Read up to n stored items from either end of the vector, all for n 0.
+func (vect *Vector) peek(n int, reverse bool, tr fdb.ReadTransaction) ([]IndexValue, error) {
+	ropts := fdb.RangeOptions{Limit: n, Reverse: reverse}
+	kvs, err := tr.GetRange(vect.indexRange(), ropts).GetSliceWithError()
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]IndexValue, 0, len(kvs))
+	for _, kv := range kvs {
+		index, err := vect.indexAt(kv.Key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := ValUnpack(kv.Value)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, IndexValue{Index: index, Value: val})
+	}
+	return items, nil
 }
 
-// Remove all items from the Vector.
-func (vect *Vector) Clear(tr fdb.Transaction) {
-	tr.ClearRange(vect.subspace)
+// Validate a write of a single item at index and return its key. Resolves
+// negative indexes, enforces Dense and keeps the AtomicPush counter in step
+// with the write the caller is about to make.
+func (vect *Vector) prepareWrite(op string, index int64, tr fdb.Transaction) (fdb.Key, error) {
+	index, err := vect.resolveIndex(index, tr)
+	if err != nil {
+		return nil, err
+	}
+	key, err := vect.indexKey(op, index)
+	if err != nil {
+		return nil, err
+	}
+	if vect.opts.Dense {
+		size, err := vect.Size(tr)
+		if err != nil {
+			return nil, err
+		}
+		if index > size {
+			return nil, fmt.Errorf("%s: index '%d' past size %d: %w", op, index, size, ErrSparseWrite)
+		}
+	}
+	if vect.opts.AtomicPush {
+		tr.Max(vect.sizeKey(), packCounter(index+1))
+	}
+	return key, nil
 }
 
-/*****************************************************************************
- * Private Methods
- ****************************************************************************/
+// Turn a negative index into an offset from the end when NegativeIndexes
+// is set. Other indexes are returned untouched.
+func (vect *Vector) resolveIndex(index int64, tr fdb.ReadTransaction) (int64, error) {
+	if index >= 0 || !vect.opts.NegativeIndexes {
+		return index, nil
+	}
+	size, err := vect.Size(tr)
+	if err != nil {
+		return 0, err
+	}
+	if size+index < 0 {
+		return 0, outOfRange("vector", index)
+	}
+	return size + index, nil
+}
+
+// The Value reported for a sparse item at index: typeless, or computed by
+// the DefaultFunc.
+func (vect *Vector) sparseValue(index int64) (*Value, error) {
+	if vect.defaultFunc == nil {
+		return &Value{Origin: OriginSparseDefault}, nil
+	}
+	v, err := ValPack(vect.defaultFunc(index))
+	if err != nil {
+		return nil, err
+	}
+	val, err := ValUnpack(v)
+	if err != nil {
+		return nil, err
+	}
+	val.Origin = OriginSparseDefault
+	return val, nil
+}
+
+// The packed value stored in place of the sparse item at index when it
+// has to be materialized, e.g. by Pop.
+func (vect *Vector) defaultPacked(index int64) ([]byte, error) {
+	if vect.defaultFunc != nil {
+		return ValPack(vect.defaultFunc(index))
+	}
+	return ValPack(vect.defaultValue)
+}
+
+// The part of the subspace holding the items: index 0 up to the end of the
+// subspace. Bookkeeping keys sort before it. All range reads and clears of
+// items go through here, so they stay inside the vector's subspace (and any
+// partition it lives in).
+func (vect *Vector) indexRange() fdb.KeyRange {
+	_, end := vect.subspace.FDBRangeKeys()
+	return fdb.KeyRange{Begin: vect.keyAt(0), End: end}
+}
+
+// Key of the AtomicPush size counter.
+func (vect *Vector) sizeKey() fdb.Key {
+	return vect.subspace.Pack(tuple.Tuple{"size"})
+}
+
+// Get the subspace key for a given index, rejecting negative indexes which
+// would sort before index 0 and break the Size math.
+func (vect *Vector) indexKey(op string, index int64) (fdb.Key, error) {
+	if index < 0 {
+		return nil, outOfRange(op, index)
+	}
+	return vect.keyAt(index), nil
+}
 
-// Get the subspace key for a given index
+// Get the subspace key for a given index. Callers must have checked that
+// index >= 0 (see indexKey).
 func (vect *Vector) keyAt(index int64) fdb.Key {
+	if vect.codec != nil {
+		prefix := vect.subspace.Bytes()
+		return vect.codec.AppendIndex(append(make([]byte, 0, len(prefix)+9), prefix...), index)
+	}
+	if vect.opts.FixedKeys {
+		prefix := vect.subspace.Bytes()
+		key := make([]byte, len(prefix)+9)
+		copy(key, prefix)
+		key[len(prefix)] = fixedKeyMarker
+		binary.BigEndian.PutUint64(key[len(prefix)+1:], uint64(index))
+		return key
+	}
 	tup := tuple.Tuple{index}
 	return vect.subspace.Pack(tup)
 }
 
-// Get the index for given key in subspace
+// Get the index for given key in subspace. Keys that are not a single
+// int64 tuple element (or fixed-width index with FixedKeys) give a
+// *ForeignKeyError.
 func (vect *Vector) indexAt(key fdb.Key) (int64, error) {
+	if vect.codec != nil {
+		prefix := vect.subspace.Bytes()
+		if !bytes.HasPrefix(key, prefix) {
+			return 0, &ForeignKeyError{Key: key, Reason: "outside the vector prefix"}
+		}
+		index, err := vect.codec.DecodeIndex(key[len(prefix):])
+		if err != nil {
+			return 0, &ForeignKeyError{Key: key, Reason: err.Error()}
+		}
+		return index, nil
+	}
+	if vect.opts.FixedKeys {
+		prefix := vect.subspace.Bytes()
+		if len(key) != len(prefix)+9 || !bytes.HasPrefix(key, prefix) || key[len(prefix)] != fixedKeyMarker {
+			return 0, &ForeignKeyError{Key: key, Reason: "not a fixed-width index key"}
+		}
+		index := int64(binary.BigEndian.Uint64(key[len(prefix)+1:]))
+		if index < 0 {
+			return 0, &ForeignKeyError{Key: key, Reason: "negative fixed-width index"}
+		}
+		return index, nil
+	}
+
+	if index, ok := vect.tupleIndex(key); ok {
+		return index, nil
+	}
+
 	islice, err := vect.subspace.Unpack(key)
 	if err != nil {
-		return 0, err
+		return 0, &ForeignKeyError{Key: key, Reason: err.Error()}
+	}
+	if len(islice) != 1 {
+		return 0, &ForeignKeyError{Key: key, Reason: fmt.Sprintf("expected 1 tuple element, got %d", len(islice))}
+	}
+	index, ok := islice[0].(int64)
+	if !ok {
+		return 0, &ForeignKeyError{Key: key, Reason: fmt.Sprintf("element is %T, not int64", islice[0])}
+	}
+	return index, nil
+}
+
+// Decode key if it is the tuple packing of a non-negative index, without
+// the allocations of a full tuple unpack. Reports false for anything else,
+// which indexAt then decodes the slow way.
+func (vect *Vector) tupleIndex(key fdb.Key) (int64, bool) {
+	prefix := vect.subspace.Bytes()
+	if len(key) <= len(prefix) || !bytes.HasPrefix(key, prefix) {
+		return 0, false
+	}
+	enc := key[len(prefix):]
+
+	// 0x14 is zero, 0x14+n a positive integer of n big-endian bytes
+	n := int(enc[0]) - 0x14
+	if n < 0 || n > 8 || len(enc) != 1+n || (n == 8 && enc[1]&0x80 != 0) {
+		return 0, false
+	}
+	var index int64
+	for _, c := range enc[1:] {
+		index = index<<8 | int64(c)
 	}
-	return islice[0].(int64), nil
+	return index, true
 }
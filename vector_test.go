@@ -1,16 +1,22 @@
 package vector
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/FoundationDB/fdb-go/fdb"
 	"github.com/FoundationDB/fdb-go/fdb/directory"
+	"github.com/FoundationDB/fdb-go/fdb/subspace"
+	"github.com/FoundationDB/fdb-go/fdb/tuple"
 )
 
 func TestMain(m *testing.M) {
-	fdb.MustAPIVersion(200)
+	fdb.MustAPIVersion(300)
 	os.Exit(m.Run())
 }
 
@@ -358,3 +364,3592 @@ func TestKeyAtIndexAt(t *testing.T) {
 		t.Error(e)
 	}
 }
+
+func TestDense(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "").WithOptions(Options{Dense: true})
+		vector.Clear(tr)
+
+		if err := vector.Set(0, "a", tr); err != nil {
+			return nil, fmt.Errorf("Set at size returned error: %s", err)
+		}
+		if err := vector.Set(0, "b", tr); err != nil {
+			return nil, fmt.Errorf("Set overwrite returned error: %s", err)
+		}
+
+		err := vector.Set(5, "c", tr)
+		if !errors.Is(err, ErrSparseWrite) {
+			return nil, fmt.Errorf("Expected ErrSparseWrite, got %v", err)
+		}
+
+		i, err := vector.Size(tr)
+		if err != nil {
+			return nil, fmt.Errorf("Size returned error: %s", err)
+		}
+		if i != 1 {
+			return nil, fmt.Errorf("Expected vector to be size 1, got %d instead", i)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestNegativeIndexes(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "").WithOptions(Options{NegativeIndexes: true})
+		vector.Clear(tr)
+
+		vector.Push("a", tr)
+		vector.Push("b", tr)
+		vector.Push("c", tr)
+
+		val, err := vector.Get(-1, tr)
+		if err != nil {
+			return nil, fmt.Errorf("Get(-1) returned error: %s", err)
+		}
+		if val.String != "c" {
+			return nil, fmt.Errorf("Expected Get(-1) to be 'c', got %s instead", val.String)
+		}
+
+		if err := vector.Set(-3, "z", tr); err != nil {
+			return nil, fmt.Errorf("Set(-3) returned error: %s", err)
+		}
+		val, err = vector.Get(0, tr)
+		if err != nil {
+			return nil, fmt.Errorf("Get returned error: %s", err)
+		}
+		if val.String != "z" {
+			return nil, fmt.Errorf("Expected Set(-3) to write index 0, got %s instead", val.String)
+		}
+
+		if _, err := vector.Get(-4, tr); err == nil {
+			return nil, fmt.Errorf("Expected out of range error for Get(-4)")
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestForeignKeys(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		vector.Set(0, "a", tr)
+		vector.Set(1, "b", tr)
+		tr.Set(subspace.Pack(tuple.Tuple{int64(9), "not an index"}), []byte("x"))
+
+		_, err := vector.Size(tr)
+		var fke *ForeignKeyError
+		if !errors.As(err, &fke) {
+			return nil, fmt.Errorf("Expected ForeignKeyError from Size, got %v", err)
+		}
+
+		skipping := vector.WithOptions(Options{SkipForeignKeys: true})
+		i, err := skipping.Size(tr)
+		if err != nil {
+			return nil, fmt.Errorf("Size returned error: %s", err)
+		}
+		if i != 2 {
+			return nil, fmt.Errorf("Expected vector to be size 2, got %d instead", i)
+		}
+
+		vi, err := skipping.GetRange(VectRange{}, tr)
+		if err != nil {
+			return nil, fmt.Errorf("GetRange returned error: %s", err)
+		}
+		n := 0
+		for vi.Advance() {
+			if _, err := vi.Get(); err != nil {
+				return nil, fmt.Errorf("iterator returned error: %s", err)
+			}
+			n++
+		}
+		if n != 2 {
+			return nil, fmt.Errorf("Expected 2 items, got %d instead", n)
+		}
+
+		vector.Clear(tr)
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestNegativeSet(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		err := vector.Set(-5, "a", tr)
+		if !errors.Is(err, ErrIndexOutOfRange) {
+			return nil, fmt.Errorf("Expected ErrIndexOutOfRange, got %v", err)
+		}
+
+		i, err := vector.Size(tr)
+		if err != nil {
+			return nil, fmt.Errorf("Size returned error: %s", err)
+		}
+		if i != 0 {
+			return nil, fmt.Errorf("Expected empty vector to be size 0, got %d instead", i)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestOrigin(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		val, err := vector.Back(tr)
+		if err != nil {
+			return nil, fmt.Errorf("Back returned error: %s", err)
+		}
+		if val.Origin != OriginMissing {
+			return nil, fmt.Errorf("Expected Back of empty vector to be missing, got %s", val.Origin)
+		}
+
+		vector.Set(0, "", tr)
+		vector.Set(2, "b", tr)
+
+		val, err = vector.Get(0, tr)
+		if err != nil {
+			return nil, fmt.Errorf("Get returned error: %s", err)
+		}
+		if val.Origin != OriginStored {
+			return nil, fmt.Errorf("Expected stored default, got %s", val.Origin)
+		}
+
+		val, err = vector.Get(1, tr)
+		if err != nil {
+			return nil, fmt.Errorf("Get returned error: %s", err)
+		}
+		if val.Origin != OriginSparseDefault {
+			return nil, fmt.Errorf("Expected sparse default, got %s", val.Origin)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestAtomicPush(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "").WithOptions(Options{AtomicPush: true})
+		vector.Clear(tr)
+
+		vector.Push("a", tr)
+		vector.Push("b", tr)
+		vector.Set(3, "d", tr)
+		vector.Push("e", tr)
+
+		i, err := vector.Size(tr)
+		if err != nil {
+			return nil, fmt.Errorf("Size returned error: %s", err)
+		}
+		if i != 5 {
+			return nil, fmt.Errorf("Expected vector to be size 5, got %d instead", i)
+		}
+
+		v, err := vector.Pop(tr)
+		if err != nil {
+			return nil, fmt.Errorf("Pop returned error: %s", err)
+		}
+		if v.String != "e" {
+			return nil, fmt.Errorf("Expected popped value to be 'e', got %s instead", v.String)
+		}
+
+		vector.Push("f", tr)
+		v, err = vector.Get(4, tr)
+		if err != nil {
+			return nil, fmt.Errorf("Get returned error: %s", err)
+		}
+		if v.String != "f" {
+			return nil, fmt.Errorf("Expected push after pop to reuse index 4, got %s instead", v.String)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestGetStoredRange(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		vector.Set(1, "a", tr)
+		vector.Set(4, "b", tr)
+
+		si, err := vector.GetStoredRange(VectRange{}, tr)
+		if err != nil {
+			return nil, fmt.Errorf("GetStoredRange returned error: %s", err)
+		}
+
+		indexes := []int64{}
+		for si.Advance() {
+			if si.Err() != nil {
+				return nil, fmt.Errorf("iterator returned error: %s", si.Err())
+			}
+			packed, _ := ValPack([]string{"a", "b"}[len(indexes)])
+			if !bytes.Equal(si.Raw(), packed) {
+				return nil, fmt.Errorf("Raw bytes differ at index %d", si.Index())
+			}
+			indexes = append(indexes, si.Index())
+		}
+		if len(indexes) != 2 || indexes[0] != 1 || indexes[1] != 4 {
+			return nil, fmt.Errorf("Expected stored indexes [1 4], got %v", indexes)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestIteratorClose(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		for i := 0; i < 5; i++ {
+			vector.Push(i, tr)
+		}
+
+		vi, err := vector.GetRange(VectRange{}, tr)
+		if err != nil {
+			return nil, fmt.Errorf("GetRange returned error: %s", err)
+		}
+		if !vi.Advance() {
+			return nil, fmt.Errorf("Expected at least one item")
+		}
+		vi.Close()
+		if vi.Advance() {
+			return nil, fmt.Errorf("Advance after Close returned true")
+		}
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		vi, err = vector.GetRange(VectRange{}, tr)
+		if err != nil {
+			return nil, fmt.Errorf("GetRange returned error: %s", err)
+		}
+		n := 0
+		for vi.AdvanceContext(ctx) {
+			n++
+			if n == 2 {
+				cancel()
+			}
+		}
+		if n != 2 || !errors.Is(vi.Err(), context.Canceled) {
+			return nil, fmt.Errorf("Expected cancel after 2 items, got %d items and %v", n, vi.Err())
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestFilter(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		for i := 0; i < 10; i++ {
+			vector.Push(i, tr)
+		}
+
+		even := func(index int64, val *Value) bool {
+			return val.IsInt && val.Int%2 == 0
+		}
+		matches, err := vector.Filter(even, VectRange{Start: 2, Stop: 7}, tr)
+		if err != nil {
+			return nil, fmt.Errorf("Filter returned error: %s", err)
+		}
+		if len(matches) != 3 || matches[0].Index != 2 || matches[2].Index != 6 {
+			return nil, fmt.Errorf("Expected indexes 2, 4, 6, got %v", matches)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestCountWhere(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		vector.Set(0, "a", tr)
+		vector.Set(3, "bb", tr)
+		vector.Set(5, "cc", tr)
+
+		long := func(index int64, val *Value) bool {
+			return len(val.String) > 1
+		}
+		n, err := vector.CountWhere(long, VectRange{}, tr)
+		if err != nil {
+			return nil, fmt.Errorf("CountWhere returned error: %s", err)
+		}
+		if n != 2 {
+			return nil, fmt.Errorf("Expected 2 matches, got %d instead", n)
+		}
+
+		n, err = vector.CountWhere(nil, VectRange{}, tr)
+		if err != nil {
+			return nil, fmt.Errorf("CountWhere returned error: %s", err)
+		}
+		if n != 3 {
+			return nil, fmt.Errorf("Expected 3 stored items, got %d instead", n)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestUpdateRange(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		vector.Set(0, 1, tr)
+		vector.Set(2, 3, tr)
+		vector.Set(4, 5, tr)
+
+		double := func(index int64, val *Value) (interface{}, error) {
+			return val.Int * 2, nil
+		}
+		if err := vector.UpdateRange(0, 3, double, tr); err != nil {
+			return nil, fmt.Errorf("UpdateRange returned error: %s", err)
+		}
+
+		val, _ := vector.Get(2, tr)
+		if val.Int != 6 {
+			return nil, fmt.Errorf("Expected index 2 to be 6, got %d instead", val.Int)
+		}
+		val, _ = vector.Get(1, tr)
+		if val.Origin != OriginSparseDefault {
+			return nil, fmt.Errorf("Expected index 1 to stay sparse, got %s", val.Origin)
+		}
+		val, _ = vector.Get(4, tr)
+		if val.Int != 5 {
+			return nil, fmt.Errorf("Expected index 4 outside the span to stay 5, got %d", val.Int)
+		}
+
+		if err := vector.UpdateRangeFill(0, 5, double, tr); err != nil {
+			return nil, fmt.Errorf("UpdateRangeFill returned error: %s", err)
+		}
+		val, _ = vector.Get(3, tr)
+		if val.Origin != OriginStored || val.Int != 0 {
+			return nil, fmt.Errorf("Expected gap at index 3 to be filled with 0, got %v", val)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestAdd(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		vector.Set(0, Counter(10), tr)
+		if err := vector.Add(0, -3, tr); err != nil {
+			return nil, fmt.Errorf("Add returned error: %s", err)
+		}
+		if err := vector.Add(1, 5, tr); err != nil {
+			return nil, fmt.Errorf("Add returned error: %s", err)
+		}
+
+		val, err := vector.Get(0, tr)
+		if err != nil {
+			return nil, fmt.Errorf("Get returned error: %s", err)
+		}
+		if !val.IsInt || val.Int != 7 {
+			return nil, fmt.Errorf("Expected 7, got %v", val)
+		}
+
+		val, err = vector.Get(1, tr)
+		if err != nil {
+			return nil, fmt.Errorf("Get returned error: %s", err)
+		}
+		if !val.IsInt || val.Int != 5 {
+			return nil, fmt.Errorf("Expected Add on missing item to give 5, got %v", val)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestAtomicMinMax(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		vector.AtomicMax(0, -5, tr)
+		vector.AtomicMax(0, 3, tr)
+		vector.AtomicMax(0, -10, tr)
+
+		vector.Set(1, Ordered(0), tr)
+		vector.AtomicMin(1, -7, tr)
+		vector.AtomicMin(1, 2, tr)
+
+		val, err := vector.Get(0, tr)
+		if err != nil {
+			return nil, fmt.Errorf("Get returned error: %s", err)
+		}
+		if val.Int != 3 {
+			return nil, fmt.Errorf("Expected max 3, got %d instead", val.Int)
+		}
+
+		val, err = vector.Get(1, tr)
+		if err != nil {
+			return nil, fmt.Errorf("Get returned error: %s", err)
+		}
+		if val.Int != -7 {
+			return nil, fmt.Errorf("Expected min -7, got %d instead", val.Int)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestBitOps(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		vector.BitOr(0, 0x0f, tr)
+		vector.BitAnd(0, 0x3c, tr)
+		vector.BitXor(0, 0x01, tr)
+
+		val, err := vector.Get(0, tr)
+		if err != nil {
+			return nil, fmt.Errorf("Get returned error: %s", err)
+		}
+		if !val.IsInt || val.Int != 0x0d {
+			return nil, fmt.Errorf("Expected 0x0d, got %v", val)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestAtomicTimestamps(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "").WithOptions(Options{Timestamps: true})
+		vector.Clear(tr)
+		vector.Set(0, Counter(1), tr)
+
+		for name, op := range map[string]func() error{
+			"Add":       func() error { return vector.Add(0, 1, tr) },
+			"AtomicMin": func() error { return vector.AtomicMin(0, 1, tr) },
+			"AtomicMax": func() error { return vector.AtomicMax(0, 1, tr) },
+			"BitOr":     func() error { return vector.BitOr(0, 1, tr) },
+			"BitAnd":    func() error { return vector.BitAnd(0, 1, tr) },
+			"BitXor":    func() error { return vector.BitXor(0, 1, tr) },
+		} {
+			if err := op(); !errors.Is(err, ErrUnsupported) {
+				return nil, fmt.Errorf("Expected %s to fail with ErrUnsupported, got %v", name, err)
+			}
+		}
+
+		val, err := vector.Get(0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.Int != 1 {
+			return nil, fmt.Errorf("Expected the counter to be left at 1, got %v", val)
+		}
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+// APPEND_IF_FITS done by hand, for bindings that do not expose it.
+type testAppender struct {
+	tr fdb.Transaction
+}
+
+func (a testAppender) AppendIfFits(key fdb.KeyConvertible, param []byte) {
+	old := a.tr.Get(key).MustGet()
+	a.tr.Set(key, append(old, param...))
+}
+
+func TestAppendString(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+		vector.Set(0, "log:", tr)
+
+		// through the bindings, when they have the mutation
+		err := vector.AppendString(0, "a", tr)
+		want := "log:a"
+		if errors.Is(err, ErrUnsupported) {
+			want = "log:"
+		} else if err != nil {
+			return nil, err
+		}
+		val, err := vector.Get(0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.String != want {
+			return nil, fmt.Errorf("Expected %q, got %v", want, val)
+		}
+
+		// the mutation applied to the key AppendString computes
+		vector.Set(0, "log:", tr)
+		if err := vector.appendString(0, "b", tr, testAppender{tr}); err != nil {
+			return nil, err
+		}
+		if val, err = vector.Get(0, tr); err != nil {
+			return nil, err
+		}
+		if val.String != "log:b" {
+			return nil, fmt.Errorf("Expected \"log:b\", got %v", val)
+		}
+
+		// the fallback when the mutation is missing leaves the item alone
+		if err := vector.appendString(0, "c", tr, struct{}{}); !errors.Is(err, ErrUnsupported) {
+			return nil, fmt.Errorf("Expected ErrUnsupported, got %v", err)
+		}
+		if val, err = vector.Get(0, tr); err != nil {
+			return nil, err
+		}
+		if val.String != "log:b" {
+			return nil, fmt.Errorf("Expected \"log:b\" to be left alone, got %v", val)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestFreeze(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	vector := NewVector(subspace, "")
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		vector.Push("a", tr)
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	ro := vector.Freeze()
+	_, e = db.ReadTransact(func(rtr fdb.ReadTransaction) (interface{}, error) {
+		i, err := ro.Size(rtr)
+		if err != nil {
+			return nil, fmt.Errorf("Size returned error: %s", err)
+		}
+		if i != 1 {
+			return nil, fmt.Errorf("Expected vector to be size 1, got %d instead", i)
+		}
+		val, err := ro.Back(rtr.Snapshot())
+		if err != nil {
+			return nil, fmt.Errorf("Back returned error: %s", err)
+		}
+		if val.String != "a" {
+			return nil, fmt.Errorf("Expected 'a', got %s instead", val.String)
+		}
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestTags(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "").WithOptions(Options{Tags: true})
+		vector.Clear(tr)
+
+		vector.SetWithTags(0, "job0", []string{"failed"}, tr)
+		vector.SetWithTags(1, "job1", nil, tr)
+		vector.SetWithTags(2, "job2", []string{"failed", "retry"}, tr)
+
+		failed, err := vector.GetByTag("failed", tr)
+		if err != nil {
+			return nil, fmt.Errorf("GetByTag returned error: %s", err)
+		}
+		if len(failed) != 2 || failed[1].Value.String != "job2" {
+			return nil, fmt.Errorf("Expected jobs 0 and 2 to be failed, got %v", failed)
+		}
+
+		vector.Pop(tr)
+		failed, err = vector.GetByTag("failed", tr)
+		if err != nil {
+			return nil, fmt.Errorf("GetByTag returned error: %s", err)
+		}
+		if len(failed) != 1 {
+			return nil, fmt.Errorf("Expected Pop to drop the tags of job2, got %v", failed)
+		}
+
+		vector.Tag(7, "failed", tr)
+		failed, err = vector.GetByTag("failed", tr)
+		if err != nil {
+			return nil, fmt.Errorf("GetByTag returned error: %s", err)
+		}
+		if len(failed) != 1 || failed[0].Value.String != "job0" {
+			return nil, fmt.Errorf("Expected the tag past the end to be skipped, got %v", failed)
+		}
+
+		// foreign keys under the tag subspaces
+		tr.Set(vector.subspace.Pack(tuple.Tuple{"tag", "failed", "junk"}), []byte{})
+		tr.Set(vector.subspace.Pack(tuple.Tuple{"tags", int64(0), int64(5)}), []byte{})
+		if _, err := vector.GetByTag("failed", tr); !errors.Is(err, ErrForeignKey) {
+			return nil, fmt.Errorf("Expected ErrForeignKey from GetByTag, got %v", err)
+		}
+		if _, err := vector.Tags(0, tr); !errors.Is(err, ErrForeignKey) {
+			return nil, fmt.Errorf("Expected ErrForeignKey from Tags, got %v", err)
+		}
+		skipping := vector.WithOptions(Options{Tags: true, SkipForeignKeys: true})
+		if failed, err = skipping.GetByTag("failed", tr); err != nil || len(failed) != 1 {
+			return nil, fmt.Errorf("Expected the foreign key to be skipped, got %v (%v)", failed, err)
+		}
+		if tags, err := skipping.Tags(0, tr); err != nil || len(tags) != 1 || tags[0] != "failed" {
+			return nil, fmt.Errorf("Expected the foreign key to be skipped, got %v (%v)", tags, err)
+		}
+
+		if _, err := NewVector(subspace, "").GetByTag("failed", tr); !errors.Is(err, ErrNotEnabled) {
+			return nil, fmt.Errorf("Expected ErrNotEnabled, got %v", err)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestNewVectorWithPrefix(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVectorWithPrefix([]byte("tests/prefix/"), "")
+		vector.Clear(tr)
+
+		vector.Push("a", tr)
+		vector.Set(3, "d", tr)
+
+		i, err := vector.Size(tr)
+		if err != nil {
+			return nil, fmt.Errorf("Size returned error: %s", err)
+		}
+		if i != 4 {
+			return nil, fmt.Errorf("Expected vector to be size 4, got %d instead", i)
+		}
+
+		if !bytes.HasPrefix(vector.keyAt(3), []byte("tests/prefix/")) {
+			return nil, fmt.Errorf("Expected keys under the prefix, got %q", vector.keyAt(3))
+		}
+
+		vector.Clear(tr)
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestFixedKeys(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		tr.ClearRange(subspace.FromBytes([]byte("tests/fixed/")))
+		vector := NewVectorWithPrefix([]byte("tests/fixed/"), "").WithOptions(Options{FixedKeys: true})
+		if err := vector.Init(tr); err != nil {
+			return nil, fmt.Errorf("Init returned error: %s", err)
+		}
+
+		vector.Push("a", tr)
+		vector.Set(300, "b", tr)
+
+		if len(vector.keyAt(300)) != len("tests/fixed/")+9 {
+			return nil, fmt.Errorf("Expected fixed-width key, got %q", vector.keyAt(300))
+		}
+
+		i, err := vector.Size(tr)
+		if err != nil {
+			return nil, fmt.Errorf("Size returned error: %s", err)
+		}
+		if i != 301 {
+			return nil, fmt.Errorf("Expected vector to be size 301, got %d instead", i)
+		}
+
+		tupleKeys := NewVectorWithPrefix([]byte("tests/fixed/"), "")
+		if err := tupleKeys.Init(tr); !errors.Is(err, ErrMetadataMismatch) {
+			return nil, fmt.Errorf("Expected ErrMetadataMismatch, got %v", err)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestNewVectorAt(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		root := tuple.Tuple{"tests", "tenant-1", "list"}
+		tr.ClearRange(subspace.FromBytes(root.Pack()))
+		vector := NewVectorAt(root, nil, "")
+		if err := vector.Init(tr); err != nil {
+			return nil, fmt.Errorf("Init returned error: %s", err)
+		}
+
+		vector.Push("a", tr)
+		vector.Push("b", tr)
+
+		if want := append(root.Pack(), tuple.Tuple{int64(1)}.Pack()...); !bytes.Equal(vector.keyAt(1), want) {
+			return nil, fmt.Errorf("Expected key %q, got %q", want, vector.keyAt(1))
+		}
+		v, err := vector.Get(1, tr)
+		if err != nil || v.String != "b" {
+			return nil, fmt.Errorf("Expected 'b', got %v (%v)", v, err)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestStats(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		vector.Set(2, "ab", tr)
+		vector.Set(7, "c", tr)
+
+		st, err := vector.Stats(tr)
+		if err != nil {
+			return nil, fmt.Errorf("Stats returned error: %s", err)
+		}
+		if st.Keys != 2 || st.MinIndex != 2 || st.MaxIndex != 7 || st.Size != 8 {
+			return nil, fmt.Errorf("Unexpected stats %+v", st)
+		}
+		if st.ValueBytes != 5 {
+			return nil, fmt.Errorf("Expected 5 value bytes, got %d instead", st.ValueBytes)
+		}
+		if st.Sparsity != 0.75 {
+			return nil, fmt.Errorf("Expected sparsity 0.75, got %f instead", st.Sparsity)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+// A ReadTransaction exposing only the methods of the interface, hiding
+// any optional ones of the bindings.
+type plainReadTransaction struct {
+	fdb.ReadTransaction
+}
+
+func TestSplitPoints(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		points, err := vector.SplitPoints(tr, 1<<20)
+		if err != nil {
+			return nil, err
+		}
+		if len(points) != 1 || points[0] != 0 {
+			return nil, fmt.Errorf("Expected [0] for an empty vector, got %v", points)
+		}
+
+		for i := 0; i < 10; i++ {
+			vector.Push(i, tr)
+		}
+		for _, chunkBytes := range []int64{0, -1} {
+			if _, err := vector.SplitPoints(tr, chunkBytes); err == nil {
+				return nil, fmt.Errorf("Expected chunkBytes %d to be rejected", chunkBytes)
+			}
+		}
+
+		plain := plainReadTransaction{tr}
+		if _, err := vector.EstimatedSizeBytes(plain); !errors.Is(err, ErrUnsupported) {
+			return nil, fmt.Errorf("Expected ErrUnsupported from EstimatedSizeBytes, got %v", err)
+		}
+		if _, err := vector.SplitPoints(plain, 1<<20); !errors.Is(err, ErrUnsupported) {
+			return nil, fmt.Errorf("Expected ErrUnsupported from SplitPoints, got %v", err)
+		}
+
+		points, err = vector.SplitPoints(tr, 1<<20)
+		if errors.Is(err, ErrUnsupported) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if points[0] != 0 || points[len(points)-1] != 10 {
+			return nil, fmt.Errorf("Expected split points from 0 to 10, got %v", points)
+		}
+		for i := 1; i < len(points); i++ {
+			if points[i] <= points[i-1] {
+				return nil, fmt.Errorf("Expected increasing split points, got %v", points)
+			}
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestLocality(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		for i := 0; i < 10; i++ {
+			vector.Push(i, tr)
+		}
+
+		shards, err := vector.Locality(tr)
+		if err != nil {
+			return nil, fmt.Errorf("Locality returned error: %s", err)
+		}
+		if len(shards) == 0 || shards[0].Start != 0 || shards[len(shards)-1].Stop != 10 {
+			return nil, fmt.Errorf("Expected shards covering [0, 10), got %v", shards)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestCachedVector(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	vector := Vector{subspace: subspace}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		cv := vector.Cached(tr)
+		for _, v := range []string{"a", "b", "c"} {
+			if err := cv.Push(v); err != nil {
+				return nil, err
+			}
+		}
+		if val, err := cv.Get(1); err != nil || val.String != "b" {
+			return nil, fmt.Errorf("Expected 'b', got %v (%v) instead", val, err)
+		}
+
+		// bypassing the cache is not seen
+		vector.Set(1, "x", tr)
+		if val, err := cv.Get(1); err != nil || val.String != "b" {
+			return nil, fmt.Errorf("Expected cached 'b', got %v (%v) instead", val, err)
+		}
+
+		if err := cv.Set(1, "y"); err != nil {
+			return nil, err
+		}
+		if val, err := cv.Get(1); err != nil || val.String != "y" {
+			return nil, fmt.Errorf("Expected 'y', got %v (%v) instead", val, err)
+		}
+
+		if _, err := cv.Pop(); err != nil {
+			return nil, err
+		}
+		if size, err := cv.Size(); err != nil || size != 2 {
+			return nil, fmt.Errorf("Expected size 2, got %d (%v) instead", size, err)
+		}
+
+		for _, v := range []string{"d", "e"} {
+			if err := cv.PushDeferred(v); err != nil {
+				return nil, err
+			}
+		}
+		if size, err := cv.Size(); err != nil || size != 4 {
+			return nil, fmt.Errorf("Expected size 4 with deferred pushes, got %d (%v) instead", size, err)
+		}
+		if val, err := cv.Get(3); err != nil || val.String != "e" {
+			return nil, fmt.Errorf("Expected deferred 'e', got %v (%v) instead", val, err)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestTryLock(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	vector := Vector{subspace: subspace}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.ClearRange(subspace.Sub("lock"))
+		if ok, err := vector.TryLock(3, "w1", time.Minute, tr); err != nil || !ok {
+			return nil, fmt.Errorf("Expected w1 to get the lock, got %v (%v)", ok, err)
+		}
+		if ok, err := vector.TryLock(3, "w2", time.Minute, tr); err != nil || ok {
+			return nil, fmt.Errorf("Expected w2 not to get the lock, got %v (%v)", ok, err)
+		}
+		if err := vector.Unlock(3, "w2", tr); !errors.Is(err, ErrNotLockOwner) {
+			return nil, fmt.Errorf("Expected ErrNotLockOwner, got %v instead", err)
+		}
+		if err := vector.Unlock(3, "w1", tr); err != nil {
+			return nil, err
+		}
+		if owner, _, err := vector.LockedBy(3, tr); err != nil || owner != "" {
+			return nil, fmt.Errorf("Expected no owner, got %q (%v) instead", owner, err)
+		}
+
+		// expired leases are free
+		if ok, err := vector.TryLock(4, "w1", -time.Second, tr); err != nil || !ok {
+			return nil, fmt.Errorf("Expected w1 to get the lock, got %v (%v)", ok, err)
+		}
+		if ok, err := vector.TryLock(4, "w2", time.Minute, tr); err != nil || !ok {
+			return nil, fmt.Errorf("Expected w2 to take the expired lock, got %v (%v)", ok, err)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestClaim(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	vector := Vector{subspace: subspace}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		tr.ClearRange(subspace.Sub("lock"))
+		for _, v := range []string{"a", "b", "c"} {
+			vector.Push(v, tr)
+		}
+
+		first, err := vector.Claim("w1", 2, time.Minute, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(first) != 2 || first[0].Value.String != "a" || first[1].Value.String != "b" {
+			return nil, fmt.Errorf("Expected w1 to claim 'a' and 'b', got %v instead", first)
+		}
+		second, err := vector.Claim("w2", 2, time.Minute, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(second) != 1 || second[0].Value.String != "c" {
+			return nil, fmt.Errorf("Expected w2 to claim 'c', got %v instead", second)
+		}
+
+		if err := vector.Ack(0, "w2", tr); !errors.Is(err, ErrNotLockOwner) {
+			return nil, fmt.Errorf("Expected ErrNotLockOwner, got %v instead", err)
+		}
+		if err := vector.Ack(0, "w1", tr); err != nil {
+			return nil, err
+		}
+		if val, err := vector.Get(0, tr); err != nil || val.Origin != OriginSparseDefault {
+			return nil, fmt.Errorf("Expected acked item to be removed, got %v (%v)", val, err)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestDeadLetter(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	vector := NewVector(subspace, "").WithOptions(Options{MaxClaims: 2})
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		tr.ClearRange(subspace.Sub("dead"))
+		vector.Push("poison", tr)
+
+		for i := 0; i < 2; i++ {
+			claimed, err := vector.Claim("w1", 1, time.Minute, tr)
+			if err != nil {
+				return nil, err
+			}
+			if len(claimed) != 1 {
+				return nil, fmt.Errorf("Expected claim %d to succeed, got %v instead", i, claimed)
+			}
+			if err := vector.Fail(0, "w1", "boom", tr); err != nil {
+				return nil, err
+			}
+		}
+
+		claimed, err := vector.Claim("w1", 1, time.Minute, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(claimed) != 0 {
+			return nil, fmt.Errorf("Expected item to be dead-lettered, got %v instead", claimed)
+		}
+
+		letters, err := vector.DeadLetters(tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(letters) != 1 || letters[0].Value.String != "poison" || letters[0].Attempts != 2 || letters[0].Reason != "boom" {
+			return nil, fmt.Errorf("Unexpected dead letters %+v", letters)
+		}
+
+		if err := vector.Requeue(0, tr); err != nil {
+			return nil, err
+		}
+		claimed, err = vector.Claim("w2", 1, time.Minute, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(claimed) != 1 || claimed[0].Value.String != "poison" {
+			return nil, fmt.Errorf("Expected requeued item to be claimable, got %v instead", claimed)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestRequeueTimestamps(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	vector := NewVector(subspace, "").WithOptions(Options{MaxClaims: 1, Timestamps: true})
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		tr.ClearRange(subspace.Sub("dead"))
+		vector.Push("poison", tr)
+
+		if _, err := vector.Claim("w1", 1, time.Minute, tr); err != nil {
+			return nil, err
+		}
+		if err := vector.Fail(0, "w1", "boom", tr); err != nil {
+			return nil, err
+		}
+		if _, err := vector.Claim("w1", 1, time.Minute, tr); err != nil {
+			return nil, err
+		}
+		if err := vector.Requeue(0, tr); err != nil {
+			return nil, err
+		}
+
+		raw, err := tr.Get(vector.keyAt(0)).Get()
+		if err != nil {
+			return nil, err
+		}
+		if len(raw) < 10 || raw[0] != 0x05 || raw[9] != 0x03 {
+			return nil, fmt.Errorf("Expected a singly stamped string after Requeue, got % x", raw)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestCursor(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	vector := Vector{subspace: subspace}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		for _, v := range []string{"a", "b", "c"} {
+			vector.Push(v, tr)
+		}
+		fast, slow := vector.Cursor("fast"), vector.Cursor("slow")
+		fast.Reset(tr)
+		slow.Reset(tr)
+
+		items, err := fast.ReadNext(2, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) != 2 || items[1].Value.String != "b" {
+			return nil, fmt.Errorf("Expected 'a' and 'b', got %v instead", items)
+		}
+		fast.Commit(items[1].Index, tr)
+
+		items, err = fast.ReadNext(2, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) != 1 || items[0].Value.String != "c" {
+			return nil, fmt.Errorf("Expected 'c', got %v instead", items)
+		}
+
+		items, err = slow.ReadNext(1, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) != 1 || items[0].Value.String != "a" {
+			return nil, fmt.Errorf("Expected slow cursor to start at 'a', got %v instead", items)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestPopMany(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	vector := Vector{subspace: subspace, defaultValue: "d"}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		vector.Push("a", tr)
+		vector.Push("b", tr)
+		vector.Set(4, "e", tr)
+
+		vals, err := vector.PopMany(3, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) != 3 || vals[0].String != "e" || vals[1].String != "d" || vals[2].String != "d" {
+			return nil, fmt.Errorf("Expected e, d, d, got %v instead", vals)
+		}
+		size, err := vector.Size(tr)
+		if err != nil {
+			return nil, err
+		}
+		if size != 2 {
+			return nil, fmt.Errorf("Expected size 2, got %d instead", size)
+		}
+
+		vals, err = vector.PopMany(5, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) != 2 || vals[0].String != "b" || vals[1].String != "a" {
+			return nil, fmt.Errorf("Expected b, a, got %v instead", vals)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestPeek(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	vector := Vector{subspace: subspace}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		for _, v := range []string{"a", "b", "c"} {
+			vector.Push(v, tr)
+		}
+
+		last, err := vector.Peek(2, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(last) != 2 || last[0].Value.String != "c" || last[1].Value.String != "b" {
+			return nil, fmt.Errorf("Expected c, b, got %v instead", last)
+		}
+		first, err := vector.Freeze().PeekFront(2, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(first) != 2 || first[0].Value.String != "a" || first[1].Value.String != "b" {
+			return nil, fmt.Errorf("Expected a, b, got %v instead", first)
+		}
+
+		size, err := vector.Size(tr)
+		if err != nil {
+			return nil, err
+		}
+		if size != 3 {
+			return nil, fmt.Errorf("Expected Peek to leave size 3, got %d instead", size)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestDrain(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	vector := Vector{subspace: subspace}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		for _, v := range []string{"a", "b", "c"} {
+			vector.Push(v, tr)
+		}
+
+		items, err := vector.Drain(tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) != 3 || items[0].Value.String != "a" || items[2].Value.String != "c" {
+			return nil, fmt.Errorf("Expected a, b, c, got %v instead", items)
+		}
+		size, err := vector.Size(tr)
+		if err != nil {
+			return nil, err
+		}
+		if size != 0 {
+			return nil, fmt.Errorf("Expected drained vector to be empty, got size %d", size)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestZip(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	a, err := Open(db, []string{"tests", "zip", "a"}, "")
+	if err != nil {
+		panic(err)
+	}
+	b, err := Open(db, []string{"tests", "zip", "b"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		a.Clear(tr)
+		b.Clear(tr)
+		a.Push("a0", tr)
+		a.Set(2, "a2", tr)
+		b.Push("b0", tr)
+
+		z, err := Zip(a, b, VectRange{}, tr)
+		if err != nil {
+			return nil, err
+		}
+		var pairs []ZipPair
+		for z.Advance() {
+			pairs = append(pairs, z.Get())
+		}
+		if err := z.Err(); err != nil {
+			return nil, err
+		}
+
+		if len(pairs) != 3 {
+			return nil, fmt.Errorf("Expected 3 pairs, got %d instead", len(pairs))
+		}
+		if pairs[0].A.String != "a0" || pairs[0].B.String != "b0" {
+			return nil, fmt.Errorf("Unexpected first pair %+v", pairs[0])
+		}
+		if pairs[1].A.Origin != OriginSparseDefault || pairs[1].B.Origin != OriginMissing {
+			return nil, fmt.Errorf("Unexpected second pair %+v", pairs[1])
+		}
+		if pairs[2].A.String != "a2" {
+			return nil, fmt.Errorf("Unexpected third pair %+v", pairs[2])
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestReadPlan(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	a, err := Open(db, []string{"tests", "zip", "a"}, "")
+	if err != nil {
+		panic(err)
+	}
+	b, err := Open(db, []string{"tests", "zip", "b"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		a.Clear(tr)
+		b.Clear(tr)
+		a.Push("a0", tr)
+		a.Set(2, "a2", tr)
+		b.Push("b0", tr)
+
+		p := NewReadPlan(tr)
+		multi := p.MultiGet(a, []int64{2, 0})
+		zip := p.Zip(a, b, 0, 10)
+		slice := p.Range(a, 1, 3)
+		if n := p.Reads(a); n != 1 {
+			return nil, fmt.Errorf("Expected the reads of a coalesced into one, got %d", n)
+		}
+		if err := p.Execute(); err != nil {
+			return nil, err
+		}
+
+		vals, err := multi.Values()
+		if err != nil {
+			return nil, err
+		}
+		if vals[0].String != "a2" || vals[1].String != "a0" {
+			return nil, fmt.Errorf("Unexpected MultiGet values %v", vals)
+		}
+
+		z, err := zip.Zipper()
+		if err != nil {
+			return nil, err
+		}
+		var pairs []ZipPair
+		for z.Advance() {
+			pairs = append(pairs, z.Get())
+		}
+		if err := z.Err(); err != nil {
+			return nil, err
+		}
+		if len(pairs) != 3 || pairs[0].B.String != "b0" || pairs[1].B.Origin != OriginMissing || pairs[2].A.String != "a2" {
+			return nil, fmt.Errorf("Unexpected pairs %+v", pairs)
+		}
+
+		vals, err = slice.Values()
+		if err != nil {
+			return nil, err
+		}
+		if len(vals) != 2 || vals[0].Origin != OriginSparseDefault || vals[1].String != "a2" {
+			return nil, fmt.Errorf("Unexpected range values %v", vals)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestZipWith(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	a, err := Open(db, []string{"tests", "zip", "a"}, "")
+	if err != nil {
+		panic(err)
+	}
+	b, err := Open(db, []string{"tests", "zip", "b"}, "")
+	if err != nil {
+		panic(err)
+	}
+	sum, err := Open(db, []string{"tests", "zip", "sum"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		a.Clear(tr)
+		b.Clear(tr)
+		sum.Clear(tr)
+		for i := 0; i < 3; i++ {
+			a.Push(i, tr)
+			b.Push(10*i, tr)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	err = ZipWith(db, a, b, sum, func(x, y *Value) (interface{}, error) {
+		return x.Int + y.Int, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		val, err := sum.Get(2, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.Int != 22 {
+			return nil, fmt.Errorf("Expected 22, got %d instead", val.Int)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestDiff(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	a, err := Open(db, []string{"tests", "diff", "a"}, "")
+	if err != nil {
+		panic(err)
+	}
+	b, err := Open(db, []string{"tests", "diff", "b"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		a.Clear(tr)
+		b.Clear(tr)
+		for _, v := range []string{"x", "y", "z"} {
+			a.Push(v, tr)
+		}
+		b.Push("x", tr)
+		b.Push("Y", tr)
+		b.Set(3, "w", tr)
+
+		diffs, err := Diff(a, b, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(diffs) != 3 {
+			return nil, fmt.Errorf("Expected 3 differences, got %d instead", len(diffs))
+		}
+		if diffs[0].Index != 1 || diffs[0].Old.String != "y" || diffs[0].New.String != "Y" {
+			return nil, fmt.Errorf("Unexpected difference %+v", diffs[0])
+		}
+		if diffs[1].Index != 2 || diffs[1].New != nil {
+			return nil, fmt.Errorf("Expected removal at 2, got %+v", diffs[1])
+		}
+		if diffs[2].Index != 3 || diffs[2].Old != nil || diffs[2].New.String != "w" {
+			return nil, fmt.Errorf("Expected addition at 3, got %+v", diffs[2])
+		}
+
+		// the same value stamped at different times does not differ
+		a.Clear(tr)
+		b.Clear(tr)
+		packed, _ := ValPack("x")
+		tr.Set(a.keyAt(0), stampValue(packed, time.Unix(1, 0)))
+		tr.Set(b.keyAt(0), stampValue(packed, time.Unix(2, 0)))
+		if diffs, err = Diff(a, b, tr); err != nil {
+			return nil, err
+		}
+		if len(diffs) != 0 {
+			return nil, fmt.Errorf("Expected no differences between stamped copies, got %+v", diffs)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestMerge(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	src, err := Open(db, []string{"tests", "diff", "a"}, "")
+	if err != nil {
+		panic(err)
+	}
+	dest, err := Open(db, []string{"tests", "diff", "b"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	// stamped items merge into a Homogeneous vector stamping its own
+	src = src.WithOptions(Options{Timestamps: true})
+	dest = dest.WithOptions(Options{Timestamps: true, Homogeneous: true})
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		src.Clear(tr)
+		dest.Clear(tr)
+		tr.Clear(dest.metaKey("type"))
+		src.Push(1, tr)
+		src.Push(2, tr)
+		dest.Push(10, tr)
+		dest.Set(3, 30, tr)
+
+		calls := 0
+		err := Merge(src, dest, func(index int64, s, d *Value) interface{} {
+			calls++
+			return s.Int + d.Int
+		}, tr)
+		if err != nil {
+			return nil, err
+		}
+		if calls != 1 {
+			return nil, fmt.Errorf("Expected resolver to be called once, got %d", calls)
+		}
+
+		for index, want := range map[int64]int64{0: 11, 1: 2, 3: 30} {
+			val, err := dest.Get(index, tr)
+			if err != nil {
+				return nil, err
+			}
+			if val.Int != want {
+				return nil, fmt.Errorf("Expected %d at %d, got %d instead", want, index, val.Int)
+			}
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	a, err := Open(db, []string{"tests", "diff", "a"}, "")
+	if err != nil {
+		panic(err)
+	}
+	b, err := Open(db, []string{"tests", "diff", "b"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		a.Clear(tr)
+		b.Clear(tr)
+		a.Push("x", tr)
+		a.Push("y", tr)
+		b.Push("x", tr)
+		b.Set(2, "z", tr)
+
+		diffs, err := Diff(a, b, tr)
+		if err != nil {
+			return nil, err
+		}
+		data, err := Patch(diffs).MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		var patch Patch
+		if err := patch.UnmarshalBinary(data); err != nil {
+			return nil, err
+		}
+
+		if err := a.ApplyPatch(patch, tr); err != nil {
+			return nil, err
+		}
+		rest, err := Diff(a, b, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(rest) != 0 {
+			return nil, fmt.Errorf("Expected no differences after patching, got %+v", rest)
+		}
+
+		if err := a.ApplyPatch(patch, tr); !errors.Is(err, ErrPatchConflict) {
+			return nil, fmt.Errorf("Expected ErrPatchConflict applying twice, got %v", err)
+		}
+
+		// a stamped value applies to a Homogeneous vector stamping its own
+		stamped := b.WithOptions(Options{Timestamps: true})
+		if err := stamped.Set(0, "w", tr); err != nil {
+			return nil, err
+		}
+		diffs, err = Diff(a, stamped, tr)
+		if err != nil {
+			return nil, err
+		}
+		tr.Clear(a.metaKey("type"))
+		strict := a.WithOptions(Options{Timestamps: true, Homogeneous: true})
+		if err := strict.ApplyPatch(Patch(diffs), tr); err != nil {
+			return nil, err
+		}
+		val, err := a.Get(0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.String != "w" || val.Modified.IsZero() {
+			return nil, fmt.Errorf("Expected stamped 'w', got %+v", val)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestHooks(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	var ops []string
+	denied := errors.New("denied")
+	vector := NewVector(subspace, "").WithHooks(Hooks{
+		OnBeforeOp: func(info *OpInfo) error {
+			if info.Op == "Pop" {
+				return denied
+			}
+			return nil
+		},
+		OnAfterOp: func(info *OpInfo) {
+			ops = append(ops, fmt.Sprintf("%s:%d", info.Op, info.Bytes))
+		},
+	})
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		ops = nil
+		vector.Clear(tr)
+		if err := vector.Push("abc", tr); err != nil {
+			return nil, err
+		}
+		if _, err := vector.Get(0, tr); err != nil {
+			return nil, err
+		}
+		if _, err := vector.Pop(tr); err != denied {
+			return nil, fmt.Errorf("Expected Pop to be vetoed, got %v", err)
+		}
+		size, err := vector.Size(tr)
+		if err != nil {
+			return nil, err
+		}
+		if size != 1 {
+			return nil, fmt.Errorf("Expected vetoed Pop to leave size 1, got %d", size)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+	if fmt.Sprint(ops) != "[Push:4 Get:4]" {
+		t.Errorf("Unexpected hook calls %v", ops)
+	}
+}
+
+func TestOpError(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	vector, err := Open(db, []string{"tests", "vector"}, "")
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		vector.Push("a", tr)
+		tr.Set(vector.keyAt(1), []byte{})
+
+		_, err := vector.Get(1, tr)
+		var oe *OpError
+		if !errors.As(err, &oe) {
+			return nil, fmt.Errorf("Expected *OpError, got %v", err)
+		}
+		if oe.Op != "Get" || oe.Index != 1 || len(oe.Path) != 2 {
+			return nil, fmt.Errorf("Unexpected error context %+v", oe)
+		}
+
+		_, err = vector.Get(5, tr)
+		if !errors.Is(err, ErrIndexOutOfRange) {
+			return nil, fmt.Errorf("Expected ErrIndexOutOfRange, got %v", err)
+		}
+
+		vi, err := vector.GetRange(VectRange{Start: 1, Stop: 2}, tr)
+		if err != nil {
+			return nil, err
+		}
+		if !vi.Advance() {
+			return nil, fmt.Errorf("Expected an item at 1")
+		}
+		_, err = vi.Get()
+		if !errors.As(err, &oe) || oe.Op != "GetRange" || oe.Index != 1 {
+			return nil, fmt.Errorf("Expected *OpError of GetRange at 1, got %v", err)
+		}
+		vector.Clear(tr)
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestTupleIndex(t *testing.T) {
+
+	vector := NewVector(subspace.Sub("tests", "tupleindex"), "")
+	for _, index := range []int64{0, 1, 255, 256, 1 << 40, 1<<63 - 1} {
+		got, err := vector.indexAt(vector.keyAt(index))
+		if err != nil || got != index {
+			t.Errorf("Expected %d, got %d (%v)", index, got, err)
+		}
+	}
+	if _, ok := vector.tupleIndex(vector.subspace.Pack(tuple.Tuple{"size"})); ok {
+		t.Error("Expected string key not to decode as an index")
+	}
+}
+
+func TestVectoratorGetInto(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	vector := Vector{subspace: subspace}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		vector.Push("a", tr)
+		vector.Push(2, tr)
+
+		vi, err := vector.GetRange(VectRange{}, tr)
+		if err != nil {
+			return nil, err
+		}
+		var iv IndexValue
+		var got []string
+		var reused *Value
+		for vi.Advance() {
+			if err := vi.GetInto(&iv); err != nil {
+				return nil, err
+			}
+			if reused != nil && iv.Value != reused {
+				return nil, fmt.Errorf("Expected GetInto to reuse the Value")
+			}
+			reused = iv.Value
+			got = append(got, fmt.Sprintf("%d:%s%d", iv.Index, iv.Value.String, iv.Value.Int))
+		}
+		if fmt.Sprint(got) != "[0:a0 1:2]" {
+			return nil, fmt.Errorf("Unexpected items %v", got)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestMultiGet(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		for i := 0; i < 10; i++ {
+			vector.Push(i, tr)
+		}
+
+		want := []int64{8, 2, 3, 4, 9, 0, 3}
+		vals, err := vector.MultiGet(want, tr)
+		if err != nil {
+			return nil, fmt.Errorf("MultiGet returned error: %s", err)
+		}
+		for i, v := range vals {
+			if !v.IsInt || v.Int != want[i] {
+				return nil, fmt.Errorf("Expected %d at position %d, got %v", want[i], i, v)
+			}
+		}
+
+		if _, err := vector.MultiGet([]int64{1, 10}, tr); !errors.Is(err, ErrIndexOutOfRange) {
+			return nil, fmt.Errorf("Expected ErrIndexOutOfRange, got %v", err)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestWriteBatch(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+		vector.Push("a", tr)
+
+		batch := vector.Batch()
+		batch.Push("b")
+		batch.Push("c")
+		batch.Set(0, "z")
+		batch.Push("d")
+		if err := batch.Flush(tr); err != nil {
+			return nil, fmt.Errorf("Flush returned error: %s", err)
+		}
+		if batch.Len() != 0 {
+			return nil, fmt.Errorf("Expected empty batch after Flush, got %d writes", batch.Len())
+		}
+
+		want := []string{"z", "b", "c", "d"}
+		size, _ := vector.Size(tr)
+		if size != int64(len(want)) {
+			return nil, fmt.Errorf("Expected size %d, got %d", len(want), size)
+		}
+		for i, s := range want {
+			v, _ := vector.Get(int64(i), tr)
+			if v.String != s {
+				return nil, fmt.Errorf("Expected %q at %d, got %q", s, i, v.String)
+			}
+		}
+
+		dense := vector.WithOptions(Options{Dense: true})
+		batch = dense.Batch()
+		batch.Set(10, "far")
+		if err := batch.Flush(tr); !errors.Is(err, ErrSparseWrite) {
+			return nil, fmt.Errorf("Expected ErrSparseWrite, got %v", err)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestAsyncReads(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+		for i := 0; i < 5; i++ {
+			vector.Push(i, tr)
+		}
+
+		size := vector.SizeAsync(tr)
+		back := vector.BackAsync(tr)
+		window := vector.GetRangeAsync(VectRange{Start: 1, Stop: 3}, tr)
+		tail := vector.GetRangeAsync(VectRange{Start: -2}, tr)
+
+		if n, err := size.Get(); err != nil || n != 5 {
+			return nil, fmt.Errorf("Expected size 5, got %d (%v)", n, err)
+		}
+		if v, err := back.Get(); err != nil || v.Int != 4 {
+			return nil, fmt.Errorf("Expected back 4, got %v (%v)", v, err)
+		}
+		for _, c := range []struct {
+			f    *RangeFuture
+			want []int64
+		}{{window, []int64{1, 2}}, {tail, []int64{3, 4}}} {
+			vi, err := c.f.Get()
+			if err != nil {
+				return nil, err
+			}
+			var got []int64
+			for vi.Advance() {
+				iv, _ := vi.Get()
+				got = append(got, iv.Value.Int)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(c.want) {
+				return nil, fmt.Errorf("Expected %v, got %v", c.want, got)
+			}
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestApplyOps(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+
+		errs, err := vector.ApplyOps([]Op{
+			FillOp(0, 3, "f"),
+			PushOp(struct{}{}),
+			SetOp(1, "s"),
+			PushOp("p"),
+			ClearOp(2),
+		}, tr)
+		if err != nil {
+			return nil, err
+		}
+		for i, err := range errs {
+			if (err != nil) != (i == 1) {
+				return nil, fmt.Errorf("Unexpected error state for op %d: %v", i, err)
+			}
+		}
+
+		want := []string{"f", "s", "", "p"}
+		for i, s := range want {
+			v, err := vector.Get(int64(i), tr)
+			if err != nil || v.String != s {
+				return nil, fmt.Errorf("Expected %q at %d, got %v (%v)", s, i, v, err)
+			}
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestNamespaceQuota(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	root, err := directory.CreateOrOpen(db, []string{"tests", "namespace"}, nil)
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		tr.ClearRange(root)
+		ns := NewNamespace(root, Quota{MaxItems: 3})
+		a := ns.Vector("acme", "a", "")
+		b := ns.Vector("acme", "b", "")
+
+		a.Push("x", tr)
+		a.Push("y", tr)
+		b.Set(0, "z", tr)
+		if err := b.Push("over", tr); !errors.Is(err, ErrQuotaExceeded) {
+			return nil, fmt.Errorf("Expected ErrQuotaExceeded, got %v", err)
+		}
+		if err := b.Set(0, "zz", tr); err != nil {
+			return nil, fmt.Errorf("Expected overwrite within quota, got %v", err)
+		}
+
+		dense := ns.Vector("acme", "dense", "").WithOptions(Options{Dense: true})
+		if err := dense.Set(5, "gap", tr); !errors.Is(err, ErrSparseWrite) {
+			return nil, fmt.Errorf("Expected ErrSparseWrite, got %v", err)
+		}
+
+		used, err := ns.Usage("acme", tr)
+		if err != nil || used.Items != 3 {
+			return nil, fmt.Errorf("Expected 3 items used, got %v (%v)", used, err)
+		}
+
+		if err := a.Clear(tr); err != nil {
+			return nil, err
+		}
+		if _, err := b.Pop(tr); err != nil {
+			return nil, err
+		}
+		used, err = ns.Usage("acme", tr)
+		if err != nil || used.Items != 0 || used.Bytes != 0 {
+			return nil, fmt.Errorf("Expected nothing used, got %v (%v)", used, err)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestAuthorizer(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	denied := errors.New("read only")
+	var seen []string
+	auth := func(op string, path []string, index int64) error {
+		seen = append(seen, fmt.Sprintf("%s %v %d", op, path, index))
+		if op == "Push" || op == "Drain" {
+			return nil
+		}
+		return denied
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		plain := NewVector(subspace, "")
+		plain.Clear(tr)
+		vector := plain.WithAuthorizer(auth)
+
+		if err := vector.Push("a", tr); err != nil {
+			return nil, fmt.Errorf("Expected Push to be allowed, got %v", err)
+		}
+		if err := vector.Set(0, "b", tr); !errors.Is(err, denied) {
+			return nil, fmt.Errorf("Expected Set to be denied, got %v", err)
+		}
+		if err := vector.Add(0, 1, tr); !errors.Is(err, denied) {
+			return nil, fmt.Errorf("Expected Add to be denied, got %v", err)
+		}
+		vector.Clear(tr)
+		if size, _ := plain.Size(tr); size != 1 {
+			return nil, fmt.Errorf("Expected denied Clear to keep the item, got size %d", size)
+		}
+		if err := vector.ClearWithError(tr); !errors.Is(err, denied) {
+			return nil, fmt.Errorf("Expected ClearWithError to be denied, got %v", err)
+		}
+		if _, err := vector.Drain(tr); !errors.Is(err, denied) {
+			return nil, fmt.Errorf("Expected Drain to fail when Clear is denied, got %v", err)
+		}
+		if err := Merge(plain, vector, nil, tr); !errors.Is(err, denied) {
+			return nil, fmt.Errorf("Expected Merge to be denied, got %v", err)
+		}
+		if size, _ := plain.Size(tr); size != 1 {
+			return nil, fmt.Errorf("Expected the item to stay after denied Drain, got size %d", size)
+		}
+		if want := "Push [tests vector] -1"; seen[0] != want {
+			return nil, fmt.Errorf("Expected %q, got %q", want, seen[0])
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestAudit(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+	audit := subspace.Sub("audit-log")
+
+	vector := NewVector(subspace, "").WithOptions(Options{Audit: audit}).WithPrincipal("alice")
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.ClearRange(audit)
+		vector.Clear(tr)
+		vector.Push("a", tr)
+		vector.Set(0, "b", tr)
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		all, err := vector.QueryAudit(AuditQuery{}, tr)
+		if err != nil {
+			return nil, err
+		}
+		var ops []string
+		for _, rec := range all {
+			if rec.Principal != "alice" {
+				return nil, fmt.Errorf("Expected principal alice, got %q", rec.Principal)
+			}
+			ops = append(ops, rec.Op)
+		}
+		if fmt.Sprint(ops) != "[Clear Push Set]" {
+			return nil, fmt.Errorf("Expected Clear, Push, Set, got %v", ops)
+		}
+
+		sets, err := vector.QueryAudit(AuditQuery{Op: "Set", Index: 0, ByIndex: true}, tr)
+		if err != nil || len(sets) != 1 || sets[0].Path[0] != "tests" {
+			return nil, fmt.Errorf("Expected one Set on index 0, got %v (%v)", sets, err)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestOpenDefaultMismatch(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	path := []string{"tests", "default"}
+	if _, err := directory.Root().Remove(db, path); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(db, path, "zero"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(db, path, "zero"); err != nil {
+		t.Errorf("Expected reopening with the same default to work, got %v", err)
+	}
+	if _, err := Open(db, path, "other"); !errors.Is(err, ErrMetadataMismatch) {
+		t.Errorf("Expected ErrMetadataMismatch, got %v", err)
+	}
+}
+
+func TestDefaultFunc(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "").WithDefaultFunc(func(index int64) interface{} {
+			return index * 10
+		})
+		vector.Clear(tr)
+		vector.Set(5, "x", tr)
+
+		v, err := vector.Get(2, tr)
+		if err != nil || !v.IsInt || v.Int != 20 || v.Origin != OriginSparseDefault {
+			return nil, fmt.Errorf("Expected computed default 20, got %v (%v)", v, err)
+		}
+
+		vector.Pop(tr)
+		v, err = vector.Get(4, tr)
+		if err != nil || v.Int != 40 || v.Origin != OriginStored {
+			return nil, fmt.Errorf("Expected stored default 40 after Pop, got %v (%v)", v, err)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestGetOr(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+		vector.Set(0, 1, tr)
+		vector.Set(2, 3, tr)
+
+		if v, err := vector.GetOr(0, -1, tr); err != nil || v.Int != 1 || v.Origin != OriginStored {
+			return nil, fmt.Errorf("Expected stored 1, got %v (%v)", v, err)
+		}
+		if v, err := vector.GetOr(1, -1, tr); err != nil || !v.IsInt || v.Int != -1 || v.Origin != OriginSparseDefault {
+			return nil, fmt.Errorf("Expected fallback -1, got %v (%v)", v, err)
+		}
+		if _, err := vector.GetOr(3, -1, tr); !errors.Is(err, ErrIndexOutOfRange) {
+			return nil, fmt.Errorf("Expected ErrIndexOutOfRange, got %v", err)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestHomogeneous(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		tr.ClearRange(subspace.FromBytes([]byte("tests/homogeneous/")))
+		vector := NewVectorWithPrefix([]byte("tests/homogeneous/"), "").WithOptions(Options{Homogeneous: true})
+
+		if err := vector.Push(1, tr); err != nil {
+			return nil, err
+		}
+		if err := vector.Set(3, int64(4), tr); err != nil {
+			return nil, fmt.Errorf("Expected int write to be accepted, got %v", err)
+		}
+		err := vector.Push("two", tr)
+		var tme *TypeMismatchError
+		if !errors.As(err, &tme) || tme.Want != "int" || tme.Got != "string" || tme.Index != 4 {
+			return nil, fmt.Errorf("Expected int/string TypeMismatchError at 4, got %v", err)
+		}
+		if !errors.Is(err, ErrTypeMismatch) {
+			return nil, fmt.Errorf("Expected ErrTypeMismatch, got %v", err)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestSetRaw(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+		vector.Push(2.5, tr)
+
+		v, err := vector.Get(0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if err := vector.SetRaw(1, v.Raw, tr); err != nil {
+			return nil, err
+		}
+		copied, err := vector.Get(1, tr)
+		if err != nil || !copied.IsFloat || copied.Float != 2.5 || !bytes.Equal(copied.Raw, v.Raw) {
+			return nil, fmt.Errorf("Expected verbatim copy of 2.5, got %v (%v)", copied, err)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestTopK(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+		for _, v := range []interface{}{5, "skip", 9.5, 1, 9, 7, 1} {
+			vector.Push(v, tr)
+		}
+
+		top, err := vector.TopK(3, 0, 0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(top) != 3 || top[0].Index != 2 || top[1].Index != 4 || top[2].Index != 5 {
+			return nil, fmt.Errorf("Expected indexes 2, 4, 5, got %v", top)
+		}
+
+		bottom, err := vector.BottomK(2, 0, 0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(bottom) != 2 || bottom[0].Index != 3 || bottom[1].Index != 6 {
+			return nil, fmt.Errorf("Expected indexes 3, 6, got %v", bottom)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestHistogram(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+		for _, v := range []interface{}{-1, 0, 2.5, "skip", 5, 10, 99} {
+			vector.Push(v, tr)
+		}
+
+		counts, err := vector.Histogram([]float64{0, 5, 10}, VectRange{}, tr)
+		if err != nil {
+			return nil, err
+		}
+		want := []int64{1, 2, 1, 2}
+		if fmt.Sprint(counts) != fmt.Sprint(want) {
+			return nil, fmt.Errorf("Expected %v, got %v", want, counts)
+		}
+
+		if _, err := vector.Histogram([]float64{5, 0}, VectRange{}, tr); err == nil {
+			return nil, fmt.Errorf("Expected error for descending bounds")
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestHeadTailIndexes(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+		vector.Set(1, "a", tr)
+		vector.Set(4, "b", tr)
+		vector.Set(6, "c", tr)
+
+		head, err := vector.HeadIndexes(2, tr)
+		if err != nil {
+			return nil, err
+		}
+		if fmt.Sprint(head) != "[1 4]" {
+			return nil, fmt.Errorf("Expected head indexes [1 4], got %v", head)
+		}
+
+		tail, err := vector.TailIndexes(5, tr)
+		if err != nil {
+			return nil, err
+		}
+		if fmt.Sprint(tail) != "[6 4 1]" {
+			return nil, fmt.Errorf("Expected tail indexes [6 4 1], got %v", tail)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestNotify(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "").WithOptions(Options{Notify: true})
+		vector.Clear(tr)
+		before, err := vector.DirtyCount(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		vector.Push("a", tr)
+		vector.Set(3, "b", tr)
+		if _, err := vector.Pop(tr); err != nil {
+			return nil, err
+		}
+
+		after, err := vector.DirtyCount(tr)
+		if err != nil {
+			return nil, err
+		}
+		if after-before != 3 {
+			return nil, fmt.Errorf("Expected 3 mutations counted, got %d", after-before)
+		}
+
+		if _, err := NewVector(subspace, "").WatchDirty(tr); !errors.Is(err, ErrNotEnabled) {
+			return nil, fmt.Errorf("Expected ErrNotEnabled, got %v", err)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestGetRanges(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+		for i := 0; i < 10; i++ {
+			vector.Push(i, tr)
+		}
+
+		items, err := vector.GetRanges([]VectRange{{Start: 6, Stop: 8}, {Start: 1, Stop: 3}, {Start: 2, Stop: 4}, {Start: -1}}, tr)
+		if err != nil {
+			return nil, err
+		}
+		var got []int64
+		for _, iv := range items {
+			got = append(got, iv.Value.Int)
+		}
+		if fmt.Sprint(got) != "[1 2 3 6 7 9]" {
+			return nil, fmt.Errorf("Expected [1 2 3 6 7 9], got %v", got)
+		}
+
+		if _, err := vector.GetRanges([]VectRange{{Start: 5, Stop: 1, Step: -1}}, tr); err == nil {
+			return nil, fmt.Errorf("Expected error for a descending window")
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestOpenRanges(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+		for i := 0; i < 5; i++ {
+			vector.Push(i, tr)
+		}
+
+		read := func(vro VectRange) (string, error) {
+			vi, err := vector.GetRange(vro, tr)
+			if err != nil {
+				return "", err
+			}
+			var got []int64
+			for vi.Advance() {
+				iv, err := vi.Get()
+				if err != nil {
+					return "", err
+				}
+				got = append(got, iv.Value.Int)
+			}
+			return fmt.Sprint(got), nil
+		}
+
+		for _, c := range []struct {
+			vro  VectRange
+			want string
+		}{
+			{RangeFrom(3), "[3 4]"},
+			{RangeLast(2), "[3 4]"},
+			{RangeLast(9), "[0 1 2 3 4]"},
+			{RangeLast(0), "[]"},
+			{VectRange{Start: 2, Step: -1, Open: true}, "[2 1 0]"},
+			{VectRange{Start: -1, Step: -1, Open: true}, "[4 3 2 1 0]"},
+		} {
+			got, err := read(c.vro)
+			if err != nil {
+				return nil, err
+			}
+			if got != c.want {
+				return nil, fmt.Errorf("Expected %s for %+v, got %s", c.want, c.vro, got)
+			}
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestChunkedVector(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "chunked"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewChunkedVector(subspace, 4)
+		vector.Clear(tr)
+		if err := vector.Init(tr); err != nil {
+			return nil, err
+		}
+		for i := 0; i < 6; i++ {
+			if err := vector.Push(float64(i)/2, tr); err != nil {
+				return nil, err
+			}
+		}
+		if err := vector.Set(9, 4.5, tr); err != nil {
+			return nil, err
+		}
+
+		size, err := vector.Size(tr)
+		if err != nil {
+			return nil, err
+		}
+		if size != 10 {
+			return nil, fmt.Errorf("Expected size 10, got %d", size)
+		}
+
+		val, err := vector.Get(5, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val != 2.5 {
+			return nil, fmt.Errorf("Expected 2.5, got %v", val)
+		}
+
+		vals, err := vector.GetRange(VectRange{Start: 3}, tr)
+		if err != nil {
+			return nil, err
+		}
+		if fmt.Sprint(vals) != "[1.5 2 2.5 0 0 0 4.5]" {
+			return nil, fmt.Errorf("Expected [1.5 2 2.5 0 0 0 4.5], got %v", vals)
+		}
+
+		if err := NewChunkedVector(subspace, 8).Init(tr); !errors.Is(err, ErrMetadataMismatch) {
+			return nil, fmt.Errorf("Expected ErrMetadataMismatch, got %v", err)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestElideDefault(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		sentinel := func(int64) interface{} { return -1 }
+		plain := NewVector(subspace, "").WithDefaultFunc(sentinel)
+		plain.Clear(tr)
+		for _, v := range []int{-1, -1, 7, -1, -1} {
+			plain.Push(v, tr)
+		}
+
+		cleared, err := plain.CompactDefaults(0, 5, tr)
+		if err != nil {
+			return nil, err
+		}
+		if cleared != 3 {
+			return nil, fmt.Errorf("Expected 3 items cleared, got %d", cleared)
+		}
+
+		eliding := plain.WithOptions(Options{ElideDefault: true})
+		eliding.Set(2, -1, tr)
+		eliding.Push(-1, tr)
+
+		st, err := eliding.Stats(tr)
+		if err != nil {
+			return nil, err
+		}
+		if st.Keys != 2 || st.Size != 6 {
+			return nil, fmt.Errorf("Expected 2 keys for size 6, got %d for %d", st.Keys, st.Size)
+		}
+
+		v, err := eliding.Get(2, tr)
+		if err != nil {
+			return nil, err
+		}
+		if !v.IsInt || v.Int != -1 {
+			return nil, fmt.Errorf("Expected -1 for an elided item, got %v", v)
+		}
+
+		// a plain default would read back untyped once elided
+		untyped := NewVector(subspace, "x")
+		untyped.Clear(tr)
+		untyped.Push("x", tr)
+		untyped.Push("y", tr)
+		if _, err := untyped.CompactDefaults(0, 2, tr); !errors.Is(err, ErrNoDefaultFunc) {
+			return nil, fmt.Errorf("Expected ErrNoDefaultFunc from CompactDefaults, got %v", err)
+		}
+		err = untyped.WithOptions(Options{ElideDefault: true}).Set(0, "x", tr)
+		if !errors.Is(err, ErrNoDefaultFunc) {
+			return nil, fmt.Errorf("Expected ErrNoDefaultFunc from Set, got %v", err)
+		}
+		v, err = untyped.Get(0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if v.String != "x" {
+			return nil, fmt.Errorf("Expected 'x' to stay stored, got %v", v)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestDeltaVector(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "delta"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewDeltaVector(subspace, 4)
+		vector.Clear(tr)
+		if err := vector.Init(tr); err != nil {
+			return nil, err
+		}
+		for i := int64(0); i < 6; i++ {
+			if err := vector.Push(1000+i*3, tr); err != nil {
+				return nil, err
+			}
+		}
+		if err := vector.Set(1, 7, tr); err != nil {
+			return nil, err
+		}
+
+		val, err := vector.Get(5, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val != 1015 {
+			return nil, fmt.Errorf("Expected 1015, got %d", val)
+		}
+
+		vals, err := vector.GetRange(VectRange{}, tr)
+		if err != nil {
+			return nil, err
+		}
+		if fmt.Sprint(vals) != "[1000 7 1006 1009 1012 1015]" {
+			return nil, fmt.Errorf("Expected [1000 7 1006 1009 1012 1015], got %v", vals)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestScanNumeric(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+		vector.Set(0, 1, tr)
+		vector.Set(2, Counter(2), tr)
+
+		col, err := vector.ScanNumeric(VectRange{}, tr)
+		if err != nil {
+			return nil, err
+		}
+		if fmt.Sprint(col.Indexes, col.Ints) != "[0 2] [1 2]" || col.Floats != nil {
+			return nil, fmt.Errorf("Expected ints [1 2] at [0 2], got %+v", col)
+		}
+
+		vector.Push(2.5, tr)
+		col, err = vector.ScanNumeric(VectRange{}, tr)
+		if err != nil {
+			return nil, err
+		}
+		if fmt.Sprint(col.Floats) != "[1 2 2.5]" || col.Ints != nil {
+			return nil, fmt.Errorf("Expected floats [1 2 2.5], got %+v", col)
+		}
+
+		vector.Push("x", tr)
+		if _, err := vector.ScanNumeric(VectRange{}, tr); !errors.Is(err, ErrTypeMismatch) {
+			return nil, fmt.Errorf("Expected ErrTypeMismatch, got %v", err)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestAggregates(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "").WithOptions(Options{Aggregates: true})
+		vector.Clear(tr)
+		for _, v := range []interface{}{5, -3, "skip", 2.5, 10} {
+			vector.Push(v, tr)
+		}
+		vector.Set(0, 4, tr)
+		if _, err := vector.Pop(tr); err != nil {
+			return nil, err
+		}
+
+		agg, err := vector.Aggregates(tr)
+		if err != nil {
+			return nil, err
+		}
+		if agg.Sum != 1 || agg.Count != 2 || agg.Min != -3 || agg.Max != 10 {
+			return nil, fmt.Errorf("Expected sum 1, count 2, min -3, max 10, got %+v", agg)
+		}
+
+		if err := vector.RebuildAggregates(tr); err != nil {
+			return nil, err
+		}
+		agg, err = vector.Aggregates(tr)
+		if err != nil {
+			return nil, err
+		}
+		if agg.Sum != 1 || agg.Count != 2 || agg.Min != -3 || agg.Max != 4 {
+			return nil, fmt.Errorf("Expected max 4 after rebuild, got %+v", agg)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestSample(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+		for i := 0; i < 100; i++ {
+			vector.Push(i, tr)
+		}
+
+		items, err := vector.Sample(10, tr)
+		if err != nil {
+			return nil, err
+		}
+		if len(items) != 10 {
+			return nil, fmt.Errorf("Expected 10 samples of a dense vector, got %d", len(items))
+		}
+		for i, iv := range items {
+			if iv.Index/10 != int64(i) || iv.Value.Int != iv.Index {
+				return nil, fmt.Errorf("Expected sample %d in [%d, %d), got index %d", i, i*10, i*10+10, iv.Index)
+			}
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestElementwise(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	dir, err := directory.CreateOrOpen(db, []string{"tests", "elementwise"}, nil)
+	if err != nil {
+		panic(err)
+	}
+	a := NewVector(dir.Sub("a"), "")
+	b := NewVector(dir.Sub("b"), "")
+	dest := NewVector(dir.Sub("dest"), "")
+
+	_, err = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		for _, v := range []*Vector{a, b, dest} {
+			v.Clear(tr)
+		}
+		a.Push(1, tr)
+		a.Set(2, 3, tr)
+		b.Push(10, tr)
+		b.Push(0.5, tr)
+		b.Push(20, tr)
+		b.Push(4, tr)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ElementwiseAdd(db, a, b, dest); err != nil {
+		t.Fatal(err)
+	}
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		var got []interface{}
+		for i := int64(0); i < 4; i++ {
+			v, err := dest.Get(i, tr)
+			if err != nil {
+				return nil, err
+			}
+			if v.IsFloat {
+				got = append(got, v.Float)
+			} else {
+				got = append(got, v.Int)
+			}
+		}
+		if fmt.Sprint(got) != "[11 0.5 23 4]" {
+			return nil, fmt.Errorf("Expected [11 0.5 23 4], got %v", got)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+
+	db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		return nil, a.Set(1, "x", tr)
+	})
+	if err := ElementwiseMul(db, a, b, dest); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Expected ErrTypeMismatch, got %v", err)
+	}
+}
+
+func TestScaleAddScalar(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+	vector := NewVector(subspace, "")
+
+	_, err = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		vector.Push(2, tr)
+		vector.Set(2, 0.5, tr)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vector.Scale(db, 4); err != nil {
+		t.Fatal(err)
+	}
+	if err := vector.AddScalar(db, -1); err != nil {
+		t.Fatal(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		col, err := vector.ScanNumeric(VectRange{}, tr)
+		if err != nil {
+			return nil, err
+		}
+		if fmt.Sprint(col.Indexes, col.Floats) != "[0 2] [7 1]" {
+			return nil, fmt.Errorf("Expected [7 1] at [0 2], got %+v", col)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+
+	// ints and Counters keep their layout while the results are integral
+	dir, err := directory.CreateOrOpen(db, []string{"tests", "numeric"}, nil)
+	if err != nil {
+		panic(err)
+	}
+	ints := NewVector(dir.Sub("ints"), "").WithOptions(Options{Homogeneous: true})
+	counters := NewVector(dir.Sub("counters"), "")
+	_, err = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.ClearRange(dir)
+		for _, v := range []int{1, 2, 3} {
+			if err := ints.Push(v, tr); err != nil {
+				return nil, err
+			}
+		}
+		return nil, counters.Push(Counter(5), tr)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ints.Scale(db, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := counters.AddScalar(db, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := counters.Scale(db, 0.5); !errors.Is(err, ErrTypeMismatch) {
+		t.Errorf("Expected ErrTypeMismatch halving a Counter, got %v", err)
+	}
+
+	_, e = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		val, err := ints.Get(1, tr)
+		if err != nil {
+			return nil, err
+		}
+		if !val.IsInt || val.Int != 4 {
+			return nil, fmt.Errorf("Expected int 4, got %+v", val)
+		}
+		if err := counters.Add(0, 1, tr); err != nil {
+			return nil, err
+		}
+		val, err = counters.Get(0, tr)
+		if err != nil {
+			return nil, err
+		}
+		if val.Int != 7 {
+			return nil, fmt.Errorf("Expected Counter 7, got %+v", val)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestChunkedAddScalar(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "chunked"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+	vector := NewChunkedVector(subspace, 4)
+
+	_, err = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		if err := vector.Set(1, 1, tr); err != nil {
+			return nil, err
+		}
+		return nil, vector.Set(9, 2, tr)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vector.AddScalar(db, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vals, err := vector.GetRange(VectRange{}, tr)
+		if err != nil {
+			return nil, err
+		}
+		// padding, the short chunk and the missing chunk alike
+		if fmt.Sprint(vals) != "[1 2 1 1 1 1 1 1 1 3]" {
+			return nil, fmt.Errorf("Expected [1 2 1 1 1 1 1 1 1 3], got %v", vals)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestDot(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	dir, err := directory.CreateOrOpen(db, []string{"tests", "elementwise"}, nil)
+	if err != nil {
+		panic(err)
+	}
+	a := NewVector(dir.Sub("a"), "")
+	b := NewVector(dir.Sub("b"), "")
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		a.Clear(tr)
+		b.Clear(tr)
+		a.Push(1, tr)
+		a.Set(2, 3, tr)
+		for _, v := range []interface{}{2, 5, 0.5, 9} {
+			b.Push(v, tr)
+		}
+
+		dot, err := Dot(a, b, tr)
+		if err != nil {
+			return nil, err
+		}
+		if dot != 3.5 {
+			return nil, fmt.Errorf("Expected 3.5, got %v", dot)
+		}
+
+		dot, err = DotRange(a, b, VectRange{Start: 1, Stop: 3}, tr)
+		if err != nil {
+			return nil, err
+		}
+		if dot != 1.5 {
+			return nil, fmt.Errorf("Expected 1.5 over [1, 3), got %v", dot)
+		}
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestNormalize(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+	vector := NewVector(subspace, "")
+
+	_, err = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		vector.Clear(tr)
+		vector.Push(3, tr)
+		vector.Set(2, 4.0, tr)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vector.NormalizeInPlace(db); err != nil {
+		t.Fatal(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		norm, err := vector.L2Norm(tr)
+		if err != nil {
+			return nil, err
+		}
+		if norm < 0.999999 || norm > 1.000001 {
+			return nil, fmt.Errorf("Expected norm 1, got %v", norm)
+		}
+		v, err := vector.Get(2, tr)
+		if err != nil {
+			return nil, err
+		}
+		if v.Float != 0.8 {
+			return nil, fmt.Errorf("Expected 0.8, got %v", v.Float)
+		}
+
+		vector.Clear(tr)
+		return nil, nil
+	})
+	if e != nil {
+		t.Error(e)
+	}
+
+	if err := vector.NormalizeInPlace(db); err == nil {
+		t.Error("Expected error normalizing an empty vector")
+	}
+}
+
+func TestSearchSorted(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "")
+		vector.Clear(tr)
+		for _, v := range []interface{}{1, 3, 3, 4.5, 9} {
+			vector.Push(v, tr)
+		}
+
+		for _, c := range []struct {
+			val  interface{}
+			want int64
+		}{{0, 0}, {3, 1}, {4, 3}, {4.5, 3}, {9, 4}, {10, 5}} {
+			got, err := vector.SearchSorted(c.val, tr)
+			if err != nil {
+				return nil, err
+			}
+			if got != c.want {
+				return nil, fmt.Errorf("Expected %v to go at %d, got %d", c.val, c.want, got)
+			}
+		}
+
+		if _, err := vector.SearchSorted("3", tr); !errors.Is(err, ErrTypeMismatch) {
+			return nil, fmt.Errorf("Expected ErrTypeMismatch, got %v", err)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestInsertSorted(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	subspace, err := directory.CreateOrOpen(db, []string{"tests", "vector"}, []byte{0})
+	if err != nil {
+		panic(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+
+		vector := NewVector(subspace, "").WithDefaultFunc(func(int64) interface{} { return 5 })
+		vector.Clear(tr)
+		vector.Push(1, tr)
+		vector.Set(2, 5, tr) // index 1 sparse, reading as 5
+		vector.Push(8, tr)
+
+		for _, v := range []int{6, 0, 9} {
+			if _, err := vector.InsertSorted(v, tr); err != nil {
+				return nil, err
+			}
+		}
+
+		var got []int64
+		for i := int64(0); i < 7; i++ {
+			v, err := vector.Get(i, tr)
+			if err != nil {
+				return nil, err
+			}
+			got = append(got, v.Int)
+		}
+		if fmt.Sprint(got) != "[0 1 5 5 6 8 9]" {
+			return nil, fmt.Errorf("Expected [0 1 5 5 6 8 9], got %v", got)
+		}
+
+		v, err := vector.Get(2, tr)
+		if err != nil {
+			return nil, err
+		}
+		if v.Origin != OriginSparseDefault {
+			return nil, fmt.Errorf("Expected the sparse gap to move to index 2, got %v", v.Origin)
+		}
+
+		return nil, nil
+	})
+
+	if e != nil {
+		t.Error(e)
+	}
+}
+
+func TestReplicator(t *testing.T) {
+
+	db := fdb.MustOpenDefault()
+	dir, err := directory.CreateOrOpen(db, []string{"tests", "replicate"}, nil)
+	if err != nil {
+		panic(err)
+	}
+	src := NewVector(dir.Sub("src"), "").WithOptions(Options{ChangeFeed: true})
+	dest := NewVector(dir.Sub("dest"), "")
+	db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		tr.ClearRange(dir)
+		return nil, nil
+	})
+
+	ctx := context.Background()
+	rep := NewReplicator(src, db, dest, db, "dr")
+	rep.Policy = DestinationWins
+
+	_, err = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		src.Push("a", tr)
+		src.Push("b", tr)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := rep.Step(ctx); err != nil || n != 2 {
+		t.Fatalf("Expected 2 records applied, got %d (%v)", n, err)
+	}
+
+	// diverge index 0 on the destination, then change both items at the source
+	_, err = db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		dest.Set(0, "local", tr)
+		src.Set(0, "a2", tr)
+		src.Set(1, "b2", tr)
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := rep.Step(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	_, e := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+		var got []string
+		for i := int64(0); i < 2; i++ {
+			v, err := dest.Get(i, tr)
+			if err != nil {
+				return nil, err
+			}
+			got = append(got, v.String)
+		}
+		if fmt.Sprint(got) != "[local b2]" {
+			return nil, fmt.Errorf("Expected [local b2], got %v", got)
+		}
+
+		src.Set(0, "a3", tr)
+		return nil, nil
+	})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	rep.Policy = FailOnConflict
+	if _, err := rep.Step(ctx); !errors.Is(err, ErrReplicaConflict) {
+		t.Errorf("Expected ErrReplicaConflict, got %v", err)
+	}
+	rep.Policy = SourceWins
+	if n, err := rep.Step(ctx); err != nil || n != 1 {
+		t.Errorf("Expected the conflicting record applied, got %d (%v)", n, err)
+	}
+}
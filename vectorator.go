@@ -1,6 +1,11 @@
 package vector
 
-import "github.com/FoundationDB/fdb-go/fdb"
+import (
+	"context"
+	"errors"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+)
 
 /*
  * Vecterator - a wrapper around the default rangeIterator that
@@ -10,33 +15,123 @@ import "github.com/FoundationDB/fdb-go/fdb"
 type Vectorator struct {
 	ri   *fdb.RangeIterator
 	vect *Vector
+
+	// current key value, read by Advance so foreign keys can be skipped
+	kv    fdb.KeyValue
+	index int64
+	err   error
+
+	closed bool
+	info   *OpInfo // of GetRange, until the hook has run
 }
 
+// Move to the next item. Returns false at the end of the range or once
+// the iterator has been closed.
 func (vi *Vectorator) Advance() bool {
-	return vi.ri.Advance()
+	if vi.closed {
+		return false
+	}
+	for vi.ri.Advance() {
+		vi.kv, vi.err = vi.ri.Get()
+		if vi.err != nil {
+			return true
+		}
+		vi.index, vi.err = vi.vect.indexAt(vi.kv.Key)
+		if vi.err != nil && vi.vect.opts.SkipForeignKeys && errors.Is(vi.err, ErrForeignKey) {
+			continue
+		}
+		if vi.info != nil {
+			vi.info.addBytes(len(vi.kv.Value))
+		}
+		return true
+	}
+	vi.finish()
+	return false
 }
 
-func (vi *Vectorator) Get() (iv IndexValue, err error) {
+// Like Advance, but stops and closes the iterator once ctx is done; Err
+// then returns the context's error.
+func (vi *Vectorator) AdvanceContext(ctx context.Context) bool {
+	if vi.closed {
+		return false
+	}
+	if err := ctx.Err(); err != nil {
+		vi.err = err
+		vi.Close()
+		return false
+	}
+	return vi.Advance()
+}
 
-	kv, err := vi.ri.Get()
-	if err != nil {
+// Stop iterating. Later calls to Advance return false and the underlying
+// range read is released. Close may be called more than once.
+func (vi *Vectorator) Close() {
+	vi.closed = true
+	vi.ri = nil
+	vi.kv = fdb.KeyValue{}
+	vi.finish()
+}
+
+// Run the OnAfterOp hook of the GetRange that created the iterator, once.
+func (vi *Vectorator) finish() {
+	if vi.info == nil {
 		return
 	}
+	info := vi.info
+	vi.info = nil
+	err := vi.err
+	vi.vect.afterOp(info, &err)
+}
 
-	val, err := ValUnpack(kv.Value)
-	if err != nil {
+// The error that ended the iteration early, e.g. from AdvanceContext.
+func (vi *Vectorator) Err() error {
+	return vi.opError(vi.err)
+}
+
+func (vi *Vectorator) Get() (iv IndexValue, err error) {
+	defer func() { err = vi.opError(err) }()
+
+	if vi.err != nil {
+		err = vi.err
 		return
 	}
 
-	idx, err := vi.vect.indexAt(kv.Key)
+	val, err := ValUnpack(vi.kv.Value)
 	if err != nil {
 		return
 	}
 
 	iv = IndexValue{
-		Index: idx,
+		Index: vi.index,
 		Value: val,
 	}
 
 	return
 }
+
+// Like Get, but decodes into iv, reusing iv.Value when it is set, so that
+// scans do not allocate a Value per item. The Value is overwritten by the
+// next call; copy it to keep it.
+func (vi *Vectorator) GetInto(iv *IndexValue) error {
+	if vi.err != nil {
+		return vi.opError(vi.err)
+	}
+	if iv.Value == nil {
+		iv.Value = &Value{}
+	}
+	iv.Index = vi.index
+	return vi.opError(unpackInto(vi.kv.Value, iv.Value))
+}
+
+// Wrap an error met at the current item in an *OpError of GetRange; the
+// index is -1 when the key itself could not be read.
+func (vi *Vectorator) opError(err error) error {
+	if err == nil || vi.vect == nil {
+		return err
+	}
+	index := vi.index
+	if vi.err != nil {
+		index = -1
+	}
+	return vi.vect.opError("GetRange", index, err)
+}
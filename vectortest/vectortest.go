@@ -0,0 +1,124 @@
+// Package vectortest provides deterministic fixtures and assertions for
+// tests of code built on fdb-vector.
+package vectortest
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/FoundationDB/fdb-go/fdb"
+	vector "github.com/dedalcom/fdb-vector"
+)
+
+/*
+ * Kind - the type of the values a generator produces.
+ */
+type Kind int
+
+const (
+	Ints    Kind = iota // int64 values
+	Floats              // float64 values
+	Strings             // short printable strings
+	Mixed               // any of the above
+)
+
+// Generate n pseudo-random values of kind. The same seed always gives the
+// same values.
+func Values(seed int64, n int, kind Kind) []interface{} {
+	rnd := rand.New(rand.NewSource(seed))
+	vals := make([]interface{}, n)
+	for i := range vals {
+		k := kind
+		if k == Mixed {
+			k = Kind(rnd.Intn(int(Mixed)))
+		}
+		switch k {
+		case Ints:
+			vals[i] = rnd.Int63() - rnd.Int63()
+		case Floats:
+			vals[i] = rnd.NormFloat64() * 1000
+		case Strings:
+			b := make([]byte, 1+rnd.Intn(16))
+			for j := range b {
+				b[j] = byte('a' + rnd.Intn(26))
+			}
+			vals[i] = string(b)
+		}
+	}
+	return vals
+}
+
+// Clear vect and push the values Values(seed, n, kind) onto it. Returns
+// the values, to compare against with Equal.
+func Fill(vect *vector.Vector, seed int64, n int, kind Kind, tr fdb.Transaction) ([]interface{}, error) {
+	vals := Values(seed, n, kind)
+	vect.Clear(tr)
+	for _, v := range vals {
+		if err := vect.Push(v, tr); err != nil {
+			return nil, err
+		}
+	}
+	return vals, nil
+}
+
+// Check vect holds exactly want: same size and, at every index, an item
+// equal to want's element, or a sparse item where want has nil. Ints
+// compare equal to int and int64, floats to float64.
+func Equal(vect *vector.Vector, want []interface{}, tr fdb.ReadTransaction) error {
+	size, err := vect.Size(tr)
+	if err != nil {
+		return err
+	}
+	if size != int64(len(want)) {
+		return fmt.Errorf("size %d, want %d", size, len(want))
+	}
+	for i, w := range want {
+		got, err := vect.Get(int64(i), tr)
+		if err != nil {
+			return err
+		}
+		if !matches(got, w) {
+			return fmt.Errorf("index %d: got %s, want %v", i, describe(got), w)
+		}
+	}
+	return nil
+}
+
+// Fail t unless vect holds exactly want, see Equal.
+func AssertEqual(t testing.TB, vect *vector.Vector, want []interface{}, tr fdb.ReadTransaction) {
+	t.Helper()
+	if err := Equal(vect, want, tr); err != nil {
+		t.Error(err)
+	}
+}
+
+// Whether val is what w describes.
+func matches(val *vector.Value, w interface{}) bool {
+	switch w := w.(type) {
+	case nil:
+		return val.Origin == vector.OriginSparseDefault
+	case int:
+		return val.IsInt && val.Int == int64(w)
+	case int64:
+		return val.IsInt && val.Int == w
+	case float64:
+		return val.IsFloat && val.Float == w
+	case string:
+		return val.IsString && val.String == w
+	}
+	return false
+}
+
+// Format val for a failure message.
+func describe(val *vector.Value) string {
+	switch {
+	case val.IsInt:
+		return fmt.Sprint(val.Int)
+	case val.IsFloat:
+		return fmt.Sprint(val.Float)
+	case val.IsString:
+		return fmt.Sprintf("%q", val.String)
+	}
+	return val.Origin.String()
+}
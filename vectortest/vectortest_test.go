@@ -0,0 +1,24 @@
+package vectortest
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValuesDeterministic(t *testing.T) {
+
+	a := Values(42, 100, Mixed)
+	b := Values(42, 100, Mixed)
+	if !reflect.DeepEqual(a, b) {
+		t.Error("Expected the same seed to give the same values")
+	}
+	if reflect.DeepEqual(a, Values(43, 100, Mixed)) {
+		t.Error("Expected another seed to give other values")
+	}
+
+	for i, v := range Values(1, 10, Strings) {
+		if _, ok := v.(string); !ok {
+			t.Errorf("Expected string at %d, got %T", i, v)
+		}
+	}
+}
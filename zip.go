@@ -0,0 +1,298 @@
+package vector
+
+import (
+	"github.com/FoundationDB/fdb-go/fdb"
+)
+
+/*
+ * ZipPair - the items of two vectors at the same index.
+ */
+type ZipPair struct {
+	Index int64
+	A     *Value
+	B     *Value
+}
+
+/*
+ * Zipper - iterates two vectors side by side, index by index, see Zip.
+ */
+type Zipper struct {
+	a, b  zipSide
+	index int64
+	stop  int64
+	pair  ZipPair
+	err   error
+}
+
+// One vector of a Zipper with its next stored item read ahead, or with
+// its items already read by a ReadPlan.
+type zipSide struct {
+	vect  *Vector
+	ri    *fdb.RangeIterator
+	found map[int64][]byte // items read by a ReadPlan, instead of ri
+	size  int64
+	next  int64 // index of kv, -1 once the range is exhausted
+	kv    fdb.KeyValue
+	err   error
+	ready bool
+}
+
+// Iterate a and b side by side over the indexes in vro, streaming both
+// ranges at once. Every index is visited: sparse items read as the
+// vector's default and indexes past the end of the shorter vector with
+// Origin OriginMissing. Start and Stop are resolved against the longer
+// vector; Step is ignored, the range is always read forward.
+func Zip(a, b *Vector, vro VectRange, tr fdb.ReadTransaction) (*Zipper, error) {
+	sizeA, err := a.Size(tr)
+	if err != nil {
+		return nil, err
+	}
+	sizeB, err := b.Size(tr)
+	if err != nil {
+		return nil, err
+	}
+	size := sizeA
+	if sizeB > size {
+		size = sizeB
+	}
+
+	start, stop := vro.Start, vro.Stop
+	if stop == 0 || vro.Open || stop > size {
+		stop = size
+	} else if stop < 0 {
+		stop += size
+	}
+	if start < 0 {
+		start += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > stop {
+		start = stop
+	}
+
+	z := &Zipper{index: start, stop: stop}
+	for _, s := range []struct {
+		side *zipSide
+		vect *Vector
+		size int64
+	}{{&z.a, a, sizeA}, {&z.b, b, sizeB}} {
+		kr := fdb.KeyRange{Begin: s.vect.keyAt(start), End: s.vect.keyAt(stop)}
+		*s.side = zipSide{
+			vect: s.vect,
+			ri:   tr.GetRange(kr, fdb.RangeOptions{}).Iterator(),
+			size: s.size,
+		}
+	}
+	return z, nil
+}
+
+// Move to the next index. Returns false at the end of the range or on
+// error, see Err.
+func (z *Zipper) Advance() bool {
+	if z.err != nil || z.index >= z.stop {
+		return false
+	}
+	a, err := z.a.at(z.index)
+	if err != nil {
+		z.err = err
+		return false
+	}
+	b, err := z.b.at(z.index)
+	if err != nil {
+		z.err = err
+		return false
+	}
+	z.pair = ZipPair{Index: z.index, A: a, B: b}
+	z.index++
+	return true
+}
+
+// The current pair.
+func (z *Zipper) Get() ZipPair {
+	return z.pair
+}
+
+// The error that ended the iteration early.
+func (z *Zipper) Err() error {
+	return z.err
+}
+
+// Get the item of the side at index, which must not decrease between
+// calls.
+func (s *zipSide) at(index int64) (*Value, error) {
+	if s.found != nil {
+		if b, ok := s.found[index]; ok {
+			return ValUnpack(b)
+		}
+	} else {
+		if !s.ready {
+			s.advance()
+		}
+		if s.err != nil {
+			return nil, s.err
+		}
+		if s.next == index {
+			s.ready = false
+			return ValUnpack(s.kv.Value)
+		}
+	}
+	if index >= s.size {
+		return &Value{Origin: OriginMissing}, nil
+	}
+	return s.vect.sparseValue(index)
+}
+
+// Read ahead the next stored item.
+func (s *zipSide) advance() {
+	s.ready = true
+	if !s.ri.Advance() {
+		s.next = -1
+		return
+	}
+	s.kv, s.err = s.ri.Get()
+	if s.err != nil {
+		return
+	}
+	s.next, s.err = s.vect.indexAt(s.kv.Key)
+}
+
+// Write fn(aVal, bVal) for every index of a and b, as visited by Zip, to
+// the same index of dest, cloneChunkSize indexes per transaction. A nil
+// result leaves the index of dest untouched. The chunks are separate
+// transactions, so the inputs are not read at a single version.
+func ZipWith(db fdb.Transactor, a, b, dest *Vector, fn func(a, b *Value) (interface{}, error)) error {
+	return zipWith(db, a, b, dest, "ZipWith", func(index int64, a, b *Value) (interface{}, error) {
+		return fn(a, b)
+	})
+}
+
+// ZipWith, with fn also passed the index and progress reported as op.
+func zipWith(db fdb.Transactor, a, b, dest *Vector, op string, fn func(index int64, a, b *Value) (interface{}, error)) error {
+	p := Progress{Op: op, Last: -1}
+	next := int64(0)
+	for {
+		var visited, last int64
+		r, err := db.Transact(func(tr fdb.Transaction) (interface{}, error) {
+			visited, last = 0, -1
+			return zipChunk(a, b, dest, fn, next, func(index int64) {
+				visited++
+				last = index
+			}, tr)
+		})
+		if err != nil {
+			return err
+		}
+		n := r.(int64)
+		dest.reportProgress(&p, visited, 0, last, n < 0)
+		if n < 0 {
+			return nil
+		}
+		next = n
+	}
+}
+
+// Combine up to cloneChunkSize indexes starting at next into dest, passing
+// each index to visited. Returns the index to continue from, or -1 at the
+// end of the longer input.
+func zipChunk(a, b, dest *Vector, fn func(index int64, a, b *Value) (interface{}, error), next int64, visited func(index int64), tr fdb.Transaction) (int64, error) {
+	z, err := Zip(a, b, VectRange{Start: next, Stop: next + cloneChunkSize}, tr)
+	if err != nil {
+		return 0, err
+	}
+	for z.Advance() {
+		pair := z.Get()
+		visited(pair.Index)
+		val, err := fn(pair.Index, pair.A, pair.B)
+		if err != nil {
+			return 0, err
+		}
+		if val == nil {
+			continue
+		}
+		if err := dest.Set(pair.Index, val, tr); err != nil {
+			return 0, err
+		}
+	}
+	if err := z.Err(); err != nil {
+		return 0, err
+	}
+	if z.stop < next+cloneChunkSize {
+		return -1, nil
+	}
+	return z.stop, nil
+}
+
+// Operators of the Elementwise helpers, on ints (wrapping on overflow)
+// and on floats.
+type elementwiseOp struct {
+	name  string
+	ints  func(a, b int64) int64
+	float func(a, b float64) float64
+}
+
+var (
+	opAdd = elementwiseOp{"Add", func(a, b int64) int64 { return a + b }, func(a, b float64) float64 { return a + b }}
+	opSub = elementwiseOp{"Sub", func(a, b int64) int64 { return a - b }, func(a, b float64) float64 { return a - b }}
+	opMul = elementwiseOp{"Mul", func(a, b int64) int64 { return a * b }, func(a, b float64) float64 { return a * b }}
+)
+
+// Write a[i] + b[i] to dest[i] for every index of the longer of a and b,
+// streaming both like ZipWith, cloneChunkSize indexes per transaction.
+// Sparse items without a typed default, and indexes past the end of the
+// shorter input, count as 0. Two ints give an int, anything involving a
+// float gives a float; any other item fails with a *TypeMismatchError.
+func ElementwiseAdd(db fdb.Transactor, a, b, dest *Vector) error {
+	return elementwise(db, a, b, dest, opAdd)
+}
+
+// Like ElementwiseAdd, writing a[i] - b[i].
+func ElementwiseSub(db fdb.Transactor, a, b, dest *Vector) error {
+	return elementwise(db, a, b, dest, opSub)
+}
+
+// Like ElementwiseAdd, writing a[i] * b[i].
+func ElementwiseMul(db fdb.Transactor, a, b, dest *Vector) error {
+	return elementwise(db, a, b, dest, opMul)
+}
+
+func elementwise(db fdb.Transactor, a, b, dest *Vector, op elementwiseOp) error {
+	return zipWith(db, a, b, dest, "Elementwise"+op.name, op.apply)
+}
+
+func (op elementwiseOp) apply(index int64, x, y *Value) (interface{}, error) {
+	if err := checkNumeric(index, x); err != nil {
+		return nil, err
+	}
+	if err := checkNumeric(index, y); err != nil {
+		return nil, err
+	}
+	if x.IsFloat || y.IsFloat {
+		return op.float(numericFloat(x), numericFloat(y)), nil
+	}
+	return op.ints(x.Int, y.Int), nil
+}
+
+// Fail unless v is an int, a float or typeless (sparse or missing, read
+// as 0).
+func checkNumeric(index int64, v *Value) error {
+	switch {
+	case v.IsInt, v.IsFloat:
+		return nil
+	case v.IsString:
+		return &TypeMismatchError{Index: index, Want: "numeric", Got: "string"}
+	case v.IsBinary:
+		return &TypeMismatchError{Index: index, Want: "numeric", Got: "binary"}
+	case v.IsRaw:
+		return &TypeMismatchError{Index: index, Want: "numeric", Got: "raw"}
+	}
+	return nil
+}
+
+func numericFloat(v *Value) float64 {
+	if v.IsInt {
+		return float64(v.Int)
+	}
+	return v.Float
+}